@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Finatext/gha-fix/internal/audit"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+)
+
+// Values of the --format flag of the audit command.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check the workflows of all repositories of an organization without cloning them",
+	Long: `Check the workflows of all repositories of an organization through the GitHub API, without cloning them.
+
+The repositories are listed with the API, and the workflow files in .github/workflows on their default
+branches are read with the contents API. They are checked like "gha-fix pin --check" and
+"gha-fix timeout --check", using pin.ignore-owners, pin.ignore-repos and pin.strict-pinning-202508 of the
+configuration of the current directory. Archived repositories are skipped unless --include-archived.
+
+The compliance report is printed as JSON (default) with the findings of each repository, or as CSV with
+one row per repository. A repository that cannot be read is reported with the error and does not stop
+the others.
+
+A token (found as for "gha-fix pin") is needed for private repositories, and recommended for the
+rate limit. Exits with 3 if a repository is not compliant or could not be audited.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		org, _ := cmd.Flags().GetString("org")
+		if org == "" {
+			slog.Error("--org is required")
+			os.Exit(exitcode.Config)
+		}
+		format, _ := cmd.Flags().GetString("format")
+		if format != formatJSON && format != formatCSV {
+			slog.Error("invalid format. must be json or csv", "format", format)
+			os.Exit(exitcode.Config)
+		}
+
+		token, err := findGitHubToken(ctx)
+		if err != nil {
+			slog.Error("failed to get GitHub token", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		if token == "" {
+			slog.Warn("no GitHub token configured. only public repositories are audited, with a low rate limit")
+		}
+		client, err := newGitHubClient(token)
+		if err != nil {
+			slog.Error("invalid GitHub API URL", "error", err)
+			os.Exit(exitcode.Config)
+		}
+
+		includeArchived, _ := cmd.Flags().GetBool("include-archived")
+		auditor := audit.NewAuditor(client, audit.Options{
+			Pin:             pinOptions(),
+			Timeout:         timeoutOptions(),
+			IncludeArchived: includeArchived,
+		})
+		results, err := auditor.Org(ctx, org)
+		if err != nil {
+			slog.Error("failed to audit organization", "org", org, "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+
+		write := audit.WriteJSON
+		if format == formatCSV {
+			write = audit.WriteCSV
+		}
+		if err := write(cmd.OutOrStdout(), results); err != nil {
+			slog.Error("failed to write report", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		nonCompliant := 0
+		for _, r := range results {
+			if !r.Compliant() {
+				nonCompliant++
+			}
+		}
+		if nonCompliant > 0 {
+			slog.Error("found repositories that are not compliant", slog.Int("count", nonCompliant), slog.Int("total", len(results)))
+			os.Exit(exitcode.Findings)
+		}
+		slog.Info("all repositories are compliant", slog.Int("total", len(results)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().String("org", "", "Organization whose repositories to audit")
+	auditCmd.Flags().String("format", formatJSON, "Output format of the report: json or csv")
+	auditCmd.Flags().Bool("include-archived", false, "Also audit archived repositories")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/baseline"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/plugin"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Manage the baseline of known findings",
+	Long: `Manage the baseline of known findings.
+
+The baseline file (default: .gha-fix-baseline.json, see --baseline) records current findings keyed by
+rule, file, job and action. In check mode (pin --check, timeout --check), findings recorded in the
+baseline are accepted, so that CI only fails on new violations.`,
+}
+
+var baselineCreateCmd = &cobra.Command{
+	Use:   "create [file1 file2 ...]",
+	Short: "Record current findings of all fixers in the baseline file",
+	Long: `Record current findings of the pin and timeout fixers, and of the plugins in the config file, in the
+baseline file.
+
+Usage:
+  baseline create [file1 file2 ...]
+
+If no files are specified, all workflow files (.yml or .yaml) in the current directory
+and subdirectories will be processed. The pin options (ignore-owners, ignore-repos,
+strict-pinning-202508) are read from the config file. Entries of other rules, e.g. plugins
+found only on PATH, and of files that were not checked are kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		result, err := checkAll(ctx, nil, args)
+		if err != nil {
+			slog.Error("failed to check workflow files", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		rules := []string{pin.RuleName, timeout.RuleName}
+		pluginFindings, pluginRules, err := checkPlugins(ctx, args)
+		if err != nil {
+			slog.Error("failed to run plugins", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		findings := append(result.Findings, pluginFindings...)
+		rules = append(rules, pluginRules...)
+
+		path := viper.GetString("baseline")
+		b, err := baseline.Load(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("failed to read baseline file", "path", path, "error", err)
+			os.Exit(exitcode.Error)
+		}
+		if err := b.Update(findings, rules, result.Files).Save(path); err != nil {
+			slog.Error("failed to write baseline file", "path", path, "error", err)
+			os.Exit(exitcode.Error)
+		}
+		slog.Info("created baseline file", "path", path, slog.Int("findings", len(findings)))
+	},
+}
+
+// checkPlugins runs the plugins of the config file in check mode on the files of args. Returns their findings and
+// names, the rules of the findings.
+func checkPlugins(ctx context.Context, args []string) ([]ghafix.Finding, []string, error) {
+	var findings []ghafix.Finding
+	var names []string
+	for _, c := range loadedConfig.Config.Plugins {
+		if slices.Contains(names, c.Name) {
+			continue
+		}
+		p, err := plugin.Find(c.Name, loadedConfig.Config.Plugins)
+		if err != nil {
+			return nil, nil, errors.Mark(err, exitcode.ErrConfig)
+		}
+		result, err := rewrite.Check(ctx, rewrite.OSFS{}, args, viper.GetStringSlice("ignore-dirs"), p.Check)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to run plugin %s", p.Name)
+		}
+		findings = append(findings, result.Findings...)
+		names = append(names, p.Name)
+	}
+	return findings, names, nil
+}
+
+// checkAll runs all fixers in check mode on files in fsys. A nil fsys is the operating system's filesystem.
+func checkAll(ctx context.Context, fsys ghafix.FS, args []string) (ghafix.CheckResult, error) {
+	// Check mode never calls the GitHub API, so an unauthenticated client is enough.
+	pinOpts := pinOptions()
+	pinOpts.FS = fsys
+	pinCmd := ghafix.NewPinCommand(github.NewClient(nil), pinOpts)
+	pinResult, err := pinCmd.Check(ctx, args)
+	if err != nil {
+		return ghafix.CheckResult{}, err
+	}
+
+	timeoutOpts := timeoutOptions()
+	timeoutOpts.FS = fsys
+	timeoutCmd := ghafix.NewTimeoutCommand(timeoutOpts)
+	timeoutResult, err := timeoutCmd.Check(ctx, args)
+	if err != nil {
+		return ghafix.CheckResult{}, err
+	}
+
+	// Both fixers check the same files.
+	return ghafix.CheckResult{
+		Findings: append(pinResult.Findings, timeoutResult.Findings...),
+		Files:    pinResult.Files,
+	}, nil
+}
+
+// reportCheck compares the findings of a check run of rules against the baseline file, prints new findings and
+// warns about fixed baseline entries. Returns the number of new findings.
+//
+// New findings are printed one per line, or as a Markdown report under title with --format markdown. See writeReport.
+func reportCheck(cmd *cobra.Command, title string, rules []string, result ghafix.CheckResult) (int, error) {
+	path := viper.GetString("baseline")
+	b, err := baseline.Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	if err == nil {
+		slog.Debug("using baseline file", "path", path, slog.Int("entries", len(b.Entries)))
+	}
+
+	cmpResult := b.Compare(result.Findings, rules, result.Files)
+	if outputFormat(cmd) == formatText {
+		for _, f := range cmpResult.New {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s:%d: [%s] %s\n", f.File, f.Line, f.Rule, f.Message)
+		}
+	}
+	writeReport(cmd, report.FindingsMarkdown(title, cmpResult.New))
+	for _, e := range cmpResult.Fixed {
+		slog.Warn("baseline entry has been fixed. run `gha-fix baseline create` to update the baseline",
+			"file", e.File, "job", e.Job, "rule", e.Rule, "action", e.Action, slog.Int("count", e.Count))
+	}
+
+	if baselined := len(result.Findings) - len(cmpResult.New); baselined > 0 {
+		slog.Info("findings accepted by baseline", "path", path, slog.Int("count", baselined))
+	}
+	return len(cmpResult.New), nil
+}
+
+func init() {
+	rootCmd.AddCommand(baselineCmd)
+	baselineCmd.AddCommand(baselineCreateCmd)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/batch"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/pin"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run the fixers in many local repositories",
+	Long: `Run the fixers in many repositories checked out locally, and print one aggregated report.
+
+The repositories are listed in a file (--repos, one path per line, relative to the file; blank lines and
+lines starting with '#' are ignored), or are the subdirectories of a directory that contain .git (--dir).
+
+Each repository is processed with its own gha-fix.yaml, discovered as if gha-fix ran at its root.
+Settings it does not have fall back to the flags and the configuration of the current directory. The
+GitHub API client and the cache are shared: their settings (pin.github-token, pin.github-api-url,
+pin.hosts, pin.credentials, pin.credential-helper, the GitHub App, pin.wait-for-rate-limit, the mirrors
+and cache) are taken from the current directory, and a repository config setting them fails. Each
+action is resolved only once for all repositories.
+
+Each repository's lockfile (pin.lockfile, default gha-fix.lock) is used and updated like by pin.
+--update-lock refreshes the entries and --no-lock (or pin.no-lock) disables the lockfiles.
+
+After fixing, each repository is checked again: it's compliant if no findings remain that are not
+accepted by its baseline file. With --check, repositories are only checked and no token is needed.
+A failing repository is reported and does not stop the others.
+
+Exits with the code of the first failure, otherwise 4 if files were changed, 3 if a repository is
+not compliant, and 0 if all repositories are compliant.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		outputFormat(cmd)
+
+		dirs := batchRepos(cmd)
+		fixers, _ := cmd.Flags().GetStringSlice("fixers")
+		for _, fixer := range fixers {
+			if !slices.Contains(batch.Fixers, fixer) {
+				slog.Error("invalid fixer. must be pin or timeout", "fixer", fixer)
+				os.Exit(exitcode.Config)
+			}
+		}
+		check, _ := cmd.Flags().GetBool("check")
+
+		var githubToken string
+		var err error
+		if !check && slices.Contains(fixers, pin.RuleName) {
+			githubToken, err = requireGitHubToken(ctx)
+			if err != nil {
+				slog.Error("failed to get GitHub token", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+		}
+		repos, err := newRepositoryService(ctx, githubToken)
+		if err != nil {
+			slog.Error("invalid GitHub API configuration", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		pinCmd := ghafix.NewPinCommandWithService(repos, ghafix.PinOptions{})
+
+		noLock, _ := cmd.Flags().GetBool("no-lock")
+		runner := batch.NewRunner(pinCmd, batch.Options{
+			Fixers:         fixers,
+			Check:          check,
+			IgnoreDirs:     viper.GetStringSlice("ignore-dirs"),
+			TimeoutMinutes: viper.GetUint64("timeout.timeout-value"),
+			NoLock:         noLock || viper.GetBool("pin.no-lock"),
+			UpdateLock:     lockUpdate(cmd),
+			LockHost:       lockHost,
+		})
+		results := runner.Run(ctx, dirs)
+		logAPISummary()
+
+		if outputFormat(cmd) == formatText {
+			for _, r := range results {
+				printRepoResult(cmd, r)
+			}
+		}
+		writeReport(cmd, report.BatchMarkdown("gha-fix batch", results))
+		os.Exit(batchExitCode(results, check))
+	},
+}
+
+// batchRepos returns the repository directories given by --repos or --dir. Exits if neither or both are given.
+func batchRepos(cmd *cobra.Command) []string {
+	list, _ := cmd.Flags().GetString("repos")
+	dir, _ := cmd.Flags().GetString("dir")
+	if (list == "") == (dir == "") {
+		slog.Error("specify either --repos or --dir")
+		os.Exit(exitcode.Config)
+	}
+
+	var dirs []string
+	var err error
+	if list != "" {
+		dirs, err = batch.ReadRepoList(list)
+	} else {
+		dirs, err = batch.FindRepos(dir)
+	}
+	if err != nil {
+		slog.Error("failed to list repositories", "error", err)
+		os.Exit(exitcode.Config)
+	}
+	if len(dirs) == 0 {
+		slog.Error("no repositories found")
+		os.Exit(exitcode.Config)
+	}
+	return dirs
+}
+
+func printRepoResult(cmd *cobra.Command, r report.RepoResult) {
+	out := cmd.OutOrStdout()
+	if r.Err != nil {
+		fmt.Fprintf(out, "%s: failed: %v\n", r.Repo, r.Err)
+		return
+	}
+	fmt.Fprintf(out, "%s: %s, %d change(s), %d finding(s)\n", r.Repo, r.Status(), r.Applied(), len(r.Findings))
+	for _, f := range r.Findings {
+		fmt.Fprintf(out, "%s: %s:%d: [%s] %s\n", r.Repo, f.File, f.Line, f.Rule, f.Message)
+	}
+}
+
+// batchExitCode returns the exit code for results: the code of the first failure, then Changed, then Findings.
+func batchExitCode(results []report.RepoResult, check bool) int {
+	changed, compliant := false, true
+	for _, r := range results {
+		if r.Err != nil {
+			return exitcode.FromError(r.Err)
+		}
+		changed = changed || r.Applied() > 0
+		compliant = compliant && r.Compliant()
+	}
+	switch {
+	case changed && !check:
+		return exitcode.Changed
+	case !compliant:
+		return exitcode.Findings
+	default:
+		return exitcode.OK
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+
+	batchCmd.Flags().String("repos", "", "File listing the repository directories, one per line")
+	batchCmd.Flags().String("dir", "", "Directory whose subdirectories are the repositories")
+	batchCmd.Flags().StringSlice("fixers", batch.Fixers, "Fixers to run: pin, timeout")
+	batchCmd.Flags().Bool("check", false, "Only check the repositories without modifying files")
+	batchCmd.Flags().Bool("no-lock", false, "Neither read nor write the lockfiles of the repositories")
+	batchCmd.Flags().Bool("update-lock", false, "Resolve actions with the GitHub API even if they are in a lockfile, and refresh their entries")
+	addFormatFlag(batchCmd)
+}
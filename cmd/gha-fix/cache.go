@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Finatext/gha-fix/internal/cache"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/githubapi"
+	"github.com/Finatext/gha-fix/internal/logging"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of GitHub API responses",
+	Long: `Manage the on-disk cache of GitHub API responses.
+
+The tag lists and the commit SHAs of refs used to pin actions are cached per host and owner/repo in
+$XDG_CACHE_HOME/gha-fix (default: ~/.cache/gha-fix, see cache.dir in the config file). Responses are used
+without a request for cache.ttl (default: 1h), then revalidated with their ETag: unchanged responses don't
+count against the rate limit. --no-cache (cache.disabled) turns the cache off.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached responses",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newCache()
+		if err := c.Clear(); err != nil {
+			slog.Error("failed to clear cache", "error", err)
+			os.Exit(exitcode.Error)
+		}
+		slog.Info("cleared cache", "dir", c.Dir())
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number and size of cached responses per repository",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newCache()
+		stats, err := c.Stats()
+		if err != nil {
+			slog.Error("failed to read cache", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Directory: %s\n", c.Dir())
+		fmt.Fprintf(out, "Entries:   %d (%d bytes)\n", stats.Entries, stats.Size)
+		if stats.Entries == 0 {
+			return
+		}
+		fmt.Fprintf(out, "Oldest:    %s\n", stats.Oldest.Local().Format(time.DateTime))
+		fmt.Fprintf(out, "Newest:    %s\n\n", stats.Newest.Local().Format(time.DateTime))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tENTRIES")
+		for _, repo := range slices.Sorted(maps.Keys(stats.Repos)) {
+			fmt.Fprintf(w, "%s\t%d\n", repo, stats.Repos[repo])
+		}
+		if err := w.Flush(); err != nil {
+			slog.Error("failed to write cache stats", "error", err)
+			os.Exit(exitcode.Error)
+		}
+	},
+}
+
+// newCache returns the cache of cache.dir and cache.ttl.
+func newCache() *cache.Cache {
+	dir := viper.GetString("cache.dir")
+	if dir == "" {
+		dir = cache.DefaultDir()
+	}
+	ttl := cache.DefaultTTL
+	if viper.IsSet("cache.ttl") {
+		ttl = viper.GetDuration("cache.ttl")
+	}
+	return cache.New(dir, ttl)
+}
+
+// configureCache makes the GitHub API clients use the cache unless it's disabled or there is no cache directory.
+func configureCache() error {
+	if viper.GetBool("cache.disabled") {
+		return nil
+	}
+	c := newCache()
+	if c.Dir() == "" {
+		slog.Debug("no cache directory. not caching GitHub API responses")
+		return nil
+	}
+	if viper.IsSet("cache.ttl") {
+		if _, err := time.ParseDuration(viper.GetString("cache.ttl")); err != nil {
+			return errors.Mark(errors.Wrap(err, "invalid cache.ttl"), exitcode.ErrConfig)
+		}
+	}
+	slog.Debug("caching GitHub API responses", "dir", c.Dir())
+	githubapi.Transport = c.Transport(logging.Transport{})
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/report"
+)
+
+// defaultTrailer is the default of commit.trailer, appended to generated commit messages.
+const defaultTrailer = "Generated-by: gha-fix"
+
+// commitTarget is where a fixer commits its changes with --commit.
+type commitTarget struct {
+	repo   gitrepo.Repo
+	branch string
+}
+
+func addCommitFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("commit", false, "Commit the changed files to a new branch")
+	cmd.Flags().String("branch", "", "Branch to create with --commit (default: gha-fix/<fixer>-YYYYMMDD)")
+}
+
+// prepareCommit opens the git repository and checks the branch for --commit before the fixer runs, so that invalid
+// options fail without modifying files. name is the fixer in the default branch name. Returns nil without --commit.
+func prepareCommit(ctx context.Context, cmd *cobra.Command, name string) *commitTarget {
+	commit, _ := cmd.Flags().GetBool("commit")
+	branch, _ := cmd.Flags().GetString("branch")
+	if !commit {
+		if branch != "" {
+			slog.Error("--branch requires --commit")
+			os.Exit(exitcode.Config)
+		}
+		return nil
+	}
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		slog.Error("--commit cannot be used with --check")
+		os.Exit(exitcode.Config)
+	}
+
+	if branch == "" {
+		branch = "gha-fix/" + name + "-" + time.Now().Format("20060102")
+	}
+	repo, err := gitrepo.Open(ctx, ".")
+	if err != nil {
+		slog.Error("--commit requires a git repository", "error", err)
+		os.Exit(exitcode.Config)
+	}
+	if err := repo.CheckBranchName(ctx, branch); err != nil {
+		slog.Error("cannot create branch. use --branch to choose another name", "branch", branch, "error", err)
+		os.Exit(exitcode.Config)
+	}
+	return &commitTarget{repo: repo, branch: branch}
+}
+
+// commit creates the branch and commits the files changed by a fixer and extraFiles, e.g. the lockfile, with a message
+// listing changes.
+func (t *commitTarget) commit(ctx context.Context, subject string, changes []ghafix.Change, extraFiles ...string) error {
+	var paths []string
+	for _, file := range extraFiles {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		paths = append(paths, path)
+	}
+	seen := map[string]bool{}
+	for _, c := range changes {
+		if !c.Applied() || seen[c.File] {
+			continue
+		}
+		seen[c.File] = true
+		// Paths are relative to the current directory, git commands run at the top of the working tree.
+		path, err := filepath.Abs(filepath.FromSlash(c.File))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		paths = append(paths, path)
+	}
+
+	if err := t.repo.CreateBranch(ctx, t.branch); err != nil {
+		return err
+	}
+	sha, err := t.repo.CommitFiles(ctx, paths, report.CommitMessage(subject, changes, viper.GetString("commit.trailer")))
+	if err != nil {
+		return err
+	}
+	slog.Info("committed changes to branch", "branch", t.branch, logging.KeySHA, sha, slog.Int("files", len(paths)))
+	return nil
+}
+
+func init() {
+	viper.SetDefault("commit.trailer", defaultTrailer)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/Finatext/gha-fix/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate gha-fix configuration",
+	// Skip the root's config check so that subcommands can report config errors by themselves.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate a gha-fix config file",
+	Long: `Validate a gha-fix config file against the configuration schema.
+
+Unknown keys, wrong value types and invalid values are reported with file and line positions.
+Files referenced by "extends" are validated as well.
+If no file is specified, the file given by --config or the discovered config files are validated.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		var files []string
+		load := config.LoadFiles
+		switch {
+		case len(args) > 0:
+			files = args
+		case cfgFile != "":
+			files = []string{cfgFile}
+		default:
+			discovered, err := config.Discover(".")
+			if err != nil {
+				return err
+			}
+			files = discovered
+			load = config.LoadDiscovered
+		}
+		if len(files) == 0 {
+			return errors.New("no config file found. specify a file or use --config")
+		}
+
+		loaded, err := load(files)
+		if err != nil {
+			var validationErr *config.ValidationError
+			if errors.As(err, &validationErr) {
+				fmt.Fprintln(cmd.OutOrStdout(), validationErr.Error())
+				return errors.Newf("%d issue(s) found in %s", len(validationErr.Issues), validationErr.Path)
+			}
+			return err
+		}
+
+		for _, path := range loaded.Files {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: ok\n", path)
+		}
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where each value came from",
+	Long: `Show the effective configuration after merging flags, environment variables, config files
+and defaults, in that order of precedence. Each value is annotated with its source; values merged
+from several config files list all of them. Secret values such as tokens are masked.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if configErr != nil {
+			return configErr
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, k := range configKeys() {
+			value := fmt.Sprint(viper.Get(k.key))
+			if k.secret && value != "" {
+				value = "********"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k.key, value, k.source())
+		}
+		return errors.WithStack(w.Flush())
+	},
+}
+
+// configKey describes a configuration key and the places its value can come from.
+type configKey struct {
+	key    string
+	flag   *pflag.Flag
+	envs   []string
+	secret bool
+}
+
+func configKeys() []configKey {
+	return []configKey{
+		{key: "log-level", flag: rootCmd.PersistentFlags().Lookup("log-level")},
+		{key: "log-format", flag: rootCmd.PersistentFlags().Lookup("log-format")},
+		{key: "ignore-dirs", flag: rootCmd.PersistentFlags().Lookup("ignore-dirs")},
+		{key: "baseline", flag: rootCmd.PersistentFlags().Lookup("baseline")},
+		{key: "anonymous", flag: rootCmd.PersistentFlags().Lookup("anonymous")},
+		{key: "pin.github-token", flag: pinCmd.Flags().Lookup("github-token"), envs: []string{"GITHUB_TOKEN", "GH_TOKEN"}, secret: true},
+		{key: "pin.credential-helper"},
+		{key: "pin.github-api-url", flag: rootCmd.PersistentFlags().Lookup("github-api-url"), envs: []string{"GITHUB_API_URL"}},
+		{key: "pin.ignore-owners", flag: pinCmd.Flags().Lookup("ignore-owners")},
+		{key: "pin.ignore-repos", flag: pinCmd.Flags().Lookup("ignore-repos")},
+		{key: "pin.strict-pinning-202508", flag: pinCmd.Flags().Lookup("strict-pinning-202508")},
+		{key: "pin.wait-for-rate-limit", flag: pinCmd.Flags().Lookup("wait-for-rate-limit")},
+		{key: "pin.lockfile", flag: pinCmd.Flags().Lookup("lockfile")},
+		{key: "pin.no-lock", flag: pinCmd.Flags().Lookup("no-lock")},
+		{key: "pin.mirror-dir", flag: pinCmd.Flags().Lookup("mirror-dir")},
+		{key: "pin.mirror-template"},
+		{key: "pin.hosts"},
+		{key: "pin.credentials"},
+		{key: "pin.app-id", flag: pinCmd.Flags().Lookup("app-id")},
+		{key: "pin.app-private-key-file", flag: pinCmd.Flags().Lookup("app-private-key-file")},
+		{key: "pin.installation-id", flag: pinCmd.Flags().Lookup("installation-id")},
+		{key: "timeout.timeout-value", flag: timeoutCmd.Flags().Lookup("timeout-value")},
+		{key: "commit.trailer"},
+		{key: "cache.dir"},
+		{key: "cache.ttl"},
+		{key: "cache.disabled", flag: rootCmd.PersistentFlags().Lookup("no-cache")},
+		{key: "plugins"},
+	}
+}
+
+// source mirrors viper's precedence: flag, env, config file, then default.
+func (k configKey) source() string {
+	if k.flag != nil && k.flag.Changed {
+		return "flag (--" + k.flag.Name + ")"
+	}
+	// viper.AutomaticEnv looks up the upper-cased key in addition to explicitly bound variables.
+	for _, env := range append(k.envs, strings.ToUpper(k.key)) {
+		if os.Getenv(env) != "" {
+			return "env (" + env + ")"
+		}
+	}
+	if files := loadedConfig.Sources[k.key]; len(files) > 0 {
+		return "file (" + strings.Join(files, ", ") + ")"
+	}
+	return "default"
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Check staged workflow files in a git pre-commit hook",
+	Long: `Check staged workflow files in a git pre-commit hook.
+
+The hook checks the staged version of workflow files, i.e. what is going to be committed, rather than
+the working tree. Unpinned actions and jobs without timeout-minutes block the commit unless they are
+accepted by the baseline file (see "gha-fix baseline").`,
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the git pre-commit hook",
+	Long: `Install a git pre-commit hook that runs "gha-fix hook run".
+
+The hook is written to the repository's hooks directory (honoring core.hooksPath). An existing
+pre-commit hook not installed by gha-fix is only replaced with --force.
+
+The gha-fix binary must be on PATH when committing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		repo, err := gitrepo.Open(ctx, ".")
+		if err != nil {
+			slog.Error("failed to open git repository", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		var runArgs []string
+		if fix, _ := cmd.Flags().GetBool("fix"); fix {
+			runArgs = append(runArgs, "--fix")
+		}
+		force, _ := cmd.Flags().GetBool("force")
+
+		path, err := repo.Install(ctx, gitrepo.Script(runArgs), force)
+		if err != nil {
+			slog.Error("failed to install pre-commit hook", "error", err)
+			os.Exit(exitcode.Error)
+		}
+		slog.Info("installed pre-commit hook", "path", path)
+	},
+}
+
+var hookRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Check staged workflow files",
+	Long: `Check the staged version of added or modified workflow files, read from the git index.
+
+With --fix, pin actions and add timeout-minutes to the staged content, then stage the fixed content.
+The same fixes are applied to the working tree. For partially staged files, the staged and unstaged
+versions are fixed separately, so unstaged changes stay unstaged. --fix requires a GitHub token, see
+"gha-fix pin".
+
+Exits with an error if findings that are not accepted by the baseline file remain.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		repo, err := gitrepo.Open(ctx, ".")
+		if err != nil {
+			slog.Error("failed to open git repository", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		files, err := repo.StagedWorkflowFiles(ctx, viper.GetStringSlice("ignore-dirs"))
+		if err != nil {
+			slog.Error("failed to list staged workflow files", "error", err)
+			os.Exit(exitcode.Error)
+		}
+		if len(files) == 0 {
+			slog.Debug("no staged workflow files")
+			return
+		}
+
+		index, err := repo.IndexFS(ctx, files)
+		if err != nil {
+			slog.Error("failed to read staged workflow files", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		if fix, _ := cmd.Flags().GetBool("fix"); fix {
+			err := fixStaged(ctx, repo, index, files)
+			logAPISummary()
+			if err != nil {
+				slog.Error("failed to fix staged workflow files", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+		}
+
+		result, err := checkAll(ctx, index, files)
+		if err != nil {
+			slog.Error("failed to check staged workflow files", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		newFindings, err := reportCheck(cmd, "gha-fix hook run", []string{pin.RuleName, timeout.RuleName}, result)
+		if err != nil {
+			slog.Error("failed to compare findings with baseline", "error", err)
+			os.Exit(exitcode.Error)
+		}
+		if newFindings > 0 {
+			slog.Error("staged workflow files have findings. fix them or run `gha-fix hook run --fix`", slog.Int("count", newFindings))
+			os.Exit(exitcode.Findings)
+		}
+	},
+}
+
+// fixStaged applies the fixers to the staged content in index and stages the result. Files whose staged content
+// changed are fixed in the working tree too: the fixed content is written as is if the working tree matched the
+// index, while partially staged files are fixed separately to keep their unstaged changes.
+func fixStaged(ctx context.Context, repo gitrepo.Repo, index *ghafix.MemFS, files []string) error {
+	githubToken, err := requireGitHubToken(ctx)
+	if err != nil {
+		return err
+	}
+	repos, err := newRepositoryService(ctx, githubToken)
+	if err != nil {
+		return err
+	}
+	pinCmd := ghafix.NewPinCommandWithService(repos, pinOptions())
+	timeoutCmd := ghafix.NewTimeoutCommand(timeoutOptions())
+
+	before := index.Files()
+	if err := fixFiles(ctx, pinCmd.WithFS(index), timeoutCmd.WithFS(index), files); err != nil {
+		return err
+	}
+
+	after := index.Files()
+	var partialFiles []string
+	for _, path := range files {
+		content := after[path]
+		if content == before[path] {
+			continue
+		}
+
+		worktreePath := filepath.Join(repo.Root, filepath.FromSlash(path))
+		_, statErr := os.Stat(worktreePath)
+		// Compared before staging, as the working tree always differs from the fixed content once staged.
+		partial, err := repo.IsPartiallyStaged(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Stage(ctx, path, []byte(content)); err != nil {
+			return err
+		}
+		slog.Info("staged fixed file", "path", path)
+
+		switch {
+		case statErr != nil:
+			// Deleted in the working tree but not in the index; nothing to fix there.
+		case partial:
+			slog.Debug("file is partially staged, fixing unstaged content separately", "path", path)
+			partialFiles = append(partialFiles, worktreePath)
+		default:
+			if err := (ghafix.OSFS{}).WriteFile(worktreePath, []byte(content)); err != nil {
+				return errors.Wrapf(err, "failed to write fixed file: %s", path)
+			}
+		}
+	}
+	if len(partialFiles) == 0 {
+		return nil
+	}
+
+	// Fixing the working tree version separately leaves its unstaged changes as they are.
+	return fixFiles(ctx, pinCmd.WithFS(ghafix.OSFS{}), timeoutCmd.WithFS(ghafix.OSFS{}), partialFiles)
+}
+
+func fixFiles(ctx context.Context, pinCmd ghafix.PinCommand, timeoutCmd ghafix.TimeoutCommand, files []string) error {
+	if _, err := pinCmd.Run(ctx, files); err != nil {
+		return errors.Wrap(err, "failed to pin actions")
+	}
+	if _, err := timeoutCmd.Run(ctx, files); err != nil {
+		return errors.Wrap(err, "failed to add timeouts")
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookInstallCmd)
+	hookCmd.AddCommand(hookRunCmd)
+
+	hookInstallCmd.Flags().Bool("fix", false, "Install a hook that fixes and re-stages files instead of only checking them")
+	hookInstallCmd.Flags().Bool("force", false, "Overwrite an existing pre-commit hook not installed by gha-fix")
+	hookRunCmd.Flags().Bool("fix", false, "Fix staged workflow files and stage the result")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/initconfig"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate a starter gha-fix.yaml from the repository's workflows",
+	Long: `Generate a commented gha-fix.yaml by scanning the workflow files in the current directory
+and subdirectories.
+
+The generated file proposes:
+  - ignore-owners: action owners that belong to the repository's own organization,
+    detected from the "origin" git remote (override with --org)
+  - timeout-value: the median of the timeout-minutes values already present in jobs
+  - strict-pinning-202508: enabled if every action is already pinned to a commit SHA
+
+The directories that contain workflow files are listed as comments.
+An existing file is not overwritten unless --force is specified.
+
+Example:
+  gha-fix init
+  gha-fix init --org my-org --output config/gha-fix.yaml`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		output, _ := cmd.Flags().GetString("output")
+		force, _ := cmd.Flags().GetBool("force")
+		org, _ := cmd.Flags().GetString("org")
+		ignoreDirs := viper.GetStringSlice("ignore-dirs") // Use common ignore-dirs configuration
+
+		if _, err := os.Stat(output); err == nil && !force {
+			slog.Error("config file already exists. use --force to overwrite", "path", output)
+			os.Exit(exitcode.Error)
+		}
+
+		if org == "" {
+			org = initconfig.DetectOrg(ctx, ".")
+			slog.Debug("detected organization from git remote", "org", org)
+		}
+
+		proposal, err := initconfig.Scan(".", ignoreDirs, org)
+		if err != nil {
+			slog.Error("failed to scan workflow files", "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		// The config file is meant to be committed, so it gets the usual mode of files in a repository.
+		if err := os.WriteFile(output, []byte(proposal.Render()), 0o644); err != nil { //nolint:gosec
+			slog.Error("failed to write config file", "path", output, "error", err)
+			os.Exit(exitcode.Error)
+		}
+
+		slog.Info("generated config file", "path", output, slog.Int("workflow-dirs", len(proposal.WorkflowDirs)), slog.Int("actions", proposal.ActionCount))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().StringP("output", "o", "gha-fix.yaml", "Path of the config file to generate")
+	initCmd.Flags().Bool("force", false, "Overwrite the config file if it already exists")
+	initCmd.Flags().String("org", "", "Organization whose actions are proposed as ignore-owners (default: owner of the origin git remote)")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/lsp"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for workflow files over stdio",
+	Long: `Run a Language Server Protocol server over stdio.
+
+The server publishes diagnostics for actions not pinned to commit SHAs and jobs without
+timeout-minutes, offers "pin to SHA" and "add timeout-minutes" code actions, and shows the tags of
+a pinned commit SHA on hover.
+
+Options are read from the config file like the pin and timeout commands. Code actions and hovers
+call the GitHub API; configure a token as for pin to avoid the low rate limit of
+unauthenticated requests.
+
+Logs are written to stderr, which editors usually show in the language server's output panel.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		token, err := findGitHubToken(ctx)
+		if err != nil {
+			slog.Error("failed to get GitHub token", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		if token == "" && !githubAppConfigured() {
+			slog.Warn("no GitHub token found. code actions and hovers use unauthenticated requests with a low rate limit")
+		}
+		repos, err := newRepositoryService(ctx, token)
+		if err != nil {
+			slog.Error("invalid GitHub API configuration", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+
+		pinOpts := pinOptions()
+		p := pin.NewPinWithService(repos, pinOpts.IgnoreOwners, pinOpts.IgnoreRepos, pinOpts.StrictPinning202508)
+		timeoutMinutes := timeoutOptions().TimeoutMinutes
+
+		server := lsp.NewServer(lsp.Options{
+			Pin:            &p,
+			Timeout:        timeout.NewTimeout(timeoutMinutes),
+			TimeoutMinutes: timeoutMinutes,
+		})
+		if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+			slog.Error("language server stopped", "error", err)
+			os.Exit(exitcode.Error)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
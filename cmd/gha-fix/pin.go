@@ -3,9 +3,22 @@ package main
 import (
 	"context"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
 
 	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/githubapi"
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	"github.com/Finatext/gha-fix/internal/lockfile"
+	"github.com/Finatext/gha-fix/internal/logging"
+	internalpin "github.com/Finatext/gha-fix/internal/pin"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/pin"
 	"github.com/google/go-github/v72/github"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,6 +42,12 @@ You can customize the behavior with the following options:
   --ignore-owners: Skip actions from specific owners (e.g., "actions,github")
   --ignore-repos: Skip specific repositories (e.g., "actions/checkout,docker/login-action")
   --strict-pinning-202508: Enable strict SHA pinning for composite actions (GitHub's SHA pinning enforcement policy)
+  --check: Report unpinned actions without modifying files, and exit with an error if any are found.
+           Findings recorded in the baseline file (see "gha-fix baseline") are accepted.
+  --commit: Commit the modified files to a new branch (--branch, default: gha-fix/pin-YYYYMMDD)
+            with a generated message listing the pinned actions.
+  --format: Print a report of pinned and skipped actions (or findings with --check) as text or markdown.
+            In GitHub Actions, the Markdown report is also appended to $GITHUB_STEP_SUMMARY.
 
 The --strict-pinning-202508 option implements support for GitHub's SHA pinning enforcement policy
 announced in August 2025. When enabled:
@@ -41,46 +60,394 @@ for reusable workflows. See: https://github.blog/changelog/2025-08-15-github-act
 Global options:
   --ignore-dirs: Skip specific directories when searching for workflow files (e.g., "node_modules,dist")
 
-Note: GITHUB_TOKEN environment variable is required to fetch tags and commit SHAs from GitHub.`,
+Note: a GitHub token is required to fetch tags and commit SHAs from GitHub, except in check mode.
+It's taken from the first of: --github-token, GITHUB_TOKEN or GH_TOKEN, pin.github-token in the
+config file, the GitHub CLI's hosts.yml (gh auth login), ~/.netrc, and the output of the
+pin.credential-helper command. --anonymous allows pinning public actions without a token, with
+GitHub's rate limit of 60 unauthenticated requests per hour.
+
+GitHub Enterprise Server: set --github-api-url (pin.github-api-url, or GITHUB_API_URL as set in GitHub
+Actions), e.g. https://ghe.example.com; /api/v3/ is appended unless present. Actions of some owners
+can be resolved against another host, e.g. github.com through GitHub Connect, with pin.hosts in the
+config file:
+
+  pin:
+    github-api-url: https://ghe.example.com
+    hosts:
+      - api-url: https://api.github.com
+        owners: [actions, docker]      # glob patterns, the first matching host is used
+        token-env: GITHUB_COM_TOKEN    # environment variable holding the token for this host
+
+Private actions of owners no single token can read: pin.credentials chooses the token per owner,
+read from an environment variable, a file or a command. Not-found errors name the credential used,
+as GitHub answers 404 for private repositories the token cannot read:
+
+  pin:
+    credentials:
+      - owners: [org-a, org-a-*]
+        token-env: ORG_A_TOKEN
+      - owners: [org-b]
+        token-command: op read op://ci/org-b/token
+
+Trusted settings: pin.github-api-url, the api-url and token-env of pin.hosts, the token sources of
+pin.credentials and pin.credential-helper are only accepted in the user-level config or a file given
+with --config, not in a gha-fix.yaml discovered in the repository, so that untrusted checkouts can
+neither run commands nor choose which secrets are sent where.
+
+Rate limits: calls hitting a rate limit are retried after the wait told by the API (Retry-After or
+X-RateLimit-Reset) if it's at most a minute; --wait-for-rate-limit (pin.wait-for-rate-limit) waits
+however long it takes. Server errors (5xx) are retried with backoff. The API calls made and the
+remaining quota are logged at the end of the run.
+
+GitHub App: instead of a token, authenticate as a GitHub App with --app-id and --app-private-key-file
+(pin.app-id, pin.app-private-key-file). Installation tokens are created and refreshed as needed. The
+installation is looked up for the owner of each action; owners without one, e.g. public actions, use
+the first installation of the app. --installation-id uses one installation for all owners.
+
+Lockfile: the SHA and tag each reference like 'actions/checkout@v4' was resolved to are recorded in
+gha-fix.lock (--lockfile, pin.lockfile), with the time and the GitHub host. Later runs, e.g. on other
+branches, pin references found in it to the same commits without calling the API. A token is only
+required if some reference to pin is not found. --update-lock resolves them again and refreshes their
+entries; --no-lock (pin.no-lock) disables the lockfile. With --commit, the lockfile is committed with
+the workflows.
+
+Offline: --mirror-dir (pin.mirror-dir) resolves tags and branches from local git mirrors, e.g. created
+with 'git clone --mirror', instead of the GitHub API; no token is needed. The mirror of owner/repo is
+found at pin.mirror-template in the directory, {owner}/{repo}.git by default.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
+		outputFormat(cmd)
+		target := prepareCommit(ctx, cmd, cmd.Name())
 
-		githubToken := viper.GetString("pin.github-token")
-		if githubToken == "" {
-			slog.Error("GitHub token is required. Use --github-token flag, GITHUB_TOKEN env var, or pin.github-token in config file.")
-			os.Exit(1)
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			// Check mode never calls the GitHub API, so neither a token nor an authenticated client is needed.
+			pinCmd := ghafix.NewPinCommand(github.NewClient(nil), pinOptions())
+			result, err := pinCmd.Check(ctx, args)
+			if err != nil {
+				slog.Error("failed to check actions", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+			newFindings, err := reportCheck(cmd, "gha-fix pin --check", []string{pin.RuleName}, result)
+			if err != nil {
+				slog.Error("failed to compare findings with baseline", "error", err)
+				os.Exit(exitcode.Error)
+			}
+			if newFindings > 0 {
+				slog.Error("found GitHub Actions not pinned to commit SHAs", slog.Int("count", newFindings))
+				os.Exit(exitcode.Findings)
+			}
+			slog.Info("all GitHub Actions are pinned to commit SHAs or accepted by baseline")
+			return
 		}
 
-		githubClient := github.NewClient(nil).WithAuthToken(githubToken)
+		lock, err := loadLock(cmd)
+		if err != nil {
+			slog.Error("failed to load lockfile", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
 
-		// Get values from viper which can come from flags, config file, or environment variables
-		ignoreOwners := viper.GetStringSlice("pin.ignore-owners")
-		ignoreRepos := viper.GetStringSlice("pin.ignore-repos")
-		ignoreDirs := viper.GetStringSlice("ignore-dirs") // Use common ignore-dirs configuration
-		strictPinning202508 := viper.GetBool("pin.strict-pinning-202508")
+		// Actions in the lockfile are pinned without the API, so reruns don't need a token unless they add actions.
+		locked, err := allLocked(ctx, lock, args)
+		if err != nil {
+			slog.Error("failed to check actions", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		var githubToken string
+		if locked {
+			githubToken, err = findGitHubToken(ctx)
+		} else {
+			githubToken, err = requireGitHubToken(ctx)
+		}
+		if err != nil {
+			slog.Error("failed to get GitHub token", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
 
-		pinCmd := ghafix.NewPinCommand(githubClient, ghafix.PinOptions{
-			IgnoreOwners:        ignoreOwners,
-			IgnoreRepos:         ignoreRepos,
-			IgnoreDirs:          ignoreDirs,
-			StrictPinning202508: strictPinning202508,
-		})
+		repos, err := newRepositoryService(ctx, githubToken)
+		if err != nil {
+			slog.Error("invalid GitHub API configuration", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+
+		opts := pinOptions()
+		if lock != nil {
+			opts.Lock = lock
+		}
+		pinCmd := ghafix.NewPinCommandWithService(repos, opts)
 
 		result, err := pinCmd.Run(ctx, args)
+		logAPISummary()
 		if err != nil {
 			slog.Error("failed to pin actions", "error", err)
-			os.Exit(1)
+			os.Exit(exitcode.FromError(err))
+		}
+		writeReport(cmd, report.Markdown("gha-fix pin", result.Changes))
+
+		var lockPaths []string
+		if lock != nil && lock.Changed() {
+			path := viper.GetString("pin.lockfile")
+			if err := lock.Lockfile().Save(path); err != nil {
+				slog.Error("failed to write lockfile", "path", path, "error", err)
+				os.Exit(exitcode.Error)
+			}
+			slog.Info("updated lockfile", "path", path)
+			lockPaths = append(lockPaths, path)
+		}
+
+		if (result.Changed || len(lockPaths) > 0) && target != nil {
+			if err := target.commit(ctx, "Pin GitHub Actions to commit SHAs", result.Changes, lockPaths...); err != nil {
+				slog.Error("failed to commit changes", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
 		}
 
 		if !result.Changed {
 			slog.Info("no changes needed. all GitHub Actions are already pinned or no actions found.")
 		} else {
 			slog.Info("successfully pinned GitHub Actions to specific commit SHAs", slog.Int("changed", result.FileCount))
+			os.Exit(exitcode.Changed)
 		}
 	},
 }
 
+// loadLock loads the lockfile of pin.lockfile, or starts a new one if it doesn't exist. Returns nil with
+// pin.no-lock. Entries are refreshed with --update-lock.
+func loadLock(cmd *cobra.Command) (*lockfile.Lock, error) {
+	if viper.GetBool("pin.no-lock") {
+		if lockUpdate(cmd) {
+			return nil, errors.Mark(errors.New("--update-lock cannot be used with --no-lock"), exitcode.ErrConfig)
+		}
+		return nil, nil
+	}
+	path := viper.GetString("pin.lockfile")
+	file, err := lockfile.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = lockfile.New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lockfile.NewLock(file, lockHost, lockUpdate(cmd)), nil
+}
+
+// allLocked reports whether every reference pin would resolve in the files of args is found in lock, so that no API
+// call is made. Returns false without a lock.
+func allLocked(ctx context.Context, lock *lockfile.Lock, args []string) (bool, error) {
+	if lock == nil {
+		return false, nil
+	}
+	// Like check mode, the references to pin are found without calling the API.
+	pinCmd := ghafix.NewPinCommand(github.NewClient(nil), pinOptions())
+	result, err := pinCmd.Check(ctx, args)
+	if err != nil {
+		return false, err
+	}
+	for _, finding := range result.Findings {
+		def, ok := internalpin.ParseActionDef(finding.Action)
+		if !ok {
+			return false, nil
+		}
+		if _, ok := lock.Lookup(def); !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lockUpdate reports whether --update-lock is set.
+func lockUpdate(cmd *cobra.Command) bool {
+	update, _ := cmd.Flags().GetBool("update-lock")
+	return update
+}
+
+// lockHost returns the GitHub host actions of owner are resolved on: the host of the first pin.hosts entry matching
+// the owner, or of pin.github-api-url. Invalid URLs are reported by newRepositoryService.
+func lockHost(owner string) string {
+	apiURL := viper.GetString("pin.github-api-url")
+	for _, h := range loadedConfig.Config.Pin.Hosts {
+		if (internalpin.Route{Owners: h.Owners}).Matches(owner) {
+			apiURL = h.APIURL
+			break
+		}
+	}
+	host, _ := githubapi.WebHost(apiURL)
+	return host
+}
+
+// findGitHubToken returns the token for the API at pin.github-api-url, from the first source having one:
+// pin.github-token (set by --github-token, GITHUB_TOKEN, GH_TOKEN or the config file), the GitHub CLI's hosts.yml,
+// ~/.netrc, then pin.credential-helper. Returns "" if none has one. The source is logged at debug level.
+func findGitHubToken(ctx context.Context) (string, error) {
+	if token := viper.GetString("pin.github-token"); token != "" {
+		// configKeys can't be used here: it refers to pinCmd, which refers to this function.
+		source := configKey{key: "pin.github-token", envs: []string{"GITHUB_TOKEN", "GH_TOKEN"}}.source()
+		if ghToken != "" {
+			source = "flag (--github-token)"
+		}
+		slog.Debug("using GitHub token", "source", source)
+		return token, nil
+	}
+	token, err := githubapi.FindToken(ctx, githubapi.TokenOptions{
+		APIURL:           viper.GetString("pin.github-api-url"),
+		CredentialHelper: viper.GetString("pin.credential-helper"),
+	})
+	if err != nil {
+		return "", errors.Mark(err, exitcode.ErrConfig)
+	}
+	if token.Value != "" {
+		slog.Debug("using GitHub token", "source", token.Source)
+	}
+	return token.Value, nil
+}
+
+// requireGitHubToken returns the token of findGitHubToken, or an error if there is none and neither a GitHub App
+// nor --anonymous is configured. With --anonymous, "" is returned for unauthenticated requests. No token is needed
+// with local git mirrors.
+func requireGitHubToken(ctx context.Context) (string, error) {
+	if mirrorConfigured() {
+		return "", nil
+	}
+	token, err := findGitHubToken(ctx)
+	if err != nil || token != "" || githubAppConfigured() {
+		return token, err
+	}
+	if viper.GetBool("anonymous") {
+		slog.Warn("no GitHub token found. using unauthenticated requests, limited to 60 per hour")
+		return "", nil
+	}
+	return "", errors.Mark(errors.New("GitHub token is required. Use --github-token, GITHUB_TOKEN or GH_TOKEN, pin.github-token in the config file, "+
+		"gh auth login, ~/.netrc, pin.credential-helper, or a GitHub App. Use --anonymous for public actions only"), exitcode.ErrConfig)
+}
+
+// newGitHubClient creates a client for the GitHub API at pin.github-api-url (github.com by default), authenticated
+// with token, or an unauthenticated one if token is empty. API calls are logged at debug level.
+func newGitHubClient(token string) (*github.Client, error) {
+	client, err := githubapi.NewClient(token, viper.GetString("pin.github-api-url"))
+	return client, errors.Mark(err, exitcode.ErrConfig)
+}
+
+// newRepositoryService creates the service actions are resolved with: the API of newGitHubClient, authenticated as
+// the GitHub App if configured, the APIs of pin.hosts for their owners, and the tokens of pin.credentials for
+// theirs. With pin.mirror-dir, the local git mirrors are read instead and token is ignored.
+func newRepositoryService(ctx context.Context, token string) (ghafix.RepositoryService, error) {
+	if mirrorConfigured() {
+		dir := viper.GetString("pin.mirror-dir")
+		if _, err := os.Stat(dir); err != nil {
+			return nil, errors.Mark(errors.Wrap(err, "invalid mirror directory"), exitcode.ErrConfig)
+		}
+		slog.Debug("resolving actions from local git mirrors", "dir", dir)
+		return gitrepo.NewMirrorService(dir, viper.GetString("pin.mirror-template")), nil
+	}
+	client, err := newGitHubClient(token)
+	if err != nil {
+		return nil, err
+	}
+	fallback := ghafix.RepositoryService(client.Repositories)
+	if githubAppConfigured() {
+		app, err := newGitHubApp()
+		if err != nil {
+			return nil, err
+		}
+		fallback = app.RepositoryService()
+	}
+	hosts := make([]githubapi.Host, 0, len(loadedConfig.Config.Pin.Hosts))
+	for _, h := range loadedConfig.Config.Pin.Hosts {
+		host := githubapi.Host{APIURL: h.APIURL, Owners: h.Owners}
+		if h.TokenEnv != "" {
+			host.Token = os.Getenv(h.TokenEnv)
+			host.Credential = githubapi.TokenSource{Env: h.TokenEnv}.String()
+			if host.Token == "" {
+				slog.Warn("token environment variable of host is not set. using unauthenticated requests", logging.KeyHost, h.APIURL, "env", h.TokenEnv)
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	apiURL := viper.GetString("pin.github-api-url")
+	for _, c := range loadedConfig.Config.Pin.Credentials {
+		source := githubapi.TokenSource{Env: c.TokenEnv, File: c.TokenFile, Command: c.TokenCommand}
+		credToken, err := source.Token(ctx, apiURL)
+		if err != nil {
+			return nil, errors.Mark(errors.Wrapf(err, "failed to read credential %s", source), exitcode.ErrConfig)
+		}
+		if credToken == "" {
+			slog.Warn("credential has no token. using unauthenticated requests for its owners", "owners", c.Owners, "credential", source.String())
+		}
+		hosts = append(hosts, githubapi.Host{APIURL: apiURL, Owners: c.Owners, Token: credToken, Credential: source.String()})
+	}
+	repos, err := githubapi.NewRepositoryService(fallback, hosts)
+	if err != nil {
+		return nil, errors.Mark(err, exitcode.ErrConfig)
+	}
+	apiCalls = githubapi.NewRetryService(repos, githubapi.RetryOptions{Wait: viper.GetBool("pin.wait-for-rate-limit")})
+	return apiCalls, nil
+}
+
+// apiCalls is the service of the last newRepositoryService call, whose calls logAPISummary reports.
+var apiCalls *githubapi.RetryService
+
+// logAPISummary logs the GitHub API calls made by the service of newRepositoryService and the remaining quota of
+// each host, if any calls were made.
+func logAPISummary() {
+	if apiCalls == nil {
+		return
+	}
+	stats := apiCalls.Stats()
+	if stats.Calls == 0 {
+		return
+	}
+	slog.Info("GitHub API usage", "calls", stats.Calls, "cached", stats.Cached, "retries", stats.Retries, "waited", stats.Waited.Round(time.Second))
+	for _, host := range slices.Sorted(maps.Keys(stats.Quotas)) {
+		rate := stats.Quotas[host]
+		slog.Info("GitHub API rate limit", logging.KeyHost, host, "remaining", rate.Remaining, "limit", rate.Limit, "reset", rate.Reset.Local().Format(time.TimeOnly))
+	}
+}
+
+// mirrorConfigured reports whether pin.mirror-dir is set, to resolve actions from local git mirrors instead of the
+// GitHub API.
+func mirrorConfigured() bool {
+	return viper.GetString("pin.mirror-dir") != ""
+}
+
+// githubAppConfigured reports whether pin.app-id is set, to authenticate as a GitHub App instead of with a token.
+func githubAppConfigured() bool {
+	return viper.GetInt64("pin.app-id") != 0
+}
+
+// newGitHubApp creates the GitHub App of pin.app-id and pin.app-private-key-file, calling the API at
+// pin.github-api-url.
+func newGitHubApp() (*githubapi.App, error) {
+	keyFile := viper.GetString("pin.app-private-key-file")
+	if keyFile == "" {
+		return nil, errors.Mark(errors.New("--app-private-key-file is required with --app-id"), exitcode.ErrConfig)
+	}
+	pemData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Mark(errors.Wrap(err, "failed to read GitHub App private key"), exitcode.ErrConfig)
+	}
+	key, err := githubapi.ParsePrivateKey(pemData)
+	if err != nil {
+		return nil, errors.Mark(err, exitcode.ErrConfig)
+	}
+	app, err := githubapi.NewApp(githubapi.AppOptions{
+		ID:             viper.GetInt64("pin.app-id"),
+		Key:            key,
+		InstallationID: viper.GetInt64("pin.installation-id"),
+		APIURL:         viper.GetString("pin.github-api-url"),
+	})
+	return app, errors.Mark(err, exitcode.ErrConfig)
+}
+
+// pinOptions builds PinOptions from viper which can come from flags, config file, or environment variables.
+func pinOptions() ghafix.PinOptions {
+	return ghafix.PinOptions{
+		IgnoreOwners:        viper.GetStringSlice("pin.ignore-owners"),
+		IgnoreRepos:         viper.GetStringSlice("pin.ignore-repos"),
+		IgnoreDirs:          viper.GetStringSlice("ignore-dirs"), // Use common ignore-dirs configuration
+		StrictPinning202508: viper.GetBool("pin.strict-pinning-202508"),
+	}
+}
+
 var (
 	ghToken string
 )
@@ -91,13 +458,35 @@ func init() {
 	// Configure GitHub token options specifically for the pin command
 	pinCmd.Flags().StringVarP(&ghToken, "github-token", "", "", "GitHub token for accessing GitHub API (can also be set via GITHUB_TOKEN env var or pin.github-token in config)")
 	cobra.CheckErr(viper.BindPFlag("pin.github-token", pinCmd.Flags().Lookup("github-token")))
-	// Bind GITHUB_TOKEN environment variable directly to pin.github-token
+	// Bind GITHUB_TOKEN, then GH_TOKEN as used by the GitHub CLI, directly to pin.github-token
 	// This avoids the prefix from viper.SetEnvPrefix
-	cobra.CheckErr(viper.BindEnv("pin.github-token", "GITHUB_TOKEN"))
+	cobra.CheckErr(viper.BindEnv("pin.github-token", "GITHUB_TOKEN", "GH_TOKEN"))
+
+	pinCmd.Flags().Int64("app-id", 0, "ID of a GitHub App to authenticate as instead of a token")
+	pinCmd.Flags().String("app-private-key-file", "", "PEM file of the GitHub App's private key")
+	pinCmd.Flags().Int64("installation-id", 0, "GitHub App installation to use for all owners (default: looked up per owner)")
+	cobra.CheckErr(viper.BindPFlag("pin.app-id", pinCmd.Flags().Lookup("app-id")))
+	cobra.CheckErr(viper.BindPFlag("pin.app-private-key-file", pinCmd.Flags().Lookup("app-private-key-file")))
+	cobra.CheckErr(viper.BindPFlag("pin.installation-id", pinCmd.Flags().Lookup("installation-id")))
+
+	pinCmd.Flags().Bool("wait-for-rate-limit", false, "Wait for GitHub API rate limits to reset instead of failing when the wait is longer than a minute")
+	cobra.CheckErr(viper.BindPFlag("pin.wait-for-rate-limit", pinCmd.Flags().Lookup("wait-for-rate-limit")))
+
+	pinCmd.Flags().String("mirror-dir", "", "Resolve actions from local git mirrors in this directory instead of the GitHub API")
+	cobra.CheckErr(viper.BindPFlag("pin.mirror-dir", pinCmd.Flags().Lookup("mirror-dir")))
+
+	pinCmd.Flags().String("lockfile", lockfile.DefaultPath, "Lockfile recording the versions actions were resolved to, reused by later runs")
+	pinCmd.Flags().Bool("no-lock", false, "Neither read nor write the lockfile")
+	pinCmd.Flags().Bool("update-lock", false, "Resolve actions with the GitHub API even if they are in the lockfile, and refresh their entries")
+	cobra.CheckErr(viper.BindPFlag("pin.lockfile", pinCmd.Flags().Lookup("lockfile")))
+	cobra.CheckErr(viper.BindPFlag("pin.no-lock", pinCmd.Flags().Lookup("no-lock")))
 
 	pinCmd.Flags().StringSlice("ignore-owners", []string{}, "Comma-separated list of owners to ignore")
 	pinCmd.Flags().StringSlice("ignore-repos", []string{}, "Comma-separated list of repos to ignore in format owner/repo")
 	pinCmd.Flags().Bool("strict-pinning-202508", false, "Enable strict SHA pinning for composite actions (GitHub's SHA pinning enforcement policy)")
+	pinCmd.Flags().Bool("check", false, "Report unpinned actions without modifying files and fail on findings not in the baseline")
+	addFormatFlag(pinCmd)
+	addCommitFlags(pinCmd)
 
 	cobra.CheckErr(viper.BindPFlag("pin.ignore-owners", pinCmd.Flags().Lookup("ignore-owners")))
 	cobra.CheckErr(viper.BindPFlag("pin.ignore-repos", pinCmd.Flags().Lookup("ignore-repos")))
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/plugin"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "List and run external fixers",
+	Long: `List and run external fixers (plugins).
+
+A plugin is an executable named gha-fix-<name> on PATH, or an entry of "plugins" in the config file:
+
+  plugins:
+    - name: shell
+      command: ./tools/gha-fix-shell   # default: gha-fix-<name> on PATH
+      options:                         # passed to the plugin as is
+        shell: bash
+
+A relative command is resolved from the directory of the config file. command is only accepted in the
+user-level config or a file given with --config, not in a gha-fix.yaml discovered in the repository.
+
+gha-fix writes one JSON request per workflow file to the plugin's stdin and reads a JSON response from
+its stdout (protocol version 1):
+
+  request:  {"version": 1, "mode": "fix" or "check", "file": "...", "content": "...", "options": {...}}
+  response: {"version": 1,
+             "edits":    [{"start_line": 3, "end_line": 3, "new_text": "..."}],
+             "changes":  [{"job": "...", "action": "...", "line": 3, "to": "...", "tag": "...", "skipped": "..."}],
+             "findings": [{"job": "...", "action": "...", "line": 3, "message": "..."}],
+             "error":    "..."}
+
+An edit replaces the lines from start_line to end_line (1-based, inclusive) with new_text; use
+end_line = start_line - 1 to insert. Edits must not overlap. Changes describe the edits for reports and
+commit messages; without them each edit is reported at its start line. A non-zero exit status or an
+error in the response fails the run.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins found in the config and on PATH",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		plugins, err := plugin.Discover(loadedConfig.Config.Plugins)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSOURCE\tPATH")
+		for _, p := range plugins {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Source, p.Path)
+		}
+		return errors.WithStack(w.Flush())
+	},
+}
+
+var pluginRunCmd = &cobra.Command{
+	Use:   "run <name> [file1 file2 ...]",
+	Short: "Run a plugin on workflow files",
+	Long: `Run a plugin on workflow files, like the built-in fixers.
+
+If no files are specified, all workflow files (.yml or .yaml) in the current directory and subdirectories
+are processed. The plugin name is the rule of its changes and findings, e.g. in the baseline file.
+
+  --check: Report findings without modifying files, and exit with an error if any are not in the baseline.
+  --commit: Commit the modified files to a new branch (--branch, default: gha-fix/<name>-YYYYMMDD).
+  --format: Print a report of the changes (or findings with --check) as text or markdown.
+
+Example:
+  gha-fix plugin run shell
+  gha-fix plugin run shell --check .github/workflows/ci.yml`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		name, files := args[0], args[1:]
+
+		outputFormat(cmd)
+		p, err := plugin.Find(name, loadedConfig.Config.Plugins)
+		if err != nil {
+			slog.Error("failed to find plugin", "plugin", name, "error", err)
+			os.Exit(exitcode.Config)
+		}
+		target := prepareCommit(ctx, cmd, p.Name)
+		ignoreDirs := viper.GetStringSlice("ignore-dirs")
+
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			result, err := rewrite.Check(ctx, rewrite.OSFS{}, files, ignoreDirs, p.Check)
+			if err != nil {
+				slog.Error("failed to run plugin", "plugin", p.Name, "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+			newFindings, err := reportCheck(cmd, "gha-fix plugin run "+p.Name+" --check", []string{p.Name}, result)
+			if err != nil {
+				slog.Error("failed to compare findings with baseline", "error", err)
+				os.Exit(exitcode.Error)
+			}
+			if newFindings > 0 {
+				slog.Error("plugin reported findings", "plugin", p.Name, slog.Int("count", newFindings))
+				os.Exit(exitcode.Findings)
+			}
+			slog.Info("no findings or all are accepted by baseline", "plugin", p.Name)
+			return
+		}
+
+		result, err := rewrite.RewriteReport(ctx, rewrite.OSFS{}, files, ignoreDirs, p.Fix)
+		if err != nil {
+			slog.Error("failed to run plugin", "plugin", p.Name, "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		writeReport(cmd, report.Markdown("gha-fix plugin run "+p.Name, result.Changes))
+
+		if result.Changed && target != nil {
+			if err := target.commit(ctx, "Apply "+p.Name+" fixes", result.Changes); err != nil {
+				slog.Error("failed to commit changes", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+		}
+
+		if !result.Changed {
+			slog.Info("no changes needed", "plugin", p.Name)
+			return
+		}
+		slog.Info("successfully applied plugin fixes", "plugin", p.Name, slog.Int("changed", result.FileCount))
+		os.Exit(exitcode.Changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginCmd)
+	pluginCmd.AddCommand(pluginListCmd)
+	pluginCmd.AddCommand(pluginRunCmd)
+
+	pluginRunCmd.Flags().Bool("check", false, "Report findings without modifying files and fail on findings not in the baseline")
+	addFormatFlag(pluginRunCmd)
+	addCommitFlags(pluginRunCmd)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/githubapi"
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	"github.com/Finatext/gha-fix/internal/pullrequest"
+	"github.com/Finatext/gha-fix/internal/report"
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Push the current branch and open or update a pull request",
+	Long: `Push the current branch and open a pull request on GitHub, typically after "gha-fix pin --commit"
+or "gha-fix timeout --commit".
+
+The pull request body summarizes the pinned actions and added timeout-minutes, found by comparing the
+workflow files on the branch with the base branch (--base, default: the repository's default branch).
+If gha-fix already opened a pull request for the branch that is still open, its title and body are
+updated instead of opening another one.
+
+The GitHub API is the one of the remote's host: pin.github-api-url (--github-api-url, GITHUB_API_URL)
+must be on that host if set, otherwise it's derived from the host. The token is looked up for that
+host like for "gha-fix pin": from
+--github-token, GITHUB_TOKEN, pin.github-token, the GitHub CLI, ~/.netrc or pin.credential-helper. It's also used to push over HTTPS, unless
+git already has an authorization header configured, e.g. by actions/checkout. The token needs
+permission to push and to write pull requests (and issues, for --label).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		repo, err := gitrepo.Open(ctx, ".")
+		if err != nil {
+			slog.Error("failed to open git repository", "error", err)
+			os.Exit(exitcode.Config)
+		}
+		remoteName, _ := cmd.Flags().GetString("remote")
+		remoteURL, err := repo.RemoteURL(ctx, remoteName)
+		if err != nil {
+			slog.Error("failed to read remote", "error", err)
+			os.Exit(exitcode.Config)
+		}
+		remote, ok := gitrepo.ParseRemoteURL(remoteURL)
+		if !ok {
+			slog.Error("remote is not a GitHub repository", "remote", remoteName, "url", remoteURL)
+			os.Exit(exitcode.Config)
+		}
+		// The token is looked up for, sent to and used on the host of the remote only.
+		apiURL, err := remoteAPIURL(remote)
+		if err != nil {
+			slog.Error("invalid GitHub API URL", "remote", remoteName, "error", err)
+			os.Exit(exitcode.Config)
+		}
+		viper.Set("pin.github-api-url", apiURL)
+
+		githubToken, err := findGitHubToken(ctx)
+		if err != nil {
+			slog.Error("failed to get GitHub token", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		if githubToken == "" {
+			slog.Error("GitHub token is required. See \"gha-fix pin --help\" for the token sources.")
+			os.Exit(exitcode.Config)
+		}
+		client, err := newGitHubClient(githubToken)
+		if err != nil {
+			slog.Error("invalid GitHub API URL", "error", err)
+			os.Exit(exitcode.Config)
+		}
+		branch, err := repo.CurrentBranch(ctx)
+		if err != nil {
+			slog.Error("failed to read current branch. check out the branch to open a pull request for", "error", err)
+			os.Exit(exitcode.Config)
+		}
+
+		base, _ := cmd.Flags().GetString("base")
+		if base == "" {
+			base, err = pullrequest.DefaultBranch(ctx, client, remote.Owner, remote.Repo)
+			if err != nil {
+				slog.Error("failed to get default branch", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+		}
+		if branch == base {
+			slog.Error("current branch is the base branch. commit the changes to another branch, e.g. with --commit", "branch", branch)
+			os.Exit(exitcode.Config)
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		if err := repo.Push(ctx, remoteName, branch, force, githubToken); err != nil {
+			slog.Error("failed to push branch", "error", err)
+			os.Exit(exitcode.Remote)
+		}
+		slog.Debug("pushed branch", "remote", remoteName, "branch", branch)
+
+		changes, err := summarize(ctx, repo, remoteName, base, githubToken)
+		body := report.Markdown("gha-fix", changes)
+		if err != nil {
+			slog.Warn("failed to summarize changes. the pull request body will not list them", "error", err)
+			body = "Fixes to GitHub Actions workflows made by gha-fix.\n"
+		}
+
+		title, _ := cmd.Flags().GetString("title")
+		if title == "" {
+			title = pullrequest.Title(changes)
+		}
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		reviewers, _ := cmd.Flags().GetStringSlice("reviewer")
+		draft, _ := cmd.Flags().GetBool("draft")
+
+		result, err := pullrequest.CreateOrUpdate(ctx, client, pullrequest.Options{
+			Owner:     remote.Owner,
+			Repo:      remote.Repo,
+			Head:      branch,
+			Base:      base,
+			Title:     title,
+			Body:      body,
+			Labels:    labels,
+			Reviewers: reviewers,
+			Draft:     draft,
+		})
+		if err != nil {
+			slog.Error("failed to open pull request", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+		if result.Created {
+			slog.Info("opened pull request", "number", result.Number)
+		} else {
+			slog.Info("updated pull request", "number", result.Number)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), result.URL)
+	},
+}
+
+// remoteAPIURL returns the REST API of the GitHub instance hosting remote: pin.github-api-url if it's set, which
+// must be on the host of the remote, or otherwise the API of the host, see githubapi.HostAPIURL.
+func remoteAPIURL(remote gitrepo.Remote) (string, error) {
+	apiURL := viper.GetString("pin.github-api-url")
+	if apiURL == "" {
+		return githubapi.HostAPIURL(remote.Host), nil
+	}
+	host, err := githubapi.WebHost(apiURL)
+	if err != nil {
+		return "", err
+	}
+	// Remote hosts have no port.
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if !strings.EqualFold(host, remote.Host) {
+		return "", errors.Newf("the remote is on %s but the GitHub API %s is on %s. set --github-api-url to the API of %s",
+			remote.Host, apiURL, host, remote.Host)
+	}
+	return apiURL, nil
+}
+
+// summarize fetches base from remote and returns the fixes made on HEAD since it diverged from base.
+func summarize(ctx context.Context, repo gitrepo.Repo, remote, base, token string) ([]ghafix.Change, error) {
+	baseSHA, err := repo.Fetch(ctx, remote, base, token)
+	if err != nil {
+		return nil, err
+	}
+	return pullrequest.Changes(ctx, repo, baseSHA, "HEAD")
+}
+
+func init() {
+	rootCmd.AddCommand(prCmd)
+
+	prCmd.Flags().String("base", "", "Branch to merge into (default: the repository's default branch)")
+	prCmd.Flags().String("remote", "origin", "Git remote to push to, which must be a GitHub repository")
+	prCmd.Flags().String("title", "", "Title of the pull request (default: generated from the changes)")
+	prCmd.Flags().StringSlice("label", []string{}, "Labels to add to the pull request")
+	prCmd.Flags().StringSlice("reviewer", []string{}, "Users or teams (org/team) to request reviews from")
+	prCmd.Flags().Bool("draft", false, "Open the pull request as a draft")
+	prCmd.Flags().Bool("force", false, "Overwrite the remote branch if it has diverged (--force-with-lease)")
+}
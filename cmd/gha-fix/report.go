@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/report"
+)
+
+// Values of the --format flag of the pin and timeout commands.
+const (
+	formatText     = "text"
+	formatMarkdown = "markdown"
+)
+
+var formats = []string{formatText, formatMarkdown}
+
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().String("format", formatText, "Output format of the report: text or markdown")
+}
+
+// outputFormat returns the --format flag of cmd, or text for commands without the flag. Exits on invalid values.
+func outputFormat(cmd *cobra.Command) string {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return formatText
+	}
+	if !slices.Contains(formats, format) {
+		slog.Error("invalid format. must be text or markdown", "format", format)
+		os.Exit(exitcode.Config)
+	}
+	return format
+}
+
+// writeReport prints the Markdown report with --format markdown. In GitHub Actions, the report is also appended to
+// the job summary regardless of the format.
+func writeReport(cmd *cobra.Command, markdown string) {
+	if outputFormat(cmd) == formatMarkdown {
+		fmt.Fprint(cmd.OutOrStdout(), markdown)
+	}
+
+	ok, err := report.AppendStepSummary(markdown)
+	if err != nil {
+		slog.Warn("failed to append report to job summary", "error", err)
+		return
+	}
+	if ok {
+		slog.Debug("appended report to job summary", "path", os.Getenv(report.StepSummaryEnv))
+	}
+}
@@ -1,44 +1,76 @@
 package main
 
 import (
+	"bytes"
 	"log/slog"
 	"os"
 
-	"github.com/phsym/console-slog"
+	"github.com/cockroachdb/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/term"
+
+	"github.com/Finatext/gha-fix/internal/baseline"
+	"github.com/Finatext/gha-fix/internal/config"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/logging"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	// configErr holds the error from loading the config file. It's reported before running a subcommand
+	// so that `config validate` can present it by itself.
+	configErr error
+	// loadedConfig holds the merged config files and the source of each value.
+	loadedConfig config.Loaded
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "gha-fix",
 	Short: "Fix GitHub Actions workflow files",
 	Long: `A utility tool for automating GitHub Actions workflow security and maintainability improvements.
 gha-fix provides various commands to automatically fix common issues in GitHub Actions workflow files.
+
+Exit codes:
+  0  success, nothing to change
+  1  unexpected error
+  2  configuration error (config file, flags, arguments or missing token)
+  3  findings in check mode
+  4  files changed
+  5  remote resolution failure (authentication, rate limit, not found or network error)
 `,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if configErr != nil {
+			slog.Error("invalid configuration", "error", configErr)
+			os.Exit(exitcode.Config)
+		}
+		if err := configureCache(); err != nil {
+			slog.Error("invalid cache configuration", "error", err)
+			os.Exit(exitcode.FromError(err))
+		}
+	},
 }
 
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		// Commands exit by themselves on failures. Errors returned here are usage errors (unknown commands,
+		// flags or arguments) and failures of the config commands.
+		os.Exit(exitcode.Config)
 	}
 }
 
 func init() {
 	logLevel := new(slog.LevelVar)
-	slog.SetDefault(slog.New(console.NewHandler(os.Stderr, &console.HandlerOptions{
-		Level:      logLevel,
-		NoColor:    !term.IsTerminal(int(os.Stderr.Fd())),
-		TimeFormat: "2006-01-02 15:04:05.000",
-	})))
+	// Console logs until the flags and config files are read.
+	handler, err := logging.NewHandler(os.Stderr, logging.FormatConsole, logLevel)
+	cobra.CheckErr(err)
+	slog.SetDefault(slog.New(handler))
 
 	cobra.OnInitialize(initConfig)
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is ./gha-fix.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is to discover gha-fix.yaml from the current directory up to the git root, plus the user-level config)")
 
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "set log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("log-format", logging.FormatConsole, "set log format (console, text, json)")
 	rootCmd.PersistentFlags().StringSlice("ignore-dirs", []string{".git", "node_modules", "dist", "out", "vendor", ".idea", ".vscode", "bin", "build", "tmp", "coverage", ".cache", "__pycache__"}, "Comma-separated list of directory names to ignore when searching for workflow files")
 	cobra.OnInitialize(func() {
 		level := viper.GetString("log-level")
@@ -55,28 +87,74 @@ func init() {
 			logLevel.Set(slog.LevelInfo)
 			slog.Warn("invalid log level specified, using 'info'", "specified", level)
 		}
+
+		format := viper.GetString("log-format")
+		handler, err := logging.NewHandler(os.Stderr, format, logLevel)
+		if err != nil {
+			slog.Warn("invalid log format specified, using 'console'", "specified", format)
+		} else {
+			slog.SetDefault(slog.New(handler))
+		}
+
+		// Logged here rather than in initConfig so that the configured level and format apply.
+		for _, path := range loadedConfig.Files {
+			slog.Info("using config file", "path", path)
+		}
 	})
 
+	rootCmd.PersistentFlags().String("baseline", baseline.DefaultPath, "Baseline file of known findings accepted in check mode")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Don't use the on-disk cache of GitHub API responses (see \"gha-fix cache\")")
+	rootCmd.PersistentFlags().Bool("anonymous", false, "Call the GitHub API without a token if none is found, for public actions only (60 requests per hour)")
+	rootCmd.PersistentFlags().String("github-api-url", "", "Base URL of the GitHub REST API, e.g. https://ghe.example.com for GitHub Enterprise Server (default: https://api.github.com/)")
+
 	// Bind the ignore-dirs flag explicitly to ensure it's available globally
 	cobra.CheckErr(viper.BindPFlag("ignore-dirs", rootCmd.PersistentFlags().Lookup("ignore-dirs")))
 
 	// Bind all persistent flags
 	cobra.CheckErr(viper.BindPFlags(rootCmd.PersistentFlags()))
+	cobra.CheckErr(viper.BindPFlag("pin.github-api-url", rootCmd.PersistentFlags().Lookup("github-api-url")))
+	cobra.CheckErr(viper.BindPFlag("cache.disabled", rootCmd.PersistentFlags().Lookup("no-cache")))
+	// GitHub Actions sets GITHUB_API_URL to the API of the GitHub instance running the workflow.
+	cobra.CheckErr(viper.BindEnv("pin.github-api-url", "GITHUB_API_URL"))
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig discovers and merges config files, then feeds the result to viper together with ENV variables.
+//
+// With --config, only the specified file (and the files it extends) is used.
+// Otherwise, see config.Discover for the lookup order and config.LoadDiscovered for the values discovered
+// repository configs must not set.
 func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		viper.AddConfigPath(".")
-		viper.SetConfigType("yaml")
-		viper.SetConfigName("gha-fix")
+	viper.AutomaticEnv() // read in environment variables that match
+
+	files := []string{cfgFile}
+	load := config.LoadFiles
+	if cfgFile == "" {
+		discovered, err := config.Discover(".")
+		if err != nil {
+			configErr = err
+			return
+		}
+		files = discovered
+		load = config.LoadDiscovered
+	}
+	if len(files) == 0 {
+		return
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	loaded, err := load(files)
+	if err != nil {
+		configErr = err
+		return
+	}
+	loadedConfig = loaded
 
-	if err := viper.ReadInConfig(); err == nil {
-		slog.Info("using config file", "path", viper.ConfigFileUsed())
+	merged, err := loaded.YAML()
+	if err != nil {
+		configErr = err
+		return
+	}
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(bytes.NewReader(merged)); err != nil {
+		configErr = errors.Wrap(err, "failed to read merged config")
 	}
 }
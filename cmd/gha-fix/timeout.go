@@ -6,6 +6,9 @@ import (
 	"os"
 
 	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/timeout"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -27,6 +30,12 @@ and subdirectories will be processed.
 
 You can customize the behavior with the following options:
   --timeout-value, -t: The timeout value in minutes to add (default: 5)
+  --check: Report jobs without timeout-minutes without modifying files, and exit with an error if any are found.
+           Findings recorded in the baseline file (see "gha-fix baseline") are accepted.
+  --commit: Commit the modified files to a new branch (--branch, default: gha-fix/timeout-YYYYMMDD)
+            with a generated message listing the jobs.
+  --format: Print a report of changed and skipped jobs (or findings with --check) as text or markdown.
+            In GitHub Actions, the Markdown report is also appended to $GITHUB_STEP_SUMMARY.
 
 Global options:
   --ignore-dirs: Skip specific directories when searching for workflow files
@@ -44,38 +53,75 @@ Example:
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
 
-		// Get values from viper which can come from flags, config file, or environment variables
-		timeoutValue := viper.GetUint64("timeout.timeout-value")
-		ignoreDirs := viper.GetStringSlice("ignore-dirs") // Use common ignore-dirs configuration
+		outputFormat(cmd)
+		target := prepareCommit(ctx, cmd, cmd.Name())
+		opts := timeoutOptions()
+		timeoutValue := opts.TimeoutMinutes
 
 		if timeoutValue == 0 {
 			slog.Error("timeout value must be greater than 0")
-			os.Exit(1)
+			os.Exit(exitcode.Config)
 		}
 
-		timeoutCmd := ghafix.NewTimeoutCommand(ghafix.TimeoutOptions{
-			IgnoreDirs:     ignoreDirs,
-			TimeoutMinutes: timeoutValue,
-		})
+		timeoutCmd := ghafix.NewTimeoutCommand(opts)
+
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			result, err := timeoutCmd.Check(ctx, args)
+			if err != nil {
+				slog.Error("failed to check timeouts", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
+			newFindings, err := reportCheck(cmd, "gha-fix timeout --check", []string{timeout.RuleName}, result)
+			if err != nil {
+				slog.Error("failed to compare findings with baseline", "error", err)
+				os.Exit(exitcode.Error)
+			}
+			if newFindings > 0 {
+				slog.Error("found jobs without timeout-minutes", slog.Int("count", newFindings))
+				os.Exit(exitcode.Findings)
+			}
+			slog.Info("all jobs have timeout-minutes or are accepted by baseline")
+			return
+		}
 
 		result, err := timeoutCmd.Run(ctx, args)
 		if err != nil {
 			slog.Error("failed to add timeouts", "error", err)
-			os.Exit(1)
+			os.Exit(exitcode.FromError(err))
+		}
+		writeReport(cmd, report.Markdown("gha-fix timeout", result.Changes))
+
+		if result.Changed && target != nil {
+			if err := target.commit(ctx, "Add timeout-minutes to jobs", result.Changes); err != nil {
+				slog.Error("failed to commit changes", "error", err)
+				os.Exit(exitcode.FromError(err))
+			}
 		}
 
 		if !result.Changed {
 			slog.Info("no changes needed. all jobs already have timeout-minutes or no jobs found.")
 		} else {
 			slog.Info("successfully added timeout-minutes to jobs", slog.Int("changed", result.FileCount), slog.Uint64("timeout-minutes", timeoutValue))
+			os.Exit(exitcode.Changed)
 		}
 	},
 }
 
+// timeoutOptions builds TimeoutOptions from viper which can come from flags, config file, or environment variables.
+func timeoutOptions() ghafix.TimeoutOptions {
+	return ghafix.TimeoutOptions{
+		IgnoreDirs:     viper.GetStringSlice("ignore-dirs"), // Use common ignore-dirs configuration
+		TimeoutMinutes: viper.GetUint64("timeout.timeout-value"),
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(timeoutCmd)
 
 	timeoutCmd.Flags().Uint64P("timeout-value", "t", 5, "Timeout value in minutes to add to jobs")
+	timeoutCmd.Flags().Bool("check", false, "Report jobs without timeout-minutes without modifying files and fail on findings not in the baseline")
+	addFormatFlag(timeoutCmd)
+	addCommitFlags(timeoutCmd)
 
 	cobra.CheckErr(viper.BindPFlag("timeout.timeout-value", timeoutCmd.Flags().Lookup("timeout-value")))
 }
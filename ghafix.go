@@ -5,6 +5,7 @@ import (
 
 	gogithub "github.com/google/go-github/v72/github"
 
+	internalpin "github.com/Finatext/gha-fix/internal/pin"
 	"github.com/Finatext/gha-fix/internal/rewrite"
 	"github.com/Finatext/gha-fix/pin"
 	"github.com/Finatext/gha-fix/timeout"
@@ -13,6 +14,43 @@ import (
 // Result represents the result of a auto-fix operation.
 type Result = rewrite.RewriteResult
 
+// Change is a modification made by Run, or an item it skipped. See Result.Changes.
+type Change = rewrite.Change
+
+// SkipReason explains why an item was left unchanged.
+type SkipReason = rewrite.SkipReason
+
+const (
+	SkipIgnoredOwner     = rewrite.SkipIgnoredOwner
+	SkipIgnoredRepo      = rewrite.SkipIgnoredRepo
+	SkipAlreadyPinned    = rewrite.SkipAlreadyPinned
+	SkipReusableWorkflow = rewrite.SkipReusableWorkflow
+)
+
+// FS is a read/write filesystem that workflow files are read from and written back to.
+// Paths are slash-separated as in io/fs.
+type FS = rewrite.FS
+
+// OSFS is an FS backed by the operating system's filesystem. It's used when no FS is specified in options.
+type OSFS = rewrite.OSFS
+
+// DirFS is an FS backed by the directory tree rooted at the directory, with paths relative to it.
+type DirFS = rewrite.DirFS
+
+// MemFS is an in-memory FS. Use NewMemFS to create one.
+type MemFS = rewrite.MemFS
+
+// NewMemFS creates an in-memory FS holding files, keyed by slash-separated paths.
+func NewMemFS(files map[string]string) *MemFS {
+	return rewrite.NewMemFS(files)
+}
+
+// Finding represents a violation reported in check mode.
+type Finding = rewrite.Finding
+
+// CheckResult represents the result of a check operation.
+type CheckResult = rewrite.CheckResult
+
 // PinOptions defines options for the pin command.
 type PinOptions struct {
 	IgnoreOwners []string
@@ -20,8 +58,20 @@ type PinOptions struct {
 	IgnoreDirs   []string
 	// Strict SHA pinning for new GitHub's SHA pinning enforcement policy. See README for details.
 	StrictPinning202508 bool
+	// FS is the filesystem to read and write workflow files. Defaults to OSFS.
+	FS FS
+	// Lock keeps resolved versions across runs, e.g. in a lockfile. Versions found in it are used without calling
+	// the GitHub API. Copies made with WithOptions without a Lock share the lock of the original.
+	Lock Lock
 }
 
+// Lock keeps the versions action references were resolved to across runs.
+type Lock = internalpin.Lock
+
+// RepositoryService is the part of the GitHub API used to resolve action versions. The Repositories service of a
+// go-github client implements it.
+type RepositoryService = internalpin.RepositoryService
+
 // PinCommand is a command to pin GitHub Actions in workflow files to specific commit SHAs.
 type PinCommand struct {
 	pin     pin.Pin
@@ -30,8 +80,14 @@ type PinCommand struct {
 
 // NewPinCommand creates a new PinCommand with the provided GitHub client and options.
 func NewPinCommand(client *gogithub.Client, opts PinOptions) PinCommand {
+	return NewPinCommandWithService(client.Repositories, opts)
+}
+
+// NewPinCommandWithService creates a new PinCommand resolving versions with repos instead of a GitHub client, e.g. to
+// route some owners to GitHub Enterprise Server.
+func NewPinCommandWithService(repos RepositoryService, opts PinOptions) PinCommand {
 	return PinCommand{
-		pin:     pin.NewPin(client, opts.IgnoreOwners, opts.IgnoreRepos, opts.StrictPinning202508),
+		pin:     pin.NewPinWithLock(repos, opts.Lock, opts.IgnoreOwners, opts.IgnoreRepos, opts.StrictPinning202508),
 		options: opts,
 	}
 }
@@ -41,15 +97,47 @@ func NewPinCommand(client *gogithub.Client, opts PinOptions) PinCommand {
 // If filePaths is specified, pin the specified workflow files. Accepts both absolute and relative paths.
 // If filePaths is emtpy, list all workflow files (.yml or .yaml) in the current directory and subdirectories.
 //
-// When re-write YAML files, use temporary files then rename them to the original file names to do atomic updates.
+// Files are read from and written to PinOptions.FS. With OSFS, re-written YAML files are written to temporary files
+// then renamed to the original file names to do atomic updates.
+//
+// Result.Changes lists the pinned references with their commit SHAs and tags, and the skipped ones.
 func (p *PinCommand) Run(ctx context.Context, filePaths []string) (Result, error) {
-	return rewrite.Rewrite(ctx, filePaths, p.options.IgnoreDirs, p.pin.Apply)
+	return rewrite.RewriteReport(ctx, fsOrDefault(p.options.FS), filePaths, p.options.IgnoreDirs, p.pin.ApplyReport)
+}
+
+// Check reports actions that Run would pin, without modifying files or calling the GitHub API.
+// See Run for details on file handling.
+func (p *PinCommand) Check(ctx context.Context, filePaths []string) (CheckResult, error) {
+	return rewrite.Check(ctx, fsOrDefault(p.options.FS), filePaths, p.options.IgnoreDirs, p.pin.Check)
+}
+
+// WithFS returns a copy of the command that reads and writes workflow files in fsys.
+// The copy shares the cache of resolved versions, so the same action is only resolved once.
+func (p *PinCommand) WithFS(fsys FS) PinCommand {
+	c := *p
+	c.options.FS = fsys
+	return c
+}
+
+// WithOptions returns a copy of the command with opts. The copy shares the cache of resolved versions, so commands
+// with different options, e.g. for several repositories, resolve the same action only once.
+func (p *PinCommand) WithOptions(opts PinOptions) PinCommand {
+	c := PinCommand{
+		pin:     p.pin.WithOptions(opts.IgnoreOwners, opts.IgnoreRepos, opts.StrictPinning202508),
+		options: opts,
+	}
+	if opts.Lock != nil {
+		c.pin = c.pin.WithLock(opts.Lock)
+	}
+	return c
 }
 
 // TimeoutOptions defines options for the timeout command.
 type TimeoutOptions struct {
 	IgnoreDirs     []string
 	TimeoutMinutes uint64
+	// FS is the filesystem to read and write workflow files. Defaults to OSFS.
+	FS FS
 }
 
 // TimeoutCommand is a command to insert timeout-minutes to GitHub Actions jobs in workflow files.
@@ -66,7 +154,29 @@ func NewTimeoutCommand(opts TimeoutOptions) TimeoutCommand {
 
 // Run executes the timeout command with the provided context and file paths.
 // See PinCommand.Run for details on file handling.
+//
+// Result.Changes lists the jobs timeout-minutes was added to, and the skipped jobs calling reusable workflows.
 func (t TimeoutCommand) Run(ctx context.Context, filePaths []string) (Result, error) {
 	tt := timeout.NewTimeout(t.opts.TimeoutMinutes)
-	return rewrite.Rewrite(ctx, filePaths, t.opts.IgnoreDirs, tt.Insert)
+	return rewrite.RewriteReport(ctx, fsOrDefault(t.opts.FS), filePaths, t.opts.IgnoreDirs, tt.InsertReport)
+}
+
+// Check reports jobs that Run would add timeout-minutes to, without modifying files.
+// See PinCommand.Run for details on file handling.
+func (t TimeoutCommand) Check(ctx context.Context, filePaths []string) (CheckResult, error) {
+	tt := timeout.NewTimeout(t.opts.TimeoutMinutes)
+	return rewrite.Check(ctx, fsOrDefault(t.opts.FS), filePaths, t.opts.IgnoreDirs, tt.Check)
+}
+
+// WithFS returns a copy of the command that reads and writes workflow files in fsys.
+func (t TimeoutCommand) WithFS(fsys FS) TimeoutCommand {
+	t.opts.FS = fsys
+	return t
+}
+
+func fsOrDefault(fsys FS) FS {
+	if fsys == nil {
+		return OSFS{}
+	}
+	return fsys
 }
@@ -0,0 +1,242 @@
+// Package audit checks the workflows of an organization's repositories through the GitHub API, without cloning them.
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+// WorkflowsDir is the directory GitHub Actions reads workflows from.
+const WorkflowsDir = ".github/workflows"
+
+// Options configures an Auditor.
+type Options struct {
+	Pin     ghafix.PinOptions
+	Timeout ghafix.TimeoutOptions
+	// IncludeArchived also audits archived repositories, which are skipped by default.
+	IncludeArchived bool
+}
+
+// Result is the outcome of auditing one repository.
+type Result struct {
+	// Repo is the full name of the repository: owner/repo.
+	Repo string
+	// Files are the paths of the audited workflow files.
+	Files    []string
+	Findings []rewrite.Finding
+	// Err is why the repository could not be audited.
+	Err error
+}
+
+// Compliant reports whether the repository was audited and has no findings.
+func (r Result) Compliant() bool {
+	return r.Err == nil && len(r.Findings) == 0
+}
+
+// Auditor runs the pin and timeout checks on workflow files read through the contents API.
+type Auditor struct {
+	client *github.Client
+	opts   Options
+}
+
+// NewAuditor creates an Auditor. Point the client at another API, e.g. a local server, with its BaseURL.
+func NewAuditor(client *github.Client, opts Options) *Auditor {
+	return &Auditor{client: client, opts: opts}
+}
+
+// Org audits all repositories of org in the order returned by the API. Failures of a repository are recorded in
+// its result and do not stop the others. Fails only if the repositories cannot be listed.
+func (a *Auditor) Org(ctx context.Context, org string) ([]Result, error) {
+	repos, err := a.listRepos(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(repos))
+	for i, repo := range repos {
+		slog.Info("auditing repository", "repository", repo.GetFullName(), slog.Int("index", i+1), slog.Int("total", len(repos)))
+		result := a.Repo(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+		if result.Err != nil {
+			slog.Error("failed to audit repository", "repository", result.Repo, "error", result.Err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Repo audits the workflow files on the default branch of owner/repo.
+func (a *Auditor) Repo(ctx context.Context, owner, repo string) Result {
+	result := Result{Repo: owner + "/" + repo}
+
+	files, err := a.readWorkflows(ctx, owner, repo)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if len(files) == 0 {
+		return result
+	}
+
+	fsys := ghafix.NewMemFS(files)
+	pinOpts := a.opts.Pin
+	pinOpts.FS = fsys
+	// Check mode does not resolve versions, so the client is not used.
+	pinCmd := ghafix.NewPinCommand(a.client, pinOpts)
+	timeoutOpts := a.opts.Timeout
+	timeoutOpts.FS = fsys
+	timeoutCmd := ghafix.NewTimeoutCommand(timeoutOpts)
+
+	for _, check := range []func(context.Context, []string) (ghafix.CheckResult, error){pinCmd.Check, timeoutCmd.Check} {
+		checked, err := check(ctx, nil)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.Files = checked.Files
+		result.Findings = append(result.Findings, checked.Findings...)
+	}
+	return result
+}
+
+func (a *Auditor) listRepos(ctx context.Context, org string) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	opts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := a.client.Repositories.ListByOrg(ctx, org, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list repositories of %s", org)
+		}
+		for _, repo := range page {
+			if repo.GetArchived() && !a.opts.IncludeArchived {
+				slog.Debug("skipping archived repository", "repository", repo.GetFullName())
+				continue
+			}
+			repos = append(repos, repo)
+		}
+		if resp.NextPage == 0 {
+			return repos, nil
+		}
+		slog.Debug("listing next page of repositories", logging.KeyPage, resp.NextPage)
+		opts.Page = resp.NextPage
+	}
+}
+
+// readWorkflows returns the contents of the workflow files of owner/repo by path. Returns no files if the
+// repository has no workflows directory.
+func (a *Auditor) readWorkflows(ctx context.Context, owner, repo string) (map[string]string, error) {
+	_, entries, resp, err := a.client.Repositories.GetContents(ctx, owner, repo, WorkflowsDir, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to list %s", WorkflowsDir)
+	}
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		// GitHub Actions ignores subdirectories of the workflows directory.
+		if entry.GetType() != "file" || !rewrite.IsWorkflowFile(entry.GetPath(), nil) {
+			continue
+		}
+		file, _, _, err := a.client.Repositories.GetContents(ctx, owner, repo, entry.GetPath(), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", entry.GetPath())
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %s", entry.GetPath())
+		}
+		files[path.Clean(entry.GetPath())] = content
+	}
+	return files, nil
+}
+
+type jsonResult struct {
+	Repository string        `json:"repository"`
+	Compliant  bool          `json:"compliant"`
+	Workflows  int           `json:"workflows"`
+	Findings   []jsonFinding `json:"findings"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type jsonFinding struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Job     string `json:"job,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// WriteJSON writes results as an indented JSON array with the findings of each repository.
+func WriteJSON(w io.Writer, results []Result) error {
+	out := make([]jsonResult, 0, len(results))
+	for _, r := range results {
+		jr := jsonResult{
+			Repository: r.Repo,
+			Compliant:  r.Compliant(),
+			Workflows:  len(r.Files),
+			Findings:   []jsonFinding{},
+		}
+		if r.Err != nil {
+			jr.Error = r.Err.Error()
+		}
+		for _, f := range r.Findings {
+			jr.Findings = append(jr.Findings, jsonFinding(f))
+		}
+		out = append(out, jr)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(out))
+}
+
+// csvHeader is the header row of WriteCSV.
+var csvHeader = []string{"repository", "compliant", "workflows", "unpinned_actions", "jobs_without_timeout", "error"}
+
+// WriteCSV writes one row per repository with the number of findings of each rule.
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, r := range results {
+		counts := map[string]int{}
+		for _, f := range r.Findings {
+			counts[f.Rule]++
+		}
+		errMsg := ""
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		row := []string{
+			r.Repo,
+			strconv.FormatBool(r.Compliant()),
+			strconv.Itoa(len(r.Files)),
+			strconv.Itoa(counts[pin.RuleName]),
+			strconv.Itoa(counts[timeout.RuleName]),
+			errMsg,
+		}
+		if err := cw.Write(row); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+const pinnedWorkflow = `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    timeout-minutes: 10
+    steps:
+      - uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2
+`
+
+const unpinnedWorkflow = `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: my-org/setup@v1
+`
+
+// newFakeGitHub serves the repositories of the "octo" organization: "good" with a compliant workflow, "bad" with
+// an unpinned one, "empty" without workflows, "broken" failing to list them, and an archived "old". Repositories
+// are listed on two pages.
+func newFakeGitHub(t *testing.T) *github.Client {
+	t.Helper()
+	var server *httptest.Server
+
+	repo := func(name string, archived bool) map[string]any {
+		return map[string]any{"name": name, "full_name": "octo/" + name, "owner": map[string]any{"login": "octo"}, "archived": archived}
+	}
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+	file := func(path, content string) map[string]any {
+		return map[string]any{"type": "file", "path": path, "encoding": "base64", "content": base64.StdEncoding.EncodeToString([]byte(content))}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			writeJSON(w, []any{repo("empty", false), repo("broken", false), repo("old", true)})
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/octo/repos?page=2>; rel="next"`, server.URL))
+		writeJSON(w, []any{repo("good", false), repo("bad", false)})
+	})
+	mux.HandleFunc("GET /repos/octo/good/contents/.github/workflows", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []any{
+			map[string]any{"type": "file", "path": ".github/workflows/ci.yml"},
+			map[string]any{"type": "file", "path": ".github/workflows/README.md"},
+			map[string]any{"type": "dir", "path": ".github/workflows/templates"},
+		})
+	})
+	mux.HandleFunc("GET /repos/octo/good/contents/.github/workflows/ci.yml", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, file(".github/workflows/ci.yml", pinnedWorkflow))
+	})
+	mux.HandleFunc("GET /repos/octo/bad/contents/.github/workflows", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []any{map[string]any{"type": "file", "path": ".github/workflows/ci.yaml"}})
+	})
+	mux.HandleFunc("GET /repos/octo/bad/contents/.github/workflows/ci.yaml", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, file(".github/workflows/ci.yaml", unpinnedWorkflow))
+	})
+	mux.HandleFunc("GET /repos/octo/broken/contents/.github/workflows", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, map[string]any{"message": "Resource not accessible by integration"})
+	})
+	mux.HandleFunc("GET /repos/octo/old/contents/.github/workflows", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("archived repositories must be skipped")
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+	return client
+}
+
+func TestAuditor_Org(t *testing.T) {
+	auditor := NewAuditor(newFakeGitHub(t), Options{
+		Pin:     ghafix.PinOptions{IgnoreOwners: []string{"my-org"}},
+		Timeout: ghafix.TimeoutOptions{TimeoutMinutes: 10},
+	})
+	results, err := auditor.Org(context.Background(), "octo")
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, Result{Repo: "octo/good", Files: []string{".github/workflows/ci.yml"}}, results[0])
+	assert.True(t, results[0].Compliant())
+
+	assert.Equal(t, "octo/bad", results[1].Repo)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, []rewrite.Finding{
+		{Rule: "pin", File: ".github/workflows/ci.yaml", Job: "build", Action: "actions/checkout@v4", Line: 6, Message: "action is not pinned to a commit SHA: actions/checkout@v4"},
+		{Rule: "timeout", File: ".github/workflows/ci.yaml", Job: "build", Line: 3, Message: "job does not have timeout-minutes: build"},
+	}, results[1].Findings)
+	assert.False(t, results[1].Compliant())
+
+	// No workflows directory.
+	assert.Equal(t, Result{Repo: "octo/empty"}, results[2])
+
+	assert.Equal(t, "octo/broken", results[3].Repo)
+	var errResp *github.ErrorResponse
+	assert.True(t, errors.As(results[3].Err, &errResp), results[3].Err)
+	assert.False(t, results[3].Compliant())
+
+	_, err = auditor.Org(context.Background(), "missing")
+	require.Error(t, err)
+}
+
+func TestWriteJSON(t *testing.T) {
+	results := []Result{
+		{Repo: "octo/bad", Files: []string{"ci.yml"}, Findings: []rewrite.Finding{
+			{Rule: "timeout", File: "ci.yml", Job: "build", Line: 3, Message: "job does not have timeout-minutes: build"},
+		}},
+		{Repo: "octo/good"},
+		{Repo: "octo/broken", Err: errors.New("forbidden")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, results))
+	assert.JSONEq(t, `[
+		{"repository": "octo/bad", "compliant": false, "workflows": 1, "findings": [
+			{"rule": "timeout", "file": "ci.yml", "job": "build", "line": 3, "message": "job does not have timeout-minutes: build"}
+		]},
+		{"repository": "octo/good", "compliant": true, "workflows": 0, "findings": []},
+		{"repository": "octo/broken", "compliant": false, "workflows": 0, "findings": [], "error": "forbidden"}
+	]`, buf.String())
+}
+
+func TestWriteCSV(t *testing.T) {
+	results := []Result{
+		{Repo: "octo/bad", Files: []string{"a.yml", "b.yml"}, Findings: []rewrite.Finding{
+			{Rule: "pin"}, {Rule: "pin"}, {Rule: "timeout"},
+		}},
+		{Repo: "octo/broken", Err: errors.New("forbidden, really")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, results))
+	assert.Equal(t, "repository,compliant,workflows,unpinned_actions,jobs_without_timeout,error\n"+
+		"octo/bad,false,2,2,1,\n"+
+		"octo/broken,false,0,0,0,\"forbidden, really\"\n", buf.String())
+}
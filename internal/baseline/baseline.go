@@ -0,0 +1,162 @@
+package baseline
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+// DefaultPath is the default location of the baseline file, relative to the working directory.
+const DefaultPath = ".gha-fix-baseline.json"
+
+// Version is the current version of the baseline file format.
+const Version = 1
+
+// Baseline records known findings so that check mode only fails on new ones.
+//
+// Entries are keyed by rule, file, job and action instead of line numbers, so that unrelated edits that shift
+// lines do not invalidate the baseline.
+type Baseline struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"findings"`
+}
+
+// Entry is a known finding. Count is the number of identical findings, e.g. the same action used twice in a job.
+type Entry struct {
+	Rule   string `json:"rule"`
+	File   string `json:"file"`
+	Job    string `json:"job,omitempty"`
+	Action string `json:"action,omitempty"`
+	Count  int    `json:"count"`
+}
+
+type key struct {
+	rule, file, job, action string
+}
+
+func keyOf(f rewrite.Finding) key {
+	return key{rule: f.Rule, file: f.File, job: f.Job, action: f.Action}
+}
+
+func (e Entry) key() key {
+	return key{rule: e.Rule, file: e.File, job: e.Job, action: e.Action}
+}
+
+// New creates a baseline from findings.
+func New(findings []rewrite.Finding) Baseline {
+	counts := map[key]int{}
+	for _, f := range findings {
+		counts[keyOf(f)]++
+	}
+
+	entries := make([]Entry, 0, len(counts))
+	for k, count := range counts {
+		entries = append(entries, Entry{Rule: k.rule, File: k.file, Job: k.job, Action: k.action, Count: count})
+	}
+	sortEntries(entries)
+	return Baseline{Version: Version, Entries: entries}
+}
+
+// Update returns a baseline of the findings of a run of rules on files, like New, keeping the entries of b for other
+// rules or files, which were not checked in the run.
+func (b Baseline) Update(findings []rewrite.Finding, rules []string, files []string) Baseline {
+	updated := New(findings)
+	for _, e := range b.Entries {
+		if !slices.Contains(rules, e.Rule) || !slices.Contains(files, e.File) {
+			updated.Entries = append(updated.Entries, e)
+		}
+	}
+	sortEntries(updated.Entries)
+	return updated
+}
+
+// sortEntries sorts entries for stable, reviewable diffs of the baseline file.
+func sortEntries(entries []Entry) {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Job, b.Job),
+			cmp.Compare(a.Rule, b.Rule),
+			cmp.Compare(a.Action, b.Action),
+		)
+	})
+}
+
+// Load reads a baseline file. If the file does not exist, the returned error satisfies errors.Is(err, os.ErrNotExist).
+func Load(path string) (Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Baseline{}, errors.WithStack(err)
+	}
+
+	var b Baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return Baseline{}, errors.Wrapf(err, "failed to parse baseline file: %s", path)
+	}
+	if b.Version != Version {
+		return Baseline{}, errors.Newf("unsupported baseline version %d in %s: expected %d", b.Version, path, Version)
+	}
+	return b, nil
+}
+
+// Save writes the baseline to path. The file is meant to be committed, so it is readable by everyone like other
+// files in a repository.
+func (b Baseline) Save(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	content = append(content, '\n')
+	if err := os.WriteFile(path, content, 0o644); err != nil { //nolint:gosec
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Comparison is the result of comparing findings against a baseline.
+type Comparison struct {
+	// New are findings not recorded in the baseline.
+	New []rewrite.Finding
+	// Fixed are baseline entries that no longer have (enough) matching findings. Count is the number of fixed ones.
+	Fixed []Entry
+}
+
+// Compare splits findings into new ones and reports baseline entries that have been fixed.
+//
+// Only entries for the given rules and files are considered for Fixed, because other files or rules were not
+// checked in this run.
+func (b Baseline) Compare(findings []rewrite.Finding, rules []string, files []string) Comparison {
+	remaining := map[key]int{}
+	for _, e := range b.Entries {
+		remaining[e.key()] += e.Count
+	}
+
+	var cmpResult Comparison
+	for _, f := range findings {
+		k := keyOf(f)
+		if remaining[k] > 0 {
+			remaining[k]--
+			continue
+		}
+		cmpResult.New = append(cmpResult.New, f)
+	}
+
+	for _, e := range b.Entries {
+		if !slices.Contains(rules, e.Rule) || !slices.Contains(files, e.File) {
+			continue
+		}
+		k := e.key()
+		if n := remaining[k]; n > 0 {
+			cmpResult.Fixed = append(cmpResult.Fixed, Entry{Rule: e.Rule, File: e.File, Job: e.Job, Action: e.Action, Count: n})
+			// Report duplicated keys only once.
+			remaining[k] = 0
+		}
+	}
+
+	return cmpResult
+}
@@ -0,0 +1,102 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+func TestNew(t *testing.T) {
+	findings := []rewrite.Finding{
+		{Rule: "timeout", File: "b.yml", Job: "build", Line: 3},
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 10},
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 20},
+	}
+
+	b := New(findings)
+	assert.Equal(t, Baseline{
+		Version: Version,
+		Entries: []Entry{
+			{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Count: 2},
+			{Rule: "timeout", File: "b.yml", Job: "build", Count: 1},
+		},
+	}, b)
+}
+
+func TestUpdate(t *testing.T) {
+	b := Baseline{
+		Version: Version,
+		Entries: []Entry{
+			{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Count: 2},
+			{Rule: "shell", File: "a.yml", Job: "test", Count: 1},
+			{Rule: "timeout", File: "a.yml", Job: "test", Count: 1},
+			{Rule: "timeout", File: "b.yml", Job: "build", Count: 1},
+		},
+	}
+	findings := []rewrite.Finding{{Rule: "pin", File: "a.yml", Job: "lint", Action: "actions/setup-go@v5"}}
+
+	// Entries of the shell plugin and of b.yml were not checked and are kept.
+	assert.Equal(t, Baseline{
+		Version: Version,
+		Entries: []Entry{
+			{Rule: "pin", File: "a.yml", Job: "lint", Action: "actions/setup-go@v5", Count: 1},
+			{Rule: "shell", File: "a.yml", Job: "test", Count: 1},
+			{Rule: "timeout", File: "b.yml", Job: "build", Count: 1},
+		},
+	}, b.Update(findings, []string{"pin", "timeout"}, []string{"a.yml"}))
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	b := New([]rewrite.Finding{{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4"}})
+
+	require.NoError(t, b.Save(path))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, b, loaded)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "findings": []}`), 0o600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "unsupported baseline version")
+}
+
+func TestCompare(t *testing.T) {
+	b := Baseline{
+		Version: Version,
+		Entries: []Entry{
+			{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Count: 2},
+			{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/setup-go@v5", Count: 1},
+			{Rule: "timeout", File: "a.yml", Job: "test", Count: 1},
+			{Rule: "pin", File: "unchecked.yml", Job: "test", Action: "actions/checkout@v4", Count: 1},
+		},
+	}
+
+	findings := []rewrite.Finding{
+		// Lines moved, still matched by key.
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 50},
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 60},
+		// A third use of the same action in the job is new.
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 70},
+		// Same action in another job is new.
+		{Rule: "pin", File: "a.yml", Job: "lint", Action: "actions/checkout@v4", Line: 80},
+	}
+
+	got := b.Compare(findings, []string{"pin"}, []string{"a.yml"})
+	assert.Equal(t, []rewrite.Finding{
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/checkout@v4", Line: 70},
+		{Rule: "pin", File: "a.yml", Job: "lint", Action: "actions/checkout@v4", Line: 80},
+	}, got.New)
+	// The timeout entry and the unchecked file are out of scope for this run.
+	assert.Equal(t, []Entry{
+		{Rule: "pin", File: "a.yml", Job: "test", Action: "actions/setup-go@v5", Count: 1},
+	}, got.Fixed)
+}
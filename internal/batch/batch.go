@@ -0,0 +1,301 @@
+// Package batch runs the fixers over many repositories checked out locally, each with its own configuration.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/baseline"
+	"github.com/Finatext/gha-fix/internal/config"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/lockfile"
+	"github.com/Finatext/gha-fix/internal/report"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+// Fixers are the names of the fixers that can be run, in the order they run.
+var Fixers = []string{pin.RuleName, timeout.RuleName}
+
+// Options configures a Runner.
+type Options struct {
+	// Fixers are the names of the fixers to run, see Fixers.
+	Fixers []string
+	// Check only reports findings, without modifying files or calling the GitHub API.
+	Check bool
+	// IgnoreDirs is used for repositories whose config file does not set ignore-dirs.
+	IgnoreDirs []string
+	// TimeoutMinutes is used for repositories whose config file does not set timeout.timeout-value.
+	TimeoutMinutes uint64
+	// NoLock disables the lockfiles of all repositories. Repositories can disable theirs with pin.no-lock.
+	NoLock bool
+	// UpdateLock resolves all references again and refreshes the entries of the lockfiles.
+	UpdateLock bool
+	// LockHost returns the GitHub host actions of owner are resolved on, see lockfile.NewLock.
+	LockHost func(owner string) string
+}
+
+// sharedKeys are the settings of the GitHub API client and the cache, which are shared by all repositories and
+// configured by the current directory. Repositories setting them fail instead of silently resolving with other
+// settings than they ask for.
+var sharedKeys = []string{
+	"pin.github-token",
+	"pin.github-api-url",
+	"pin.hosts",
+	"pin.credentials",
+	"pin.credential-helper",
+	"pin.app-id",
+	"pin.app-private-key-file",
+	"pin.installation-id",
+	"pin.wait-for-rate-limit",
+	"pin.mirror-dir",
+	"pin.mirror-template",
+	"cache.dir",
+	"cache.ttl",
+	"cache.disabled",
+}
+
+// Runner runs the fixers in one repository after another. All repositories share the cache of resolved versions,
+// so each action is resolved once per run.
+type Runner struct {
+	pin  ghafix.PinCommand
+	opts Options
+}
+
+// NewRunner creates a Runner resolving versions with pinCmd. Only the cache of pinCmd is used, the options come
+// from the config file of each repository.
+func NewRunner(pinCmd ghafix.PinCommand, opts Options) *Runner {
+	return &Runner{pin: pinCmd, opts: opts}
+}
+
+// Run runs the fixers in each repository directory. Failures of a repository are recorded in its result and do not
+// stop the others.
+func (r *Runner) Run(ctx context.Context, dirs []string) []report.RepoResult {
+	results := make([]report.RepoResult, 0, len(dirs))
+	for i, dir := range dirs {
+		slog.Info("processing repository", "repository", dir, slog.Int("index", i+1), slog.Int("total", len(dirs)))
+		result := r.runRepo(ctx, dir)
+		if result.Err != nil {
+			slog.Error("failed to process repository", "repository", dir, "error", result.Err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (r *Runner) runRepo(ctx context.Context, dir string) report.RepoResult {
+	result := report.RepoResult{Repo: dir}
+
+	cfg, err := loadConfig(dir)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if cfg.Pin.Lockfile != "" && !filepath.IsLocal(cfg.Pin.Lockfile) {
+		result.Err = errors.Mark(errors.Newf("pin.lockfile %q must be a relative path inside the repository", cfg.Pin.Lockfile), exitcode.ErrConfig)
+		return result
+	}
+	var lock *lockfile.Lock
+	if !r.opts.Check && slices.Contains(r.opts.Fixers, pin.RuleName) && !r.opts.NoLock && !cfg.Pin.NoLock {
+		lock, err = r.loadLock(dir, cfg.Pin.Lockfile)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	fsys := ghafix.DirFS(dir)
+	ignoreDirs := cfg.IgnoreDirs
+	if len(ignoreDirs) == 0 {
+		ignoreDirs = r.opts.IgnoreDirs
+	}
+	pinOpts := ghafix.PinOptions{
+		IgnoreOwners:        cfg.Pin.IgnoreOwners,
+		IgnoreRepos:         cfg.Pin.IgnoreRepos,
+		IgnoreDirs:          ignoreDirs,
+		StrictPinning202508: cfg.Pin.StrictPinning202508 != nil && *cfg.Pin.StrictPinning202508,
+		FS:                  fsys,
+	}
+	if lock != nil {
+		pinOpts.Lock = lock
+	}
+	pinCmd := r.pin.WithOptions(pinOpts)
+	timeoutMinutes := r.opts.TimeoutMinutes
+	if cfg.Timeout.TimeoutValue != nil {
+		timeoutMinutes = *cfg.Timeout.TimeoutValue
+	}
+	timeoutCmd := ghafix.NewTimeoutCommand(ghafix.TimeoutOptions{
+		IgnoreDirs:     ignoreDirs,
+		TimeoutMinutes: timeoutMinutes,
+		FS:             fsys,
+	})
+
+	var findings []ghafix.Finding
+	var files []string
+	for _, fixer := range Fixers {
+		if !slices.Contains(r.opts.Fixers, fixer) {
+			continue
+		}
+
+		var res ghafix.Result
+		if !r.opts.Check {
+			if fixer == pin.RuleName {
+				res, err = pinCmd.Run(ctx, nil)
+			} else {
+				res, err = timeoutCmd.Run(ctx, nil)
+			}
+			if err != nil {
+				result.Err = errors.Wrapf(err, "failed to run %s", fixer)
+				return result
+			}
+			result.Changes = append(result.Changes, res.Changes...)
+		}
+
+		// Checking after fixing finds what the fixer left, e.g. actions whose version could not be resolved.
+		var checked ghafix.CheckResult
+		if fixer == pin.RuleName {
+			checked, err = pinCmd.Check(ctx, nil)
+		} else {
+			checked, err = timeoutCmd.Check(ctx, nil)
+		}
+		if err != nil {
+			result.Err = errors.Wrapf(err, "failed to check %s", fixer)
+			return result
+		}
+		findings = append(findings, checked.Findings...)
+		files = append(files, checked.Files...)
+	}
+
+	if lock != nil && lock.Changed() {
+		path := lockPath(dir, cfg.Pin.Lockfile)
+		if err := lock.Lockfile().Save(path); err != nil {
+			result.Err = errors.Wrap(err, "failed to write lockfile")
+			return result
+		}
+		slog.Info("updated lockfile", "path", path)
+	}
+
+	b, err := loadBaseline(dir, cfg.Baseline)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Findings = b.Compare(findings, r.opts.Fixers, files).New
+	return result
+}
+
+// loadConfig loads the config files of the repository at dir, discovered as if gha-fix ran in dir. Settings of
+// sharedKeys are only accepted from the user-level config, which applies to the current directory as well.
+func loadConfig(dir string) (config.Config, error) {
+	files, err := config.Discover(dir)
+	if err != nil {
+		return config.Config{}, errors.Mark(err, exitcode.ErrConfig)
+	}
+	loaded, err := config.LoadDiscovered(files)
+	if err != nil {
+		return config.Config{}, errors.Mark(err, exitcode.ErrConfig)
+	}
+	for _, path := range loaded.Files {
+		slog.Debug("using config file", "path", path)
+	}
+	userPath := config.UserConfigPath()
+	for _, key := range sharedKeys {
+		for _, path := range loaded.Sources[key] {
+			if path != userPath {
+				return config.Config{}, errors.Mark(errors.Newf("%s: %s is not supported by batch: it's shared by all repositories, "+
+					"set it in the configuration of the current directory instead", path, key), exitcode.ErrConfig)
+			}
+		}
+	}
+	return loaded.Config, nil
+}
+
+// loadLock loads the lockfile of the repository at dir, or starts a new one if it doesn't exist. path is relative to
+// dir, empty for the default.
+func (r *Runner) loadLock(dir, path string) (*lockfile.Lock, error) {
+	file, err := lockfile.Load(lockPath(dir, path))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = lockfile.New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	host := r.opts.LockHost
+	if host == nil {
+		host = func(string) string { return "github.com" }
+	}
+	return lockfile.NewLock(file, host, r.opts.UpdateLock), nil
+}
+
+// lockPath returns the path of the lockfile of the repository at dir. path is relative to dir, empty for the default.
+func lockPath(dir, path string) string {
+	if path == "" {
+		path = lockfile.DefaultPath
+	}
+	return filepath.Join(dir, path)
+}
+
+// loadBaseline loads the baseline file of the repository at dir. path is relative to dir, empty for the default.
+// A missing file is an empty baseline.
+func loadBaseline(dir, path string) (baseline.Baseline, error) {
+	if path == "" {
+		path = baseline.DefaultPath
+	}
+	b, err := baseline.Load(filepath.Join(dir, path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return baseline.Baseline{}, err
+	}
+	return b, nil
+}
+
+// ReadRepoList reads repository directories from a file with one path per line. Blank lines and lines starting
+// with '#' are ignored. Relative paths are resolved from the directory of the file.
+func ReadRepoList(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read repository list: %s", path)
+	}
+
+	var dirs []string
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs, errors.WithStack(scanner.Err())
+}
+
+// FindRepos returns the subdirectories of dir that are git checkouts, i.e. contain .git, in lexical order.
+func FindRepos(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read directory: %s", dir)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		repo := filepath.Join(dir, entry.Name())
+		// .git is a file in worktrees and submodules.
+		if _, err := os.Stat(filepath.Join(repo, ".git")); err == nil {
+			dirs = append(dirs, repo)
+		}
+	}
+	return dirs, nil
+}
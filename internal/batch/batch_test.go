@@ -0,0 +1,221 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ghafix "github.com/Finatext/gha-fix"
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/lockfile"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+const sha422 = "11bd71901bbe5b1630ceea73d27597364c9af683"
+
+const workflow = `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: my-org/setup@v1
+`
+
+func writeRepo(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+// newPinCommand returns a PinCommand resolving versions with a fake API, and the number of requests it served.
+func newPinCommand(t *testing.T) (ghafix.PinCommand, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/repos/actions/checkout/tags":
+			fmt.Fprintf(w, `[{"name": "v4.2.2", "commit": {"sha": %q}}]`, sha422)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(api.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+	return ghafix.NewPinCommand(client, ghafix.PinOptions{}), &requests
+}
+
+func TestRunner_Run(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	writeRepo(t, filepath.Join(root, "a"), map[string]string{
+		".github/workflows/ci.yml": workflow,
+		"gha-fix.yaml":             "pin:\n  ignore-owners: [my-org]\ntimeout:\n  timeout-value: 30\n",
+	})
+	writeRepo(t, filepath.Join(root, "b"), map[string]string{
+		".github/workflows/ci.yml": workflow,
+	})
+	writeRepo(t, filepath.Join(root, "invalid"), map[string]string{
+		".github/workflows/ci.yml": workflow,
+		"gha-fix.yaml":             "timeout:\n  timeout-value: 0\n",
+	})
+
+	pinCmd, requests := newPinCommand(t)
+	runner := NewRunner(pinCmd, Options{Fixers: Fixers, IgnoreDirs: []string{".git"}, TimeoutMinutes: 5})
+	results := runner.Run(context.Background(), []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "invalid"),
+	})
+	require.Len(t, results, 3)
+
+	// a: my-org is ignored by its config, so nothing remains.
+	a := results[0]
+	require.NoError(t, a.Err)
+	assert.Equal(t, []rewrite.Change{
+		{Rule: "pin", File: ".github/workflows/ci.yml", Job: "build", Action: "actions/checkout@v4", Line: 6, To: sha422, Tag: "v4.2.2"},
+		{Rule: "pin", File: ".github/workflows/ci.yml", Job: "build", Action: "my-org/setup@v1", Line: 7, Skipped: rewrite.SkipIgnoredOwner},
+		{Rule: "timeout", File: ".github/workflows/ci.yml", Job: "build", Line: 3, To: "30"},
+	}, a.Changes)
+	assert.True(t, a.Compliant())
+	assert.Contains(t, readFile(t, filepath.Join(root, "a", ".github", "workflows", "ci.yml")), "timeout-minutes: 30")
+
+	// b: my-org/setup cannot be resolved.
+	b := results[1]
+	require.Error(t, b.Err)
+	assert.Equal(t, exitcode.Remote, exitcode.FromError(b.Err))
+
+	// invalid: the config file is rejected before touching files.
+	invalid := results[2]
+	require.Error(t, invalid.Err)
+	assert.True(t, errors.Is(invalid.Err, exitcode.ErrConfig), invalid.Err)
+	assert.Equal(t, workflow, readFile(t, filepath.Join(root, "invalid", ".github", "workflows", "ci.yml")))
+
+	// actions/checkout is resolved once for a and b, my-org/setup once for b.
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRunner_Run_lockfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	locked := "eef61447b9ff4aafe5dcd4e0bbf5d482be7e7871"
+	lock := lockfile.New()
+	lock.Actions["actions/checkout@v4"] = lockfile.Entry{SHA: locked, Tag: "v4.1.7", Host: "github.com"}
+	writeRepo(t, filepath.Join(root, "locked"), map[string]string{
+		".github/workflows/ci.yml": "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n",
+		"gha-fix.yaml":             "pin:\n  lockfile: .github/gha-fix.lock\n",
+	})
+	require.NoError(t, lock.Save(filepath.Join(root, "locked", ".github", "gha-fix.lock")))
+	writeRepo(t, filepath.Join(root, "unlocked"), map[string]string{
+		".github/workflows/ci.yml": "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n",
+	})
+	writeRepo(t, filepath.Join(root, "shared"), map[string]string{
+		"gha-fix.yaml": "pin:\n  mirror-dir: /srv/mirrors\n",
+	})
+
+	pinCmd, requests := newPinCommand(t)
+	runner := NewRunner(pinCmd, Options{Fixers: []string{"pin"}})
+	results := runner.Run(context.Background(), []string{
+		filepath.Join(root, "locked"),
+		filepath.Join(root, "unlocked"),
+		filepath.Join(root, "shared"),
+	})
+	require.Len(t, results, 3)
+
+	// locked: its own lockfile is used without calling the API.
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, locked, results[0].Changes[0].To)
+	assert.Equal(t, lock, loadLockfile(t, filepath.Join(root, "locked", ".github", "gha-fix.lock")))
+
+	// unlocked: resolved with the API, and a lockfile is written.
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, sha422, results[1].Changes[0].To)
+	assert.Equal(t, sha422, loadLockfile(t, filepath.Join(root, "unlocked", lockfile.DefaultPath)).Actions["actions/checkout@v4"].SHA)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// shared: settings of the shared client are rejected.
+	require.Error(t, results[2].Err)
+	assert.True(t, errors.Is(results[2].Err, exitcode.ErrConfig), results[2].Err)
+	assert.ErrorContains(t, results[2].Err, "pin.mirror-dir is not supported by batch")
+}
+
+func loadLockfile(t *testing.T, path string) lockfile.Lockfile {
+	t.Helper()
+	l, err := lockfile.Load(path)
+	require.NoError(t, err)
+	return l
+}
+
+func TestRunner_Check(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "repo")
+	writeRepo(t, dir, map[string]string{
+		".github/workflows/ci.yml": workflow,
+		".gha-fix-baseline.json":   `{"version": 1, "findings": [{"rule": "pin", "file": ".github/workflows/ci.yml", "job": "build", "action": "my-org/setup@v1", "count": 1}]}`,
+	})
+
+	pinCmd, requests := newPinCommand(t)
+	runner := NewRunner(pinCmd, Options{Fixers: []string{"pin"}, Check: true, TimeoutMinutes: 5})
+	results := runner.Run(context.Background(), []string{dir})
+	require.Len(t, results, 1)
+
+	require.NoError(t, results[0].Err)
+	assert.Empty(t, results[0].Changes)
+	require.Len(t, results[0].Findings, 1)
+	assert.Equal(t, "actions/checkout@v4", results[0].Findings[0].Action)
+	assert.False(t, results[0].Compliant())
+	assert.Equal(t, workflow, readFile(t, filepath.Join(dir, ".github", "workflows", "ci.yml")))
+	assert.Zero(t, requests.Load())
+}
+
+func TestReadRepoList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "repos.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# platform repos\nsvc-a\n\n  svc-b  \n/abs/svc-c\n"), 0o600))
+
+	dirs, err := ReadRepoList(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "svc-a"), filepath.Join(dir, "svc-b"), "/abs/svc-c"}, dirs)
+
+	_, err = ReadRepoList(filepath.Join(dir, "missing.txt"))
+	require.Error(t, err)
+}
+
+func TestFindRepos(t *testing.T) {
+	dir := t.TempDir()
+	writeRepo(t, filepath.Join(dir, "b"), nil)
+	writeRepo(t, filepath.Join(dir, "a"), nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "not-a-repo"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0o600))
+
+	dirs, err := FindRepos(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, dirs)
+}
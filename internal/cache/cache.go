@@ -0,0 +1,329 @@
+// Package cache keeps the GitHub API responses used to resolve actions on disk across runs: the tag lists and the
+// commit SHAs of refs of each repository. Entries are served without a request until they are older than the TTL,
+// then revalidated with their ETag, so unchanged responses don't count against the rate limit.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DefaultTTL is how long entries are used without revalidation by default.
+const DefaultTTL = time.Hour
+
+// StatusHeader is set on responses served from the cache: StatusHit for fresh entries and StatusRevalidated for
+// entries the API confirmed as unchanged.
+const StatusHeader = "X-Gha-Fix-Cache"
+
+const (
+	StatusHit         = "hit"
+	StatusRevalidated = "revalidated"
+)
+
+// cachedHeaders are the response headers kept in entries. Link holds the pagination of tag lists.
+var cachedHeaders = []string{"Content-Type", "Link"}
+
+// cacheablePath matches the API paths of tag lists and commits of refs, with the owner and repository in groups 1
+// and 2. GitHub Enterprise Server serves them under /api/v3.
+var cacheablePath = regexp.MustCompile(`/repos/([^/]+)/([^/]+)/(?:tags|commits/[^/]+)$`)
+
+// entryName matches the file names of entries: the hex-encoded SHA-256 of the request.
+var entryName = regexp.MustCompile(`^[0-9a-f]{64}\.json$`)
+
+// DefaultDir returns the cache directory: $XDG_CACHE_HOME/gha-fix, falling back to ~/.cache/gha-fix. Returns an
+// empty string if neither can be determined.
+func DefaultDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "gha-fix")
+}
+
+// Cache is a directory of cached API responses, one JSON file per request under <host>/<owner>/<repo>.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// New creates a Cache in dir using entries for ttl. With a zero ttl, every entry is revalidated.
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// Dir returns the cache directory.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// entry is a cached response.
+type entry struct {
+	URL      string      `json:"url"`
+	ETag     string      `json:"etag,omitempty"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// path returns the file of the entry for req, or false if req is not cacheable. Requests differing in the Accept
+// header, e.g. the commit SHA media type, are cached separately, and so are those of different credentials: a
+// response for a private repository must not be served to a token without access, or to an anonymous request.
+// Only the hash of the Authorization header is part of the file name.
+func (c *Cache) path(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet {
+		return "", false
+	}
+	m := cacheablePath.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		return "", false
+	}
+	for _, name := range m[1:3] {
+		if name == "." || name == ".." {
+			return "", false
+		}
+	}
+	host := strings.ReplaceAll(req.URL.Host, ":", "_")
+	key := sha256.Sum256([]byte(req.Header.Get("Accept") + " " + req.Header.Get("Authorization") + " " + req.URL.String()))
+	return filepath.Join(c.dir, host, m[1], m[2], hex.EncodeToString(key[:])+".json"), true
+}
+
+// load returns the entry at path, or nil if it doesn't exist or cannot be read.
+func (c *Cache) load(path string) *entry {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("failed to read cache entry", "path", path, "error", err)
+		}
+		return nil
+	}
+	var e entry
+	if err := json.Unmarshal(content, &e); err != nil {
+		slog.Debug("ignoring invalid cache entry", "path", path, "error", err)
+		return nil
+	}
+	return &e
+}
+
+// store writes e to path. The cache is an optimization, so failures are only logged.
+func (c *Cache) store(path string, e *entry) {
+	if err := writeEntry(path, e); err != nil {
+		slog.Debug("failed to write cache entry", "path", path, "error", err)
+	}
+}
+
+func writeEntry(path string, e *entry) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	// Concurrent runs must not read partially written entries.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(content); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), path))
+}
+
+// response creates the response served for e.
+func (e *entry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(StatusHeader, status)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// Transport returns an http.RoundTripper serving cacheable requests from the cache and making the others, and
+// those of stale or missing entries, with base.
+func (c *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	return transport{cache: c, base: base}
+}
+
+type transport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := t.cache.path(req)
+	if !ok {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned as is to the HTTP client.
+	}
+	cached := t.cache.load(path)
+	if cached != nil && t.cache.now().Sub(cached.StoredAt) < t.cache.ttl {
+		slog.Debug("using cached API response", "url", req.URL.String())
+		return cached.response(req, StatusHit), nil
+	}
+
+	if cached != nil && cached.ETag != "" {
+		// RoundTrippers must not modify the request.
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err //nolint:wrapcheck // Errors are returned as is to the HTTP client.
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		cached.StoredAt = t.cache.now()
+		t.cache.store(path, cached)
+		revalidated := cached.response(req, StatusRevalidated)
+		// Keep the rate limit of the actual response for the quota reported at the end of the run.
+		for name, values := range resp.Header {
+			if strings.HasPrefix(name, "X-Ratelimit-") {
+				revalidated.Header[name] = values
+			}
+		}
+		return revalidated, nil
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		e := &entry{URL: req.URL.String(), ETag: resp.Header.Get("ETag"), Header: http.Header{}, Body: body, StoredAt: t.cache.now()}
+		for _, name := range cachedHeaders {
+			if v := resp.Header.Values(name); len(v) > 0 {
+				e.Header[name] = v
+			}
+		}
+		t.cache.store(path, e)
+	}
+	return resp, nil
+}
+
+// Stats describes the entries in the cache.
+type Stats struct {
+	Entries int
+	// Size is the total size of the entries in bytes.
+	Size int64
+	// Repos is the number of entries by host and owner/repo, e.g. "api.github.com/actions/checkout".
+	Repos map[string]int
+	// Oldest and Newest are the times the least and most recently stored or revalidated entries were written.
+	Oldest, Newest time.Time
+}
+
+// Stats walks the cache directory. A missing directory is an empty cache.
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Repos: map[string]int{}}
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == c.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Entries++
+		stats.Size += info.Size()
+		if rel, err := filepath.Rel(c.dir, filepath.Dir(path)); err == nil {
+			stats.Repos[filepath.ToSlash(rel)]++
+		}
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	return stats, errors.Wrapf(err, "failed to read cache directory %s", c.dir)
+}
+
+// Clear removes all entries and the directories they leave empty. cache.dir may be set to a directory used for
+// other things as well, so only files named like entries at <host>/<owner>/<repo> are removed, and never the
+// cache directory itself. A missing directory is an empty cache.
+func (c *Cache) Clear() error {
+	var dirs []string
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == c.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path == c.dir {
+			return nil
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		depth := strings.Count(filepath.ToSlash(rel), "/") + 1
+		if d.IsDir() {
+			if depth > 3 {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if depth != 4 || !d.Type().IsRegular() || !entryName.MatchString(d.Name()) {
+			return nil
+		}
+		return errors.WithStack(os.Remove(path))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to clear cache directory %s", c.dir)
+	}
+	// Children are walked after their parents, so reversing removes them first.
+	slices.Reverse(dirs)
+	for _, dir := range dirs {
+		// Directories that still contain other files are kept.
+		_ = os.Remove(dir)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI serves tags and commits with an ETag that changes with version, and records the requests it received.
+type fakeAPI struct {
+	mu       sync.Mutex
+	version  int
+	requests []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	etag := fmt.Sprintf(`W/"v%d"`, f.version)
+	if r.Header.Get("If-None-Match") == etag {
+		f.requests = append(f.requests, "304 "+r.URL.Path)
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	f.requests = append(f.requests, "200 "+r.URL.Path)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Link", `<https://api.github.com/repositories/1/tags?page=2>; rel="next"`)
+	fmt.Fprintf(w, `[{"name": "v%d"}]`, f.version)
+}
+
+func (f *fakeAPI) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func get(t *testing.T, client *http.Client, url string) (string, *http.Response) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body), resp
+}
+
+func TestTransport(t *testing.T) {
+	api := &fakeAPI{version: 1}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	c := New(t.TempDir(), time.Hour)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	client := &http.Client{Transport: c.Transport(http.DefaultTransport)}
+	tags := server.URL + "/api/v3/repos/actions/checkout/tags"
+
+	body, resp := get(t, client, tags)
+	assert.JSONEq(t, `[{"name": "v1"}]`, body)
+	assert.Empty(t, resp.Header.Get(StatusHeader))
+	assert.Equal(t, []string{"200 /api/v3/repos/actions/checkout/tags"}, api.take())
+
+	// Fresh entries are served without a request, with the pagination of the response.
+	body, resp = get(t, client, tags)
+	assert.JSONEq(t, `[{"name": "v1"}]`, body)
+	assert.Equal(t, StatusHit, resp.Header.Get(StatusHeader))
+	assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+	assert.Empty(t, api.take())
+
+	// Stale entries are revalidated.
+	now = now.Add(2 * time.Hour)
+	body, resp = get(t, client, tags)
+	assert.JSONEq(t, `[{"name": "v1"}]`, body)
+	assert.Equal(t, StatusRevalidated, resp.Header.Get(StatusHeader))
+	assert.Equal(t, "4999", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, []string{"304 /api/v3/repos/actions/checkout/tags"}, api.take())
+
+	// Revalidation renews the entry.
+	_, resp = get(t, client, tags)
+	assert.Equal(t, StatusHit, resp.Header.Get(StatusHeader))
+
+	now = now.Add(2 * time.Hour)
+	api.version = 2
+	body, _ = get(t, client, tags)
+	assert.JSONEq(t, `[{"name": "v2"}]`, body)
+	assert.Equal(t, []string{"200 /api/v3/repos/actions/checkout/tags"}, api.take())
+
+	// Other requests are not cached.
+	for range 2 {
+		get(t, client, server.URL+"/api/v3/repos/actions/checkout/contents/action.yml")
+	}
+	assert.Len(t, api.take(), 2)
+
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Positive(t, stats.Size)
+	host := server.Listener.Addr().String()
+	assert.Len(t, stats.Repos, 1)
+	for repo, entries := range stats.Repos {
+		assert.Contains(t, repo, "/actions/checkout")
+		assert.NotContains(t, repo, host, "colons of ports are replaced in directory names")
+		assert.Equal(t, 1, entries)
+	}
+
+	require.NoError(t, c.Clear())
+	stats, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestTransport_accept(t *testing.T) {
+	api := &fakeAPI{version: 1}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	c := New(t.TempDir(), time.Hour)
+	client := &http.Client{Transport: c.Transport(http.DefaultTransport)}
+	commit := server.URL + "/repos/actions/checkout/commits/main"
+
+	// The SHA media type and JSON responses of the same URL are cached separately.
+	for _, accept := range []string{"application/vnd.github.v3.sha", "application/vnd.github.v3.sha", "application/json"} {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, commit, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	assert.Len(t, api.take(), 2)
+}
+
+func TestTransport_authorization(t *testing.T) {
+	api := &fakeAPI{version: 1}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	c := New(t.TempDir(), time.Hour)
+	client := &http.Client{Transport: c.Transport(http.DefaultTransport)}
+	tags := server.URL + "/repos/org/private-action/tags"
+
+	// Responses are not shared between credentials, or with anonymous requests.
+	for _, authorization := range []string{"Bearer token-a", "Bearer token-a", "Bearer token-b", ""} {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, tags, nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	assert.Len(t, api.take(), 3)
+
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Entries)
+}
+
+func TestPath(t *testing.T) {
+	c := New("/cache", time.Hour)
+	for url, want := range map[string]bool{
+		"https://api.github.com/repos/actions/checkout/tags?per_page=100":   true,
+		"https://ghe.example.com/api/v3/repos/org/repo/commits/main":        true,
+		"https://api.github.com/repos/actions/checkout/contents/action.yml": false,
+		"https://api.github.com/repos/../checkout/tags":                     false,
+		"https://api.github.com/orgs/actions/repos":                         false,
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		_, ok := c.path(req)
+		assert.Equal(t, want, ok, url)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.github.com/repos/actions/checkout/tags", nil)
+	require.NoError(t, err)
+	_, ok := c.path(req)
+	assert.False(t, ok)
+}
+
+func TestClear(t *testing.T) {
+	dir := t.TempDir()
+	entry := strings.Repeat("0", 64) + ".json"
+	write := func(rel string) string {
+		path := filepath.Join(dir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte("{}"), 0o600))
+		return path
+	}
+	entries := []string{
+		write("api.github.com/actions/checkout/" + entry),
+		write("api.github.com/actions/cache/" + entry),
+	}
+	// Files that are not entries, e.g. with cache.dir set to a directory used for other things.
+	others := []string{
+		write("settings.json"),
+		write("api.github.com/actions/checkout/notes.txt"),
+		write("projects/app/config/settings.json"),
+		write("projects/app/config/nested/" + entry),
+	}
+
+	c := New(dir, time.Hour)
+	require.NoError(t, c.Clear())
+	for _, path := range entries {
+		assert.NoFileExists(t, path)
+	}
+	for _, path := range others {
+		assert.FileExists(t, path)
+	}
+	assert.NoDirExists(t, filepath.Join(dir, "api.github.com/actions/cache"))
+	assert.DirExists(t, filepath.Join(dir, "api.github.com/actions/checkout"))
+
+	require.NoError(t, os.Remove(others[1]))
+	require.NoError(t, c.Clear())
+	assert.NoDirExists(t, filepath.Join(dir, "api.github.com"))
+	assert.DirExists(t, dir)
+
+	require.NoError(t, New(filepath.Join(dir, "missing"), time.Hour).Clear())
+}
+
+func TestStats_missingDir(t *testing.T) {
+	stats, err := New(t.TempDir()+"/missing", time.Hour).Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+// Config is the typed representation of a gha-fix.yaml file.
+//
+// Pointer fields distinguish "not set in the file" from zero values so that invalid values such as
+// `timeout-value: 0` can be reported instead of silently falling back to defaults.
+type Config struct {
+	// Extends lists config files to inherit from. Relative paths are resolved from the directory of the
+	// file that declares them. See Merge for how inherited values are combined.
+	Extends []string `yaml:"extends,omitempty"`
+	// Root stops the discovery of config files in parent directories and the user-level config.
+	Root bool `yaml:"root,omitempty"`
+
+	LogLevel   string        `yaml:"log-level,omitempty"`
+	LogFormat  string        `yaml:"log-format,omitempty"`
+	IgnoreDirs []string      `yaml:"ignore-dirs,omitempty"`
+	Baseline   string        `yaml:"baseline,omitempty"`
+	Pin        PinConfig     `yaml:"pin,omitempty"`
+	Timeout    TimeoutConfig `yaml:"timeout,omitempty"`
+	Commit     CommitConfig  `yaml:"commit,omitempty"`
+	Cache      CacheConfig   `yaml:"cache,omitempty"`
+	// Plugins configures external fixers. See PluginConfig.
+	Plugins []PluginConfig `yaml:"plugins,omitempty"`
+}
+
+// PinConfig is the `pin` section of the config file.
+type PinConfig struct {
+	GitHubToken string `yaml:"github-token,omitempty"`
+	// GitHubAPIURL is the REST API used for owners not routed by Hosts, e.g. GitHub Enterprise Server.
+	GitHubAPIURL        string   `yaml:"github-api-url,omitempty"`
+	IgnoreOwners        []string `yaml:"ignore-owners,omitempty"`
+	IgnoreRepos         []string `yaml:"ignore-repos,omitempty"`
+	StrictPinning202508 *bool    `yaml:"strict-pinning-202508,omitempty"`
+	// WaitForRateLimit waits for GitHub API rate limits to reset however long it takes instead of failing.
+	WaitForRateLimit bool `yaml:"wait-for-rate-limit,omitempty"`
+	// Hosts routes the owners of actions to other GitHub APIs. See HostConfig.
+	Hosts []HostConfig `yaml:"hosts,omitempty"`
+	// AppID and AppPrivateKeyFile authenticate as a GitHub App instead of GitHubToken.
+	AppID             int64  `yaml:"app-id,omitempty"`
+	AppPrivateKeyFile string `yaml:"app-private-key-file,omitempty"`
+	// InstallationID is the GitHub App installation to use for all owners. Looked up per owner if not set.
+	InstallationID int64 `yaml:"installation-id,omitempty"`
+	// Credentials choose the token for the owners of actions on the API of GitHubAPIURL. See CredentialConfig.
+	Credentials []CredentialConfig `yaml:"credentials,omitempty"`
+	// CredentialHelper is a shell command printing a token, tried when no other token source has one.
+	CredentialHelper string `yaml:"credential-helper,omitempty"`
+	// Lockfile records the versions actions were resolved to, reused by later runs. Defaults to gha-fix.lock.
+	Lockfile string `yaml:"lockfile,omitempty"`
+	NoLock   bool   `yaml:"no-lock,omitempty"`
+	// MirrorDir resolves actions from local git mirrors in this directory instead of the GitHub API.
+	MirrorDir string `yaml:"mirror-dir,omitempty"`
+	// MirrorTemplate is the path of the mirror of a repository in MirrorDir with {owner} and {repo} replaced.
+	// Defaults to {owner}/{repo}.git.
+	MirrorTemplate string `yaml:"mirror-template,omitempty"`
+}
+
+// HostConfig is an entry of `pin.hosts`: a GitHub API that actions of the listed owners are resolved against, e.g.
+// github.com for actions/* when pin.github-api-url is GitHub Enterprise Server.
+type HostConfig struct {
+	APIURL string `yaml:"api-url"`
+	// Owners are glob patterns of owner names, e.g. "actions" or "my-team-*". The first matching host is used.
+	Owners []string `yaml:"owners"`
+	// TokenEnv is the environment variable holding the token for this host. Calls are unauthenticated without it.
+	TokenEnv string `yaml:"token-env,omitempty"`
+}
+
+// CredentialConfig is an entry of `pin.credentials`: the token used for actions of the listed owners, e.g. private
+// actions of an organization no other token can read. Exactly one of TokenEnv, TokenFile and TokenCommand is set.
+type CredentialConfig struct {
+	// Owners are glob patterns of owner names like HostConfig.Owners. The first matching credential is used.
+	Owners   []string `yaml:"owners"`
+	TokenEnv string   `yaml:"token-env,omitempty"`
+	// TokenFile is a file containing the token. Surrounding whitespace is ignored.
+	TokenFile string `yaml:"token-file,omitempty"`
+	// TokenCommand is a shell command printing the token on stdout.
+	TokenCommand string `yaml:"token-command,omitempty"`
+}
+
+// TimeoutConfig is the `timeout` section of the config file.
+type TimeoutConfig struct {
+	TimeoutValue *uint64 `yaml:"timeout-value,omitempty"`
+}
+
+// CommitConfig is the `commit` section of the config file, used by the --commit option of the fixers.
+type CommitConfig struct {
+	// Trailer is appended to generated commit messages, e.g. "Generated-by: gha-fix".
+	Trailer string `yaml:"trailer,omitempty"`
+}
+
+// CacheConfig is the `cache` section of the config file: the on-disk cache of GitHub API responses.
+type CacheConfig struct {
+	// Dir defaults to $XDG_CACHE_HOME/gha-fix.
+	Dir string `yaml:"dir,omitempty"`
+	// TTL is how long responses are used without revalidation, as a Go duration, e.g. "24h".
+	TTL      string `yaml:"ttl,omitempty"`
+	Disabled bool   `yaml:"disabled,omitempty"`
+}
+
+// PluginConfig is an entry of `plugins`: an external fixer run through the plugin protocol.
+type PluginConfig struct {
+	// Name identifies the plugin, e.g. in `gha-fix plugin run <name>` and as the rule of its findings.
+	Name string `yaml:"name"`
+	// Command is the executable: a name looked up on PATH, or a path relative to the directory of the config file.
+	// Defaults to gha-fix-<name> on PATH.
+	Command string `yaml:"command,omitempty"`
+	// Options are passed to the plugin as is.
+	Options map[string]any `yaml:"options,omitempty"`
+}
+
+// PluginNamePattern matches valid plugin names, which are also the suffix of plugin executables.
+var PluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ReservedPluginNames are the rules of the built-in fixers. Plugins cannot use them, as their findings and changes
+// would be attributed to the built-in fixers in baselines, commit messages and reports.
+var ReservedPluginNames = []string{pin.RuleName, timeout.RuleName}
+
+// trailerPattern matches a git trailer line: a token, a colon and a value.
+var trailerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*: \S.*$`)
+
+// envNamePattern matches environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var validLogLevels = []string{"debug", "info", "warn", "error"}
+
+// Issue is a single problem found in a config file.
+type Issue struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// ValidationError reports all issues found in a config file with their source positions.
+type ValidationError struct {
+	Path   string
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", e.Path, issue.Line, issue.Column, issue.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Load reads the config file at path, decodes it strictly and validates its values.
+//
+// Unknown keys, type mismatches and invalid values are reported as *ValidationError.
+func Load(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.WithStack(err)
+	}
+	return Parse(path, content)
+}
+
+// Parse decodes and validates config content. path is only used for error messages.
+func Parse(path string, content []byte) (Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalWithOptions(content, &cfg, yaml.Strict()); err != nil {
+		var yamlErr yaml.Error
+		if errors.As(err, &yamlErr) {
+			issue := Issue{Message: yamlErr.GetMessage()}
+			if tk := yamlErr.GetToken(); tk != nil && tk.Position != nil {
+				issue.Line = tk.Position.Line
+				issue.Column = tk.Position.Column
+			}
+			return Config{}, &ValidationError{Path: path, Issues: []Issue{issue}}
+		}
+		return Config{}, errors.Wrapf(err, "failed to decode config file: %s", path)
+	}
+
+	file, err := parser.ParseBytes(content, 0)
+	if err != nil {
+		return Config{}, errors.Wrapf(err, "failed to parse config file: %s", path)
+	}
+
+	if issues := cfg.validate(file); len(issues) > 0 {
+		return Config{}, &ValidationError{Path: path, Issues: issues}
+	}
+	return cfg, nil
+}
+
+func (c Config) validate(file *ast.File) []Issue {
+	var issues []Issue
+	add := func(yamlPath, format string, args ...any) {
+		line, column := position(file, yamlPath)
+		issues = append(issues, Issue{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, ext := range c.Extends {
+		if ext == "" {
+			add(fmt.Sprintf("$.extends[%d]", i), "extends entry must not be empty")
+		}
+	}
+	if c.LogLevel != "" && !slices.Contains(validLogLevels, c.LogLevel) {
+		add("$.log-level", "invalid log-level %q: must be one of %s", c.LogLevel, strings.Join(validLogLevels, ", "))
+	}
+	if c.LogFormat != "" && !slices.Contains(logging.Formats, c.LogFormat) {
+		add("$.log-format", "invalid log-format %q: must be one of %s", c.LogFormat, strings.Join(logging.Formats, ", "))
+	}
+	for i, dir := range c.IgnoreDirs {
+		if dir == "" {
+			add(fmt.Sprintf("$.ignore-dirs[%d]", i), "ignore-dirs entry must not be empty")
+		}
+	}
+	for i, owner := range c.Pin.IgnoreOwners {
+		if owner == "" || strings.Contains(owner, "/") {
+			add(fmt.Sprintf("$.pin.ignore-owners[%d]", i), "invalid ignore-owners entry %q: must be an owner name without '/'", owner)
+		}
+	}
+	for i, repo := range c.Pin.IgnoreRepos {
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			add(fmt.Sprintf("$.pin.ignore-repos[%d]", i), "invalid ignore-repos entry %q: must be in owner/repo format", repo)
+		}
+	}
+	if c.Pin.GitHubAPIURL != "" && !validAPIURL(c.Pin.GitHubAPIURL) {
+		add("$.pin.github-api-url", "invalid github-api-url %q: must be an http or https URL", c.Pin.GitHubAPIURL)
+	}
+	for i, host := range c.Pin.Hosts {
+		if !validAPIURL(host.APIURL) {
+			add(fmt.Sprintf("$.pin.hosts[%d].api-url", i), "invalid api-url %q: must be an http or https URL", host.APIURL)
+		}
+		validateOwners(fmt.Sprintf("$.pin.hosts[%d]", i), host.Owners, add)
+		if host.TokenEnv != "" && !envNamePattern.MatchString(host.TokenEnv) {
+			add(fmt.Sprintf("$.pin.hosts[%d].token-env", i), "invalid token-env %q: must be an environment variable name", host.TokenEnv)
+		}
+	}
+	for i, cred := range c.Pin.Credentials {
+		entry := fmt.Sprintf("$.pin.credentials[%d]", i)
+		validateOwners(entry, cred.Owners, add)
+		sources := 0
+		for _, source := range []string{cred.TokenEnv, cred.TokenFile, cred.TokenCommand} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			add(entry, "exactly one of token-env, token-file and token-command must be set")
+		}
+		if cred.TokenEnv != "" && !envNamePattern.MatchString(cred.TokenEnv) {
+			add(entry+".token-env", "invalid token-env %q: must be an environment variable name", cred.TokenEnv)
+		}
+	}
+	if c.Pin.AppID < 0 {
+		add("$.pin.app-id", "app-id must be greater than 0")
+	}
+	if c.Pin.AppID != 0 && c.Pin.AppPrivateKeyFile == "" {
+		add("$.pin.app-id", "app-id requires app-private-key-file")
+	}
+	if c.Pin.AppPrivateKeyFile != "" && c.Pin.AppID == 0 {
+		add("$.pin.app-private-key-file", "app-private-key-file requires app-id")
+	}
+	if c.Pin.InstallationID < 0 {
+		add("$.pin.installation-id", "installation-id must be greater than 0")
+	}
+	if c.Pin.InstallationID != 0 && c.Pin.AppID == 0 {
+		add("$.pin.installation-id", "installation-id requires app-id")
+	}
+	if c.Pin.MirrorTemplate != "" {
+		if !strings.Contains(c.Pin.MirrorTemplate, "{repo}") {
+			add("$.pin.mirror-template", "invalid mirror-template %q: must contain {repo}", c.Pin.MirrorTemplate)
+		}
+		if c.Pin.MirrorDir == "" {
+			add("$.pin.mirror-template", "mirror-template requires mirror-dir")
+		}
+	}
+	if c.Timeout.TimeoutValue != nil && *c.Timeout.TimeoutValue == 0 {
+		add("$.timeout.timeout-value", "timeout-value must be greater than 0")
+	}
+	if c.Commit.Trailer != "" && !trailerPattern.MatchString(c.Commit.Trailer) {
+		add("$.commit.trailer", "invalid trailer %q: must be in \"Key: value\" format", c.Commit.Trailer)
+	}
+	if c.Cache.TTL != "" {
+		if ttl, err := time.ParseDuration(c.Cache.TTL); err != nil || ttl < 0 {
+			add("$.cache.ttl", "invalid ttl %q: must be a non-negative duration such as 30m or 24h", c.Cache.TTL)
+		}
+	}
+	for i, plugin := range c.Plugins {
+		if !PluginNamePattern.MatchString(plugin.Name) {
+			add(fmt.Sprintf("$.plugins[%d].name", i), "invalid plugin name %q: must consist of lower-case letters, digits, '-' and '_'", plugin.Name)
+		} else if slices.Contains(ReservedPluginNames, plugin.Name) {
+			add(fmt.Sprintf("$.plugins[%d].name", i), "invalid plugin name %q: reserved for the built-in fixer", plugin.Name)
+		}
+	}
+
+	return issues
+}
+
+// trustedKeys returns the YAML paths of the values in c that only trusted config files may set: commands that are
+// run, the APIs tokens are sent to, and the environment variables and files tokens are read from.
+func (c Config) trustedKeys() []string {
+	var keys []string
+	if c.Pin.GitHubAPIURL != "" {
+		keys = append(keys, "$.pin.github-api-url")
+	}
+	for i, host := range c.Pin.Hosts {
+		if host.APIURL != "" {
+			keys = append(keys, fmt.Sprintf("$.pin.hosts[%d].api-url", i))
+		}
+		if host.TokenEnv != "" {
+			keys = append(keys, fmt.Sprintf("$.pin.hosts[%d].token-env", i))
+		}
+	}
+	if c.Pin.CredentialHelper != "" {
+		keys = append(keys, "$.pin.credential-helper")
+	}
+	for i, plugin := range c.Plugins {
+		if plugin.Command != "" {
+			keys = append(keys, fmt.Sprintf("$.plugins[%d].command", i))
+		}
+	}
+	for i, cred := range c.Pin.Credentials {
+		for _, source := range []struct{ key, value string }{
+			{"token-env", cred.TokenEnv},
+			{"token-file", cred.TokenFile},
+			{"token-command", cred.TokenCommand},
+		} {
+			if source.value != "" {
+				keys = append(keys, fmt.Sprintf("$.pin.credentials[%d].%s", i, source.key))
+			}
+		}
+	}
+	return keys
+}
+
+// validateOwners reports an empty list of owners or invalid owner patterns of the entry at yamlPath.
+func validateOwners(yamlPath string, owners []string, add func(yamlPath, format string, args ...any)) {
+	if len(owners) == 0 {
+		add(yamlPath, "owners must not be empty")
+	}
+	for i, owner := range owners {
+		if _, err := path.Match(owner, ""); err != nil || owner == "" || strings.Contains(owner, "/") {
+			add(fmt.Sprintf("%s.owners[%d]", yamlPath, i), "invalid owners entry %q: must be an owner name or glob pattern without '/'", owner)
+		}
+	}
+}
+
+// validAPIURL reports whether s is an absolute http or https URL.
+func validAPIURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// position returns the line and column of the node at yamlPath, or zeros if it cannot be located.
+func position(file *ast.File, yamlPath string) (int, int) {
+	p, err := yaml.PathString(yamlPath)
+	if err != nil {
+		return 0, 0
+	}
+	node, err := p.FilterFile(file)
+	if err != nil || node == nil {
+		return 0, 0
+	}
+	tk := node.GetToken()
+	if tk == nil || tk.Position == nil {
+		return 0, 0
+	}
+	return tk.Position.Line, tk.Position.Column
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_ExampleConfig(t *testing.T) {
+	cfg, err := Load("../../testdata/example-config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".git", "node_modules", "vendor"}, cfg.IgnoreDirs)
+	assert.Equal(t, []string{"Finatext"}, cfg.Pin.IgnoreOwners)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantIssues []Issue
+	}{
+		{
+			name: "valid config",
+			input: `log-level: debug
+log-format: json
+ignore-dirs:
+  - node_modules
+pin:
+  ignore-owners:
+    - Finatext
+  ignore-repos:
+    - actions/checkout
+  strict-pinning-202508: true
+  github-api-url: https://ghe.example.com/api/v3/
+  hosts:
+    - api-url: https://api.github.com/
+      owners: [actions, docker-*]
+      token-env: GITHUB_COM_TOKEN
+  app-id: 12345
+  app-private-key-file: /etc/gha-fix/app.pem
+  installation-id: 678
+timeout:
+  timeout-value: 10
+commit:
+  trailer: "Signed-off-by: bot <bot@example.com>"
+cache:
+  dir: /tmp/gha-fix-cache
+  ttl: 24h
+plugins:
+  - name: company-rules
+    options:
+      allowed-runners: [self-hosted]
+  - name: lint
+    command: ./tools/gha-fix-lint
+`,
+		},
+		{
+			name: "unknown key",
+			input: `pin:
+  ignore-owner:
+    - Finatext
+`,
+			wantIssues: []Issue{{Line: 2, Column: 3, Message: `unknown field "ignore-owner"`}},
+		},
+		{
+			name: "unknown top-level key",
+			input: `ignore-dir:
+  - vendor
+`,
+			wantIssues: []Issue{{Line: 1, Column: 1, Message: `unknown field "ignore-dir"`}},
+		},
+		{
+			name: "invalid timeout value",
+			input: `timeout:
+  timeout-value: 0
+`,
+			wantIssues: []Issue{{Line: 2, Column: 18, Message: "timeout-value must be greater than 0"}},
+		},
+		{
+			name: "invalid commit trailer",
+			input: `commit:
+  trailer: generated by gha-fix
+`,
+			wantIssues: []Issue{{Line: 2, Column: 12, Message: `invalid trailer "generated by gha-fix": must be in "Key: value" format`}},
+		},
+		{
+			name: "invalid plugin name",
+			input: `plugins:
+  - name: Company Rules
+  - name: pin
+`,
+			wantIssues: []Issue{
+				{Line: 2, Column: 11, Message: `invalid plugin name "Company Rules": must consist of lower-case letters, digits, '-' and '_'`},
+				{Line: 3, Column: 11, Message: `invalid plugin name "pin": reserved for the built-in fixer`},
+			},
+		},
+		{
+			name: "invalid log format",
+			input: `log-format: yaml
+`,
+			wantIssues: []Issue{{Line: 1, Column: 13, Message: `invalid log-format "yaml": must be one of console, text, json`}},
+		},
+		{
+			name: "invalid log level and repo format",
+			input: `log-level: verbose
+pin:
+  ignore-repos:
+    - actions/checkout
+    - checkout
+`,
+			wantIssues: []Issue{
+				{Line: 1, Column: 12, Message: `invalid log-level "verbose": must be one of debug, info, warn, error`},
+				{Line: 5, Column: 7, Message: `invalid ignore-repos entry "checkout": must be in owner/repo format`},
+			},
+		},
+		{
+			name: "incomplete GitHub App",
+			input: `pin:
+  app-private-key-file: app.pem
+  installation-id: 678
+`,
+			wantIssues: []Issue{
+				{Line: 2, Column: 25, Message: "app-private-key-file requires app-id"},
+				{Line: 3, Column: 20, Message: "installation-id requires app-id"},
+			},
+		},
+		{
+			name: "invalid hosts",
+			input: `pin:
+  github-api-url: ghe.example.com
+  hosts:
+    - api-url: https://api.github.com/
+      owners: [actions, "[a"]
+      token-env: GITHUB-TOKEN
+    - api-url: ftp://example.com
+`,
+			wantIssues: []Issue{
+				{Line: 2, Column: 19, Message: `invalid github-api-url "ghe.example.com": must be an http or https URL`},
+				{Line: 5, Column: 25, Message: `invalid owners entry "[a": must be an owner name or glob pattern without '/'`},
+				{Line: 6, Column: 18, Message: `invalid token-env "GITHUB-TOKEN": must be an environment variable name`},
+				{Line: 7, Column: 16, Message: `invalid api-url "ftp://example.com": must be an http or https URL`},
+				{Line: 7, Column: 14, Message: "owners must not be empty"},
+			},
+		},
+		{
+			name: "invalid cache ttl",
+			input: `cache:
+  ttl: 1 day
+`,
+			wantIssues: []Issue{
+				{Line: 2, Column: 8, Message: `invalid ttl "1 day": must be a non-negative duration such as 30m or 24h`},
+			},
+		},
+		{
+			name: "invalid mirror-template",
+			input: `pin:
+  mirror-template: "{owner}.git"
+`,
+			wantIssues: []Issue{
+				{Line: 2, Column: 20, Message: `invalid mirror-template "{owner}.git": must contain {repo}`},
+				{Line: 2, Column: 20, Message: "mirror-template requires mirror-dir"},
+			},
+		},
+		{
+			name: "invalid credentials",
+			input: `pin:
+  credentials:
+    - owners: [org-a, "org-b/*"]
+      token-env: ORG_A_TOKEN
+    - owners: [org-c]
+      token-env: ORG-C-TOKEN
+    - owners: [org-d]
+      token-file: token.txt
+      token-command: pass show org-d
+    - owners: []
+`,
+			wantIssues: []Issue{
+				{Line: 3, Column: 23, Message: `invalid owners entry "org-b/*": must be an owner name or glob pattern without '/'`},
+				{Line: 6, Column: 18, Message: `invalid token-env "ORG-C-TOKEN": must be an environment variable name`},
+				{Line: 7, Column: 13, Message: "exactly one of token-env, token-file and token-command must be set"},
+				{Line: 10, Column: 13, Message: "owners must not be empty"},
+				{Line: 10, Column: 13, Message: "exactly one of token-env, token-file and token-command must be set"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("gha-fix.yaml", []byte(tt.input))
+			if len(tt.wantIssues) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "expected ValidationError, got %v", err)
+			assert.Equal(t, "gha-fix.yaml", validationErr.Path)
+			assert.Equal(t, tt.wantIssues, validationErr.Issues)
+		})
+	}
+}
+
+func TestParse_TypeMismatch(t *testing.T) {
+	_, err := Parse("gha-fix.yaml", []byte(`timeout:
+  timeout-value: ten
+`))
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected ValidationError, got %v", err)
+	require.Len(t, validationErr.Issues, 1)
+	assert.Equal(t, 2, validationErr.Issues[0].Line)
+	assert.Contains(t, validationErr.Error(), "gha-fix.yaml:2:")
+}
@@ -0,0 +1,349 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// FileNames are the config file names looked up in each directory during discovery.
+var FileNames = []string{"gha-fix.yaml", "gha-fix.yml"}
+
+// Loaded is the result of loading and merging one or more config files.
+type Loaded struct {
+	Config Config
+	// Files are the loaded files, including extended ones, in order of increasing precedence.
+	Files []string
+	// Sources maps a dotted key (e.g. "pin.ignore-owners") to the files that contributed its value.
+	Sources map[string][]string
+}
+
+// ErrExtendsCycle is returned when config files extend each other in a cycle.
+var ErrExtendsCycle = errors.New("config files extend each other in a cycle")
+
+// UserConfigPath returns the path of the user-level config file: $XDG_CONFIG_HOME/gha-fix/config.yaml,
+// falling back to ~/.config/gha-fix/config.yaml. Returns an empty string if neither can be determined.
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gha-fix", "config.yaml")
+}
+
+// Discover returns existing config files that apply to startDir in order of increasing precedence:
+//
+//  1. the user-level config (see UserConfigPath)
+//  2. gha-fix.yaml in each directory from the git root (the nearest parent with a .git entry)
+//     down to startDir; without a git root, only startDir is searched
+//
+// A file with `root: true` ends the chain: files with lower precedence are not returned.
+func Discover(startDir string) ([]string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Directories to search, nearest first.
+	dirs := []string{dir}
+	if gitRoot := findGitRoot(dir); gitRoot != "" {
+		for d := dir; d != gitRoot; {
+			d = filepath.Dir(d)
+			dirs = append(dirs, d)
+		}
+	}
+
+	var files []string
+	for _, d := range dirs {
+		path := findConfigFile(d)
+		if path == "" {
+			continue
+		}
+		files = append(files, path)
+		if declaresRoot(path) {
+			slices.Reverse(files)
+			return files, nil
+		}
+	}
+
+	if userPath := UserConfigPath(); userPath != "" && exists(userPath) && !slices.Contains(files, userPath) {
+		files = append(files, userPath)
+	}
+	slices.Reverse(files)
+	return files, nil
+}
+
+// findGitRoot returns the nearest directory from dir upwards that contains a .git entry, or an empty string.
+func findGitRoot(dir string) string {
+	for {
+		if exists(filepath.Join(dir, ".git")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func findConfigFile(dir string) string {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if exists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// declaresRoot peeks at the root key without validating the rest of the file.
+// Broken files are reported with positions when they are loaded by LoadFiles.
+func declaresRoot(path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var peek struct {
+		Root bool `yaml:"root"`
+	}
+	return yaml.Unmarshal(content, &peek) == nil && peek.Root
+}
+
+// LoadFiles loads the given files and everything they extend, then merges them with Merge.
+// files must be ordered by increasing precedence, as returned by Discover.
+func LoadFiles(files []string) (Loaded, error) {
+	return loadFiles(files, func(string) bool { return true })
+}
+
+// LoadDiscovered is LoadFiles for files returned by Discover. Config files found in the repository, and the files
+// they extend, must not set values that are run as commands (pin.credential-helper and
+// pin.credentials[].token-command and plugins[].command), choose the APIs tokens are sent to (pin.github-api-url and pin.hosts[].api-url)
+// or the environment variables and files tokens are read from (token-env and token-file): running gha-fix in an
+// untrusted checkout, e.g. of a pull request from a fork, would otherwise run commands of that checkout or send the
+// user's secrets to a host it chose. Only the user-level config and config files given explicitly may set them.
+func LoadDiscovered(files []string) (Loaded, error) {
+	userPath := UserConfigPath()
+	return loadFiles(files, func(path string) bool { return path == userPath })
+}
+
+// loadFiles loads files like LoadFiles. Files for which trusted returns false, and the files they extend, are
+// rejected if they set values that only trusted files may set, see LoadDiscovered.
+func loadFiles(files []string, trusted func(path string) bool) (Loaded, error) {
+	var result Loaded
+	for _, path := range files {
+		loaded, err := loadWithExtends(path, nil, trusted(path))
+		if err != nil {
+			return Loaded{}, err
+		}
+		result = Merge(result, loaded)
+	}
+	return result, nil
+}
+
+func loadWithExtends(path string, stack []string, trusted bool) (Loaded, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Loaded{}, errors.WithStack(err)
+	}
+	if slices.Contains(stack, absPath) {
+		return Loaded{}, errors.Wrapf(ErrExtendsCycle, "%s", strings.Join(append(stack, absPath), " -> "))
+	}
+	stack = append(stack, absPath)
+
+	cfg, err := Load(path)
+	if err != nil {
+		return Loaded{}, err
+	}
+	if !trusted {
+		if err := rejectTrustedKeys(path, cfg); err != nil {
+			return Loaded{}, err
+		}
+	}
+	// Like extends, relative plugin commands are resolved from the directory of the file declaring them.
+	for i, plugin := range cfg.Plugins {
+		hasDir := strings.ContainsRune(plugin.Command, filepath.Separator) || strings.ContainsRune(plugin.Command, '/')
+		if hasDir && !filepath.IsAbs(plugin.Command) {
+			cfg.Plugins[i].Command = filepath.Join(filepath.Dir(absPath), plugin.Command)
+		}
+	}
+
+	var result Loaded
+	for _, ext := range cfg.Extends {
+		if !filepath.IsAbs(ext) {
+			ext = filepath.Join(filepath.Dir(path), ext)
+		}
+		base, err := loadWithExtends(ext, stack, trusted)
+		if err != nil {
+			return Loaded{}, errors.Wrapf(err, "failed to load config extended by %s", path)
+		}
+		result = Merge(result, base)
+	}
+
+	own := Loaded{
+		Config:  cfg,
+		Files:   []string{path},
+		Sources: map[string][]string{},
+	}
+	collectSources(reflect.ValueOf(cfg), "", path, own.Sources)
+	return Merge(result, own), nil
+}
+
+// rejectTrustedKeys reports the values of cfg, loaded from path, that only trusted config files may set.
+func rejectTrustedKeys(path string, cfg Config) error {
+	keys := cfg.trustedKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	file, err := parser.ParseBytes(content, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse config file: %s", path)
+	}
+	issues := make([]Issue, 0, len(keys))
+	for _, yamlPath := range keys {
+		line, column := position(file, yamlPath)
+		name := yamlPath[strings.LastIndex(yamlPath, ".")+1:]
+		issues = append(issues, Issue{
+			Line:    line,
+			Column:  column,
+			Message: fmt.Sprintf("%s is only allowed in the user-level config or a config file given with --config", name),
+		})
+	}
+	return &ValidationError{Path: path, Issues: issues}
+}
+
+// Merge merges override into base and returns the result. The semantics are:
+//
+//   - scalars (strings, numbers, booleans): a value set in override replaces the one in base
+//   - lists (e.g. ignore-owners, ignore-dirs): entries of override are appended to base, skipping duplicates,
+//     so inherited entries can be extended but not removed. Use `root: true` to stop inheriting from
+//     discovered files.
+//   - maps: keys of override replace the same keys in base
+//
+// `extends` and `root` only affect loading and are cleared in the result.
+func Merge(base, override Loaded) Loaded {
+	result := Loaded{
+		Config:  base.Config,
+		Files:   append(slices.Clone(base.Files), override.Files...),
+		Sources: map[string][]string{},
+	}
+	for k, v := range base.Sources {
+		result.Sources[k] = slices.Clone(v)
+	}
+
+	mergeValue(reflect.ValueOf(&result.Config).Elem(), reflect.ValueOf(override.Config), "", func(key string, appendSource bool) {
+		if appendSource {
+			result.Sources[key] = append(result.Sources[key], override.Sources[key]...)
+		} else {
+			result.Sources[key] = slices.Clone(override.Sources[key])
+		}
+	})
+
+	result.Config.Extends = nil
+	result.Config.Root = false
+	return result
+}
+
+func mergeValue(dst, src reflect.Value, key string, record func(key string, appendSource bool)) {
+	switch {
+	case src.Kind() == reflect.Struct:
+		for i := range src.NumField() {
+			mergeValue(dst.Field(i), src.Field(i), joinKey(key, src.Type().Field(i)), record)
+		}
+	case src.Kind() == reflect.Slice:
+		if src.Len() == 0 {
+			return
+		}
+		// Copy first so that the base config is never modified through a shared backing array.
+		merged := reflect.AppendSlice(reflect.MakeSlice(dst.Type(), 0, dst.Len()+src.Len()), dst)
+		for i := range src.Len() {
+			item := src.Index(i)
+			if !containsValue(merged, item) {
+				merged = reflect.Append(merged, item)
+			}
+		}
+		dst.Set(merged)
+		record(key, true)
+	case src.Kind() == reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		merged := reflect.MakeMap(src.Type())
+		for _, m := range []reflect.Value{dst, src} {
+			iter := m.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		dst.Set(merged)
+		record(key, true)
+	default:
+		if src.IsZero() {
+			return
+		}
+		dst.Set(src)
+		record(key, false)
+	}
+}
+
+func containsValue(slice, item reflect.Value) bool {
+	for i := range slice.Len() {
+		if reflect.DeepEqual(slice.Index(i).Interface(), item.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSources records path as the source of every key set in cfg.
+func collectSources(v reflect.Value, key, path string, sources map[string][]string) {
+	if v.Kind() == reflect.Struct {
+		for i := range v.NumField() {
+			collectSources(v.Field(i), joinKey(key, v.Type().Field(i)), path, sources)
+		}
+		return
+	}
+	if !v.IsZero() {
+		sources[key] = []string{path}
+	}
+}
+
+func joinKey(prefix string, field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// YAML renders the merged config so that it can be fed to viper.
+func (l Loaded) YAML() ([]byte, error) {
+	out, err := yaml.Marshal(l.Config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
+}
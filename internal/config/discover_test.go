@@ -0,0 +1,264 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestDiscover(t *testing.T) {
+	tmp := t.TempDir()
+	xdg := filepath.Join(tmp, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	userConfig := filepath.Join(xdg, "gha-fix", "config.yaml")
+	writeFile(t, userConfig, "log-level: debug\n")
+
+	repo := filepath.Join(tmp, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0o755))
+	writeFile(t, filepath.Join(tmp, "gha-fix.yaml"), "# outside of the git repository\n")
+	writeFile(t, filepath.Join(repo, "gha-fix.yaml"), "ignore-dirs: [vendor]\n")
+	writeFile(t, filepath.Join(repo, "services/api/gha-fix.yml"), "ignore-dirs: [dist]\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "services/api/src"), 0o755))
+
+	t.Run("walks up to the git root", func(t *testing.T) {
+		files, err := Discover(filepath.Join(repo, "services/api/src"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			userConfig,
+			filepath.Join(repo, "gha-fix.yaml"),
+			filepath.Join(repo, "services/api/gha-fix.yml"),
+		}, files)
+	})
+
+	t.Run("root stops discovery", func(t *testing.T) {
+		dir := filepath.Join(repo, "tools")
+		writeFile(t, filepath.Join(dir, "gha-fix.yaml"), "root: true\n")
+
+		files, err := Discover(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "gha-fix.yaml")}, files)
+	})
+
+	t.Run("outside of a git repository", func(t *testing.T) {
+		dir := filepath.Join(tmp, "plain")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+
+		files, err := Discover(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{userConfig}, files)
+	})
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy/baseline.yaml")
+	writeFile(t, policy, `ignore-dirs:
+  - .git
+  - node_modules
+pin:
+  ignore-owners:
+    - Finatext
+  strict-pinning-202508: true
+timeout:
+  timeout-value: 10
+`)
+	repoConfig := filepath.Join(dir, "repo/gha-fix.yaml")
+	writeFile(t, repoConfig, `extends:
+  - ../policy/baseline.yaml
+ignore-dirs:
+  - vendor
+  - node_modules
+pin:
+  ignore-owners:
+    - my-team
+timeout:
+  timeout-value: 20
+`)
+	userConfig := filepath.Join(dir, "user.yaml")
+	writeFile(t, userConfig, "log-level: warn\n")
+
+	loaded, err := LoadFiles([]string{userConfig, repoConfig})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{userConfig, filepath.Join(dir, "repo/../policy/baseline.yaml"), repoConfig}, loaded.Files)
+	assert.Equal(t, "warn", loaded.Config.LogLevel)
+	assert.Equal(t, []string{".git", "node_modules", "vendor"}, loaded.Config.IgnoreDirs)
+	assert.Equal(t, []string{"Finatext", "my-team"}, loaded.Config.Pin.IgnoreOwners)
+	require.NotNil(t, loaded.Config.Pin.StrictPinning202508)
+	assert.True(t, *loaded.Config.Pin.StrictPinning202508)
+	require.NotNil(t, loaded.Config.Timeout.TimeoutValue)
+	assert.Equal(t, uint64(20), *loaded.Config.Timeout.TimeoutValue)
+	assert.Empty(t, loaded.Config.Extends)
+
+	baseline := filepath.Join(dir, "repo/../policy/baseline.yaml")
+	assert.Equal(t, []string{baseline, repoConfig}, loaded.Sources["pin.ignore-owners"])
+	assert.Equal(t, []string{repoConfig}, loaded.Sources["timeout.timeout-value"])
+	assert.Equal(t, []string{baseline}, loaded.Sources["pin.strict-pinning-202508"])
+	assert.Equal(t, []string{userConfig}, loaded.Sources["log-level"])
+}
+
+func TestLoadFiles_ExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "extends: [b.yaml]\n")
+	writeFile(t, filepath.Join(dir, "b.yaml"), "extends: [a.yaml]\n")
+
+	_, err := LoadFiles([]string{filepath.Join(dir, "a.yaml")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrExtendsCycle))
+}
+
+func TestLoadFiles_InvalidExtendedFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "base.yaml"), "pin:\n  ignore-owner: [Finatext]\n")
+	writeFile(t, filepath.Join(dir, "gha-fix.yaml"), "extends: [base.yaml]\n")
+
+	_, err := LoadFiles([]string{filepath.Join(dir, "gha-fix.yaml")})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected ValidationError, got %v", err)
+	assert.Equal(t, filepath.Join(dir, "base.yaml"), validationErr.Path)
+}
+
+func TestLoadDiscovered_TrustedKeys(t *testing.T) {
+	tmp := t.TempDir()
+	xdg := filepath.Join(tmp, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	command := `pin:
+  credentials:
+    - owners: [org-a]
+      token-command: op read op://ci/org-a/token
+`
+	userConfig := filepath.Join(xdg, "gha-fix", "config.yaml")
+	writeFile(t, userConfig, command)
+	repoConfig := filepath.Join(tmp, "repo/gha-fix.yaml")
+	writeFile(t, repoConfig, "ignore-dirs: [vendor]\n")
+
+	loaded, err := LoadDiscovered([]string{userConfig, repoConfig})
+	require.NoError(t, err)
+	assert.Equal(t, "op read op://ci/org-a/token", loaded.Config.Pin.Credentials[0].TokenCommand)
+
+	// Repository configs and the files they extend must not set them.
+	assertRejected := func(t *testing.T, path string, want ...Issue) {
+		t.Helper()
+		_, err := LoadDiscovered([]string{userConfig, repoConfig})
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr), "expected ValidationError, got %v", err)
+		assert.Equal(t, path, validationErr.Path)
+		assert.Equal(t, want, validationErr.Issues)
+	}
+	tokenCommand := Issue{
+		Line:    4,
+		Column:  22,
+		Message: "token-command is only allowed in the user-level config or a config file given with --config",
+	}
+	writeFile(t, repoConfig, command)
+	assertRejected(t, repoConfig, tokenCommand)
+
+	base := filepath.Join(tmp, "repo/base.yaml")
+	writeFile(t, base, command)
+	writeFile(t, repoConfig, "extends: [base.yaml]\n")
+	assertRejected(t, base, tokenCommand)
+
+	for name, tt := range map[string]struct {
+		content string
+		want    []Issue
+	}{
+		"credential-helper": {
+			content: "pin:\n  credential-helper: op read op://dev/github/token\n",
+			want: []Issue{{
+				Line:    2,
+				Column:  22,
+				Message: "credential-helper is only allowed in the user-level config or a config file given with --config",
+			}},
+		},
+		"API URLs": {
+			content: `pin:
+  github-api-url: https://attacker.example.com
+  hosts:
+    - api-url: https://attacker.example.com
+      owners: [actions]
+`,
+			want: []Issue{
+				{Line: 2, Column: 19, Message: "github-api-url is only allowed in the user-level config or a config file given with --config"},
+				{Line: 4, Column: 16, Message: "api-url is only allowed in the user-level config or a config file given with --config"},
+			},
+		},
+		"plugin command": {
+			content: "plugins:\n  - name: shell\n    command: ./evil\n",
+			want:    []Issue{{Line: 3, Column: 14, Message: "command is only allowed in the user-level config or a config file given with --config"}},
+		},
+		"token sources": {
+			content: `pin:
+  hosts:
+    - api-url: https://api.github.com
+      owners: [actions]
+      token-env: AWS_SECRET_ACCESS_KEY
+  credentials:
+    - owners: [org-a]
+      token-env: AWS_SECRET_ACCESS_KEY
+    - owners: [org-b]
+      token-file: ~/.ssh/id_ed25519
+`,
+			want: []Issue{
+				{Line: 3, Column: 16, Message: "api-url is only allowed in the user-level config or a config file given with --config"},
+				{Line: 5, Column: 18, Message: "token-env is only allowed in the user-level config or a config file given with --config"},
+				{Line: 8, Column: 18, Message: "token-env is only allowed in the user-level config or a config file given with --config"},
+				{Line: 10, Column: 19, Message: "token-file is only allowed in the user-level config or a config file given with --config"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			writeFile(t, repoConfig, tt.content)
+			assertRejected(t, repoConfig, tt.want...)
+
+			// Explicitly given files may.
+			_, err := LoadFiles([]string{repoConfig})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadFiles_PluginCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "repo/gha-fix.yaml")
+	writeFile(t, path, `plugins:
+  - name: shell
+    command: ./tools/gha-fix-shell
+  - name: lint
+    command: gha-fix-lint
+  - name: audit
+`)
+	loaded, err := LoadFiles([]string{path})
+	require.NoError(t, err)
+	assert.Equal(t, []PluginConfig{
+		{Name: "shell", Command: filepath.Join(dir, "repo/tools/gha-fix-shell")},
+		{Name: "lint", Command: "gha-fix-lint"},
+		{Name: "audit"},
+	}, loaded.Config.Plugins)
+}
+
+func TestLoaded_YAML(t *testing.T) {
+	value := uint64(15)
+	loaded := Loaded{Config: Config{
+		IgnoreDirs: []string{"vendor"},
+		Timeout:    TimeoutConfig{TimeoutValue: &value},
+	}}
+
+	out, err := loaded.YAML()
+	require.NoError(t, err)
+
+	// The rendered YAML only contains keys that are set, so that viper falls back to flag defaults.
+	cfg, err := Parse("merged.yaml", out)
+	require.NoError(t, err)
+	assert.Equal(t, loaded.Config, cfg)
+	assert.NotContains(t, string(out), "pin")
+}
@@ -0,0 +1,67 @@
+// Package exitcode defines the exit codes of the gha-fix command. The codes are part of the command's interface:
+// scripts branch on them, so existing values must not change.
+package exitcode
+
+import (
+	"net/url"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+const (
+	// OK means the command succeeded and there was nothing to change or report.
+	OK = 0
+	// Error is an unexpected failure, such as an unreadable or invalid workflow file.
+	Error = 1
+	// Config is an invalid config file, flag or argument, or a missing setting such as the GitHub token.
+	Config = 2
+	// Findings means check mode found violations that are not accepted by the baseline.
+	Findings = 3
+	// Changed means files were modified.
+	Changed = 4
+	// Remote is a failure to resolve actions with the GitHub API: authentication, rate limits, repositories or
+	// tags not found, and network errors. Failures to read the git mirrors used instead of the API are reported
+	// the same way.
+	Remote = 5
+)
+
+// ErrConfig marks errors caused by the configuration. Use errors.Mark(err, ErrConfig) so that FromError maps
+// them to Config.
+var ErrConfig = errors.New("configuration error")
+
+// ErrRemote marks errors of sources of action versions other than the GitHub API, e.g. git mirrors. Use
+// errors.Mark(err, ErrRemote) so that FromError maps them to Remote like API errors.
+var ErrRemote = errors.New("remote error")
+
+// FromError returns the exit code for a command that failed with err.
+func FromError(err error) int {
+	if err == nil {
+		return OK
+	}
+	if errors.Is(err, ErrConfig) {
+		return Config
+	}
+	if isRemote(err) {
+		return Remote
+	}
+	return Error
+}
+
+func isRemote(err error) bool {
+	var (
+		rateLimitErr      *github.RateLimitError
+		abuseRateLimitErr *github.AbuseRateLimitError
+		responseErr       *github.ErrorResponse
+		urlErr            *url.Error
+	)
+	return errors.Is(err, ErrRemote) ||
+		errors.As(err, &rateLimitErr) ||
+		errors.As(err, &abuseRateLimitErr) ||
+		errors.As(err, &responseErr) ||
+		errors.As(err, &urlErr) ||
+		errors.Is(err, pin.NoTagsFoundError) ||
+		errors.Is(err, pin.TagNotFoundError)
+}
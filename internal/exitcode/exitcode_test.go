@@ -0,0 +1,40 @@
+package exitcode
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+func TestFromError(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: "Not Found"}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, OK},
+		{"unexpected", errors.New("failed to read file"), Error},
+		{"config", errors.Mark(errors.New("GitHub token is required"), ErrConfig), Config},
+		{"wrapped config", errors.Wrap(errors.Mark(errors.New("invalid"), ErrConfig), "failed"), Config},
+		{"not found", errors.Wrap(notFound, "failed to list tags for foo/bar"), Remote},
+		{"rate limit", errors.Wrap(&github.RateLimitError{Message: "API rate limit exceeded"}, "failed"), Remote},
+		{"secondary rate limit", &github.AbuseRateLimitError{Message: "secondary rate limit"}, Remote},
+		{"network", errors.Wrap(&url.Error{Op: "Get", URL: "https://api.github.com", Err: errors.New("timeout")}, "failed"), Remote},
+		{"no tags", errors.Wrap(pin.NoTagsFoundError, "failed to resolve version"), Remote},
+		{"mirror", errors.Wrap(errors.Mark(errors.New("ref v4 not found in mirror"), ErrRemote), "failed"), Remote},
+		{"no matching tag", errors.Wrap(errors.Mark(errors.New("no matching tags found"), pin.TagNotFoundError), "failed"), Remote},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FromError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package githubapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+const (
+	// jwtLifetime is how long app JWTs are valid. GitHub accepts at most 10 minutes.
+	jwtLifetime = 9 * time.Minute
+	// clockSkew backdates JWTs against clocks running ahead of GitHub's.
+	clockSkew = time.Minute
+	// refreshMargin is how long before their expiry JWTs and installation tokens are replaced.
+	refreshMargin = time.Minute
+)
+
+// ErrNoInstallation is returned when the app has no installation to authenticate as.
+var ErrNoInstallation = errors.New("GitHub App has no installation")
+
+// ParsePrivateKey parses the PEM-encoded RSA private key of a GitHub App, in PKCS #1 as downloaded from GitHub, or
+// PKCS #8.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse private key")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Newf("private key is %T, not RSA", parsed)
+	}
+	return key, nil
+}
+
+// AppOptions configures an App.
+type AppOptions struct {
+	ID  int64
+	Key *rsa.PrivateKey
+	// InstallationID is the installation to authenticate as for all owners. If zero, the installation is looked
+	// up per owner, see App.RepositoryService.
+	InstallationID int64
+	// APIURL is the REST API of the app, see NewClient. Defaults to github.com.
+	APIURL string
+}
+
+// App authenticates as a GitHub App: it signs JWTs with the app's private key and exchanges them for installation
+// tokens, which are refreshed before they expire. It's safe for concurrent use.
+type App struct {
+	opts AppOptions
+	// client calls the app endpoints with JWTs.
+	client *github.Client
+	// base is the transport of installation clients.
+	base http.RoundTripper
+	now  func() time.Time
+
+	mu  sync.Mutex
+	jwt token
+	// tokens are the installation tokens by installation ID.
+	tokens map[int64]token
+	// installations are the installation IDs by owner, zero if the app is not installed for the owner.
+	installations map[string]int64
+	// fallback is the installation used for owners without one, zero until looked up.
+	fallback int64
+	// clients are the clients authenticated as installations by installation ID.
+	clients map[int64]*github.Client
+}
+
+type token struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (t token) valid(now time.Time) bool {
+	return t.value != "" && now.Add(refreshMargin).Before(t.expiresAt)
+}
+
+// NewApp creates an App calling the API at opts.APIURL.
+func NewApp(opts AppOptions) (*App, error) {
+	a := &App{
+		opts:          opts,
+		base:          Transport,
+		now:           time.Now,
+		tokens:        map[int64]token{},
+		installations: map[string]int64{},
+		clients:       map[int64]*github.Client{},
+	}
+	client, err := a.newClient(a.jwtHeader)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+// newClient creates a client for the app's API whose requests are authorized with the header returned by auth.
+func (a *App) newClient(auth func(ctx context.Context) (string, error)) (*github.Client, error) {
+	return withAPIURL(github.NewClient(&http.Client{Transport: authTransport{base: a.base, auth: auth}}), a.opts.APIURL)
+}
+
+// JWT returns a JWT authenticating as the app, valid for jwtLifetime. It's reused until shortly before it expires.
+func (a *App) JWT() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if a.jwt.valid(now) {
+		return a.jwt.value, nil
+	}
+	issuedAt := now.Add(-clockSkew)
+	expiresAt := now.Add(jwtLifetime)
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": issuedAt.Unix(),
+		"exp": expiresAt.Unix(),
+		"iss": strconv.FormatInt(a.opts.ID, 10),
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.opts.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign GitHub App JWT")
+	}
+	a.jwt = token{value: signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), expiresAt: expiresAt}
+	return a.jwt.value, nil
+}
+
+func (a *App) jwtHeader(context.Context) (string, error) {
+	jwt, err := a.JWT()
+	return "Bearer " + jwt, err
+}
+
+// InstallationToken returns a token of the installation, created with the app's JWT and reused until shortly before
+// it expires.
+func (a *App) InstallationToken(ctx context.Context, installationID int64) (string, error) {
+	a.mu.Lock()
+	cached := a.tokens[installationID]
+	a.mu.Unlock()
+	if cached.valid(a.now()) {
+		return cached.value, nil
+	}
+
+	created, _, err := a.client.Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create token for GitHub App installation %d", installationID)
+	}
+	t := token{value: created.GetToken(), expiresAt: created.GetExpiresAt().Time}
+	slog.Debug("created GitHub App installation token", "installation", installationID, "expires_at", t.expiresAt)
+
+	a.mu.Lock()
+	a.tokens[installationID] = t
+	a.mu.Unlock()
+	return t.value, nil
+}
+
+// Installation returns the installation to authenticate as for owner: AppOptions.InstallationID if set, otherwise
+// the installation on the owner's organization or user account. Owners without one, e.g. "actions", use the first
+// installation of the app, whose token can read public repositories.
+func (a *App) Installation(ctx context.Context, owner string) (int64, error) {
+	if a.opts.InstallationID != 0 {
+		return a.opts.InstallationID, nil
+	}
+
+	a.mu.Lock()
+	id, ok := a.installations[owner]
+	a.mu.Unlock()
+	if !ok {
+		var err error
+		id, err = a.findInstallation(ctx, owner)
+		if err != nil {
+			return 0, err
+		}
+		a.mu.Lock()
+		a.installations[owner] = id
+		a.mu.Unlock()
+	}
+	if id != 0 {
+		return id, nil
+	}
+	return a.fallbackInstallation(ctx)
+}
+
+// findInstallation returns the installation on owner's organization or user account, or zero if there is none.
+func (a *App) findInstallation(ctx context.Context, owner string) (int64, error) {
+	installation, resp, err := a.client.Apps.FindOrganizationInstallation(ctx, owner)
+	if err == nil {
+		slog.Debug("found GitHub App installation", logging.KeyOwner, owner, "installation", installation.GetID())
+		return installation.GetID(), nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return 0, errors.Wrapf(err, "failed to find GitHub App installation for %s", owner)
+	}
+	installation, resp, err = a.client.Apps.FindUserInstallation(ctx, owner)
+	if err == nil {
+		slog.Debug("found GitHub App installation", logging.KeyOwner, owner, "installation", installation.GetID())
+		return installation.GetID(), nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return 0, errors.Wrapf(err, "failed to find GitHub App installation for %s", owner)
+	}
+	slog.Debug("GitHub App is not installed for owner", logging.KeyOwner, owner)
+	return 0, nil
+}
+
+func (a *App) fallbackInstallation(ctx context.Context) (int64, error) {
+	a.mu.Lock()
+	id := a.fallback
+	a.mu.Unlock()
+	if id != 0 {
+		return id, nil
+	}
+
+	installations, _, err := a.client.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list GitHub App installations")
+	}
+	if len(installations) == 0 {
+		return 0, errors.WithStack(ErrNoInstallation)
+	}
+	id = installations[0].GetID()
+	a.mu.Lock()
+	a.fallback = id
+	a.mu.Unlock()
+	return id, nil
+}
+
+// installationClient returns a client authenticated as the installation. Its token is refreshed as needed.
+func (a *App) installationClient(installationID int64) (*github.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if client, ok := a.clients[installationID]; ok {
+		return client, nil
+	}
+	client, err := a.newClient(func(ctx context.Context) (string, error) {
+		t, err := a.InstallationToken(ctx, installationID)
+		return "Bearer " + t, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.clients[installationID] = client
+	return client, nil
+}
+
+// RepositoryService returns a pin.RepositoryService calling the API as the installation for the owner of each
+// repository, see Installation.
+func (a *App) RepositoryService() pin.RepositoryService {
+	return appRepositoryService{app: a}
+}
+
+type appRepositoryService struct {
+	app *App
+}
+
+func (s appRepositoryService) repositories(ctx context.Context, owner string) (*github.RepositoriesService, error) {
+	id, err := s.app.Installation(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.app.installationClient(id)
+	if err != nil {
+		return nil, err
+	}
+	return client.Repositories, nil
+}
+
+func (s appRepositoryService) ListTags(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error) {
+	repos, err := s.repositories(ctx, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repos.ListTags(ctx, owner, repo, opts) //nolint:wrapcheck // Errors are wrapped by the resolver.
+}
+
+func (s appRepositoryService) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error) {
+	repos, err := s.repositories(ctx, owner)
+	if err != nil {
+		return "", nil, err
+	}
+	return repos.GetCommitSHA1(ctx, owner, repo, ref, lastSHA) //nolint:wrapcheck // Errors are wrapped by the resolver.
+}
+
+// authTransport sets the Authorization header of each request to the value returned by auth.
+type authTransport struct {
+	base http.RoundTripper
+	auth func(ctx context.Context) (string, error)
+}
+
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header, err := t.auth(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", header)
+	return t.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned as is to the HTTP client.
+}
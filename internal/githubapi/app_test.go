@@ -0,0 +1,211 @@
+package githubapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAppAPI stands in for the GitHub API: the app is installed on the "my-org" organization (installation 7) and
+// the "alice" user (installation 8). Tokens are "token-<installation>-<n>" and expire after an hour of fake time.
+type fakeAppAPI struct {
+	t   *testing.T
+	key *rsa.PublicKey
+	now func() time.Time
+
+	mu sync.Mutex
+	// minted counts the tokens created per installation.
+	minted map[string]int
+	// auth is the Authorization header of the last request per path.
+	auth map[string]string
+}
+
+func (f *fakeAppAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth[r.URL.Path] = r.Header.Get("Authorization")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3")
+	if strings.HasPrefix(path, "/repos/") {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+			http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "sha1"}}]`)
+		return
+	}
+
+	f.verifyJWT(r.Header.Get("Authorization"))
+	switch {
+	case path == "/orgs/my-org/installation":
+		fmt.Fprint(w, `{"id": 7}`)
+	case path == "/users/alice/installation":
+		fmt.Fprint(w, `{"id": 8}`)
+	case path == "/app/installations":
+		fmt.Fprint(w, `[{"id": 7}, {"id": 8}]`)
+	case r.Method == http.MethodPost && (path == "/app/installations/7/access_tokens" || path == "/app/installations/8/access_tokens"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/app/installations/"), "/access_tokens")
+		f.minted[id]++
+		expiresAt := f.now().Add(time.Hour).UTC().Format(time.RFC3339)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "token-%s-%d", "expires_at": %q}`, id, f.minted[id], expiresAt)
+	default:
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}
+}
+
+// verifyJWT checks the signature and claims of an app JWT.
+func (f *fakeAppAPI) verifyJWT(header string) {
+	jwt, ok := strings.CutPrefix(header, "Bearer ")
+	if !assert.True(f.t, ok, header) {
+		return
+	}
+	parts := strings.Split(jwt, ".")
+	if !assert.Len(f.t, parts, 3) {
+		return
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(f.t, err)
+	assert.NoError(f.t, rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], signature))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(f.t, err)
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	assert.NoError(f.t, json.Unmarshal(payload, &claims))
+	assert.Equal(f.t, "42", claims.Iss)
+	now := f.now().Unix()
+	assert.Less(f.t, claims.Iat, now)
+	assert.LessOrEqual(f.t, claims.Exp-claims.Iat, int64(10*60))
+}
+
+func newTestApp(t *testing.T, installationID int64) (*App, *fakeAppAPI, *time.Time) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	api := &fakeAppAPI{t: t, key: &key.PublicKey, now: clock, minted: map[string]int{}, auth: map[string]string{}}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	app, err := NewApp(AppOptions{ID: 42, Key: key, InstallationID: installationID, APIURL: server.URL})
+	require.NoError(t, err)
+	app.now = clock
+	return app, api, &now
+}
+
+func TestApp_RepositoryService(t *testing.T) {
+	app, api, now := newTestApp(t, 0)
+	repos := app.RepositoryService()
+	ctx := context.Background()
+
+	for _, owner := range []string{"my-org", "alice", "actions"} {
+		tags, _, err := repos.ListTags(ctx, owner, "repo", nil)
+		require.NoError(t, err, owner)
+		assert.Len(t, tags, 1)
+	}
+	assert.Equal(t, "Bearer token-7-1", api.auth["/api/v3/repos/my-org/repo/tags"])
+	assert.Equal(t, "Bearer token-8-1", api.auth["/api/v3/repos/alice/repo/tags"])
+	// actions has no installation and uses the first one.
+	assert.Equal(t, "Bearer token-7-1", api.auth["/api/v3/repos/actions/repo/tags"])
+	assert.Equal(t, map[string]int{"7": 1, "8": 1}, api.minted)
+
+	// Tokens are reused until shortly before they expire.
+	*now = now.Add(58 * time.Minute)
+	_, _, err := repos.ListTags(ctx, "my-org", "repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-7-1", api.auth["/api/v3/repos/my-org/repo/tags"])
+
+	*now = now.Add(time.Minute)
+	_, _, err = repos.GetCommitSHA1(ctx, "my-org", "repo", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-7-2", api.auth["/api/v3/repos/my-org/repo/commits/main"])
+}
+
+func TestApp_InstallationID(t *testing.T) {
+	app, api, _ := newTestApp(t, 8)
+
+	_, _, err := app.RepositoryService().ListTags(context.Background(), "my-org", "repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-8-1", api.auth["/api/v3/repos/my-org/repo/tags"])
+	assert.NotContains(t, api.auth, "/api/v3/orgs/my-org/installation")
+}
+
+func TestApp_JWT(t *testing.T) {
+	app, _, now := newTestApp(t, 0)
+
+	first, err := app.JWT()
+	require.NoError(t, err)
+	second, err := app.JWT()
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	*now = now.Add(9 * time.Minute)
+	third, err := app.JWT()
+	require.NoError(t, err)
+	assert.NotEqual(t, first, third)
+}
+
+func TestApp_errors(t *testing.T) {
+	app, _, _ := newTestApp(t, 0)
+	_, err := app.InstallationToken(context.Background(), 99)
+	require.Error(t, err)
+
+	// Owners without an installation fail if the app has no installations at all.
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/app/installations") {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}))
+	t.Cleanup(empty.Close)
+	app.opts.APIURL = empty.URL
+	client, err := app.newClient(app.jwtHeader)
+	require.NoError(t, err)
+	app.client = client
+	_, err = app.Installation(context.Background(), "nobody")
+	assert.True(t, errors.Is(err, ErrNoInstallation), err)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := ParsePrivateKey(pkcs1)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+}
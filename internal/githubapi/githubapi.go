@@ -0,0 +1,111 @@
+// Package githubapi creates clients for the GitHub REST API on github.com and GitHub Enterprise Server.
+package githubapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+// DefaultAPIURL is the REST API of github.com.
+const DefaultAPIURL = "https://api.github.com/"
+
+// enterprisePath is where GitHub Enterprise Server serves the REST API.
+const enterprisePath = "/api/v3/"
+
+// Transport is the base transport of the clients created by this package. It logs API calls at debug level; replace
+// it before creating clients to add e.g. a cache.
+var Transport http.RoundTripper = logging.Transport{}
+
+// NewClient creates a client for the REST API at apiURL, authenticated with token unless it's empty. An empty apiURL
+// is github.com; see APIURL for other URLs. API calls are logged at debug level.
+func NewClient(token, apiURL string) (*github.Client, error) {
+	client := github.NewClient(&http.Client{Transport: Transport})
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	return withAPIURL(client, apiURL)
+}
+
+// withAPIURL points client at the REST API at apiURL, see APIURL. An empty apiURL is github.com.
+func withAPIURL(client *github.Client, apiURL string) (*github.Client, error) {
+	if apiURL == "" {
+		return client, nil
+	}
+	baseURL, err := APIURL(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == DefaultAPIURL {
+		return client, nil
+	}
+	// Uploads are served at /api/uploads/ next to /api/v3/.
+	client, err = client.WithEnterpriseURLs(baseURL, strings.TrimSuffix(baseURL, "api/v3/"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return client, nil
+}
+
+// APIURL normalizes the URL of a REST API. A GitHub Enterprise Server host, e.g. https://ghe.example.com, gets the
+// /api/v3/ path the API is served at, as with go-github's enterprise URLs. Hosts starting with "api." (github.com
+// and GHE.com) and URLs already ending with /api/v3 are kept as they are.
+func APIURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid GitHub API URL: %s", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.Newf("invalid GitHub API URL %s: unsupported scheme %q", raw, u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.Newf("invalid GitHub API URL %s: missing host", raw)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	if !strings.HasSuffix(u.Path, enterprisePath) && !strings.HasPrefix(u.Host, "api.") && !strings.Contains(u.Host, ".api.") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + enterprisePath
+	}
+	return u.String(), nil
+}
+
+// Host is a GitHub API and the owners whose repositories are read from it.
+type Host struct {
+	APIURL string
+	// Owners are path.Match patterns of owner names.
+	Owners []string
+	// Token authenticates the calls unless it's empty.
+	Token string
+	// Credential describes where Token comes from in errors, e.g. "env ORG_A_TOKEN". See pin.Route.
+	Credential string
+}
+
+// NewRepositoryService returns fallback, or with hosts, a pin.OwnerRouter sending the calls for their owners to their
+// APIs and the others to fallback, e.g. the Repositories service of a client or App.RepositoryService. Hosts are
+// tried in order.
+func NewRepositoryService(fallback pin.RepositoryService, hosts []Host) (pin.RepositoryService, error) {
+	if len(hosts) == 0 {
+		return fallback, nil
+	}
+	routes := make([]pin.Route, 0, len(hosts))
+	for _, host := range hosts {
+		hostClient, err := NewClient(host.Token, host.APIURL)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, pin.Route{
+			Owners:     host.Owners,
+			Host:       hostClient.BaseURL.String(),
+			Credential: host.Credential,
+			Service:    hostClient.Repositories,
+		})
+	}
+	return pin.NewOwnerRouter(fallback, routes), nil
+}
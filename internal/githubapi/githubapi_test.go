@@ -0,0 +1,104 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://api.github.com", "https://api.github.com/"},
+		{"https://ghe.example.com", "https://ghe.example.com/api/v3/"},
+		{"https://ghe.example.com/", "https://ghe.example.com/api/v3/"},
+		{"https://ghe.example.com/api/v3", "https://ghe.example.com/api/v3/"},
+		{"https://api.octo.ghe.com", "https://api.octo.ghe.com/"},
+		{"http://127.0.0.1:8080/api/v3/", "http://127.0.0.1:8080/api/v3/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := APIURL(tt.raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, raw := range []string{"ghe.example.com", "ftp://ghe.example.com", "https://", "://"} {
+		_, err := APIURL(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAPIURL, client.BaseURL.String())
+
+	client, err = NewClient("token", "https://ghe.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", client.BaseURL.String())
+	assert.Equal(t, "https://ghe.example.com/api/uploads/", client.UploadURL.String())
+
+	_, err = NewClient("", "ghe.example.com")
+	require.Error(t, err)
+}
+
+// newServer serves the tags of actions/checkout under /api/v3/ and records the Authorization header of the last
+// request in auth.
+func newServer(t *testing.T, sha string, auth *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/api/v3/repos/actions/checkout/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"name": "v4.2.2", "commit": {"sha": %q}}]`, sha)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewRepositoryService(t *testing.T) {
+	var ghesAuth, dotcomAuth string
+	ghes := newServer(t, "ghes-sha", &ghesAuth)
+	dotcom := newServer(t, "dotcom-sha", &dotcomAuth)
+
+	client, err := NewClient("ghes-token", ghes.URL)
+	require.NoError(t, err)
+	repos, err := NewRepositoryService(client.Repositories, []Host{{APIURL: dotcom.URL, Owners: []string{"actions"}, Token: "dotcom-token"}})
+	require.NoError(t, err)
+
+	tags, _, err := repos.ListTags(context.Background(), "actions", "checkout", nil)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "dotcom-sha", tags[0].GetCommit().GetSHA())
+	assert.Equal(t, "Bearer dotcom-token", dotcomAuth)
+
+	// Other owners use the default client.
+	_, _, err = repos.ListTags(context.Background(), "platform", "checkout", nil)
+	require.Error(t, err)
+	assert.Equal(t, "Bearer ghes-token", ghesAuth)
+
+	// Not-found errors of routes name the credential, as private repositories the token cannot read are not found.
+	repos, err = NewRepositoryService(client.Repositories, []Host{{APIURL: dotcom.URL, Owners: []string{"org-a"}, Token: "org-a-token", Credential: "env ORG_A_TOKEN"}})
+	require.NoError(t, err)
+	_, _, err = repos.ListTags(context.Background(), "org-a", "private", nil)
+	require.ErrorContains(t, err, "with credential env ORG_A_TOKEN, which may not have access")
+	assert.Equal(t, "Bearer org-a-token", dotcomAuth)
+
+	repos, err = NewRepositoryService(client.Repositories, nil)
+	require.NoError(t, err)
+	assert.Same(t, client.Repositories, repos)
+
+	_, err = NewRepositoryService(client.Repositories, []Host{{APIURL: "ghe.example.com"}})
+	require.Error(t, err)
+}
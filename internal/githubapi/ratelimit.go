@@ -0,0 +1,216 @@
+package githubapi
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/cache"
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+const (
+	// DefaultMaxRetries is how often a call is retried by default.
+	DefaultMaxRetries = 3
+	// DefaultMaxWait is the longest rate-limit wait without RetryOptions.Wait, enough for most secondary rate limits.
+	DefaultMaxWait = time.Minute
+	// DefaultBackoff is the default delay of the first retry after a 5xx error.
+	DefaultBackoff = time.Second
+
+	// secondaryRateLimitWait is how long to wait after a secondary rate limit without Retry-After, as GitHub
+	// recommends.
+	secondaryRateLimitWait = time.Minute
+	// resetBuffer is added to the reset time of primary rate limits against clock skew.
+	resetBuffer = time.Second
+)
+
+// RetryOptions configures a RetryService.
+type RetryOptions struct {
+	// MaxRetries is how often a call is retried after a rate limit or a 5xx error.
+	MaxRetries int
+	// Wait waits for rate limits to reset however long it takes. Otherwise calls fail when the wait would be longer
+	// than MaxWait.
+	Wait    bool
+	MaxWait time.Duration
+	// Backoff is the delay of the first retry after a 5xx error. It doubles with each retry and is jittered.
+	Backoff time.Duration
+}
+
+// Stats counts the API calls of a RetryService.
+type Stats struct {
+	// Calls is the number of calls, including retries.
+	Calls   int
+	Retries int
+	// Cached is the number of calls answered by the cache, see cache.StatusHeader.
+	Cached int
+	// Waited is the time spent waiting for rate limits and before retries.
+	Waited time.Duration
+	// Quotas are the primary rate limits of the last responses by API host.
+	Quotas map[string]github.Rate
+}
+
+// RetryService is a pin.RepositoryService retrying the calls of another one that hit rate limits or fail with
+// transient 5xx errors. Rate limits are waited out as told by Retry-After and X-RateLimit-Reset; 5xx errors are
+// retried with jittered exponential backoff. It's safe for concurrent use.
+type RetryService struct {
+	service pin.RepositoryService
+	opts    RetryOptions
+	sleep   func(ctx context.Context, d time.Duration) error
+	now     func() time.Time
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewRetryService creates a RetryService calling service. Zero options use the defaults.
+func NewRetryService(service pin.RepositoryService, opts RetryOptions) *RetryService {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.MaxWait == 0 {
+		opts.MaxWait = DefaultMaxWait
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	return &RetryService{
+		service: service,
+		opts:    opts,
+		sleep:   sleep,
+		now:     time.Now,
+		stats:   Stats{Quotas: map[string]github.Rate{}},
+	}
+}
+
+// Stats returns the calls made so far.
+func (s *RetryService) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Quotas = make(map[string]github.Rate, len(s.stats.Quotas))
+	for host, rate := range s.stats.Quotas {
+		stats.Quotas[host] = rate
+	}
+	return stats
+}
+
+func (s *RetryService) ListTags(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error) {
+	var tags []*github.RepositoryTag
+	resp, err := s.retry(ctx, owner, repo, func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		tags, resp, err = s.service.ListTags(ctx, owner, repo, opts)
+		return resp, err
+	})
+	return tags, resp, err
+}
+
+func (s *RetryService) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error) {
+	var sha string
+	resp, err := s.retry(ctx, owner, repo, func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		sha, resp, err = s.service.GetCommitSHA1(ctx, owner, repo, ref, lastSHA)
+		return resp, err
+	})
+	return sha, resp, err
+}
+
+// retry calls call until it succeeds, fails with an error that is not retried, or MaxRetries is reached.
+func (s *RetryService) retry(ctx context.Context, owner, repo string, call func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := call()
+		s.record(resp)
+		if err == nil || attempt >= s.opts.MaxRetries {
+			return resp, err
+		}
+
+		wait, rateLimited := s.delay(resp, err, attempt)
+		if wait < 0 {
+			return resp, err
+		}
+		if rateLimited && wait > s.opts.MaxWait && !s.opts.Wait {
+			return resp, errors.Wrapf(err, "rate limit resets in %s, longer than the maximum wait of %s",
+				wait.Round(time.Second), s.opts.MaxWait)
+		}
+
+		attrs := []any{logging.KeyOwner, owner, logging.KeyRepo, repo, "attempt", attempt + 1, "wait", wait.Round(time.Millisecond), "error", err}
+		if rateLimited {
+			slog.Warn("GitHub API rate limit exceeded. waiting before retrying", attrs...)
+		} else {
+			slog.Debug("GitHub API call failed. retrying", attrs...)
+		}
+		if err := s.sleep(ctx, wait); err != nil {
+			return resp, err
+		}
+		s.mu.Lock()
+		s.stats.Retries++
+		s.stats.Waited += wait
+		s.mu.Unlock()
+	}
+}
+
+// delay returns how long to wait before retrying a call that failed with err, and whether it hit a rate limit.
+// The delay is negative for errors that are not retried.
+func (s *RetryService) delay(resp *github.Response, err error, attempt int) (time.Duration, bool) {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		if rateLimitErr.Rate.Reset.IsZero() {
+			return secondaryRateLimitWait, true
+		}
+		return max(rateLimitErr.Rate.Reset.Sub(s.now())+resetBuffer, 0), true
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return secondaryRateLimitWait, true
+	}
+	if resp != nil && resp.Response != nil && resp.StatusCode >= http.StatusInternalServerError {
+		// Jitter within the upper half of the doubled delay spreads the retries of concurrent callers.
+		backoff := s.opts.Backoff << attempt
+		return backoff/2 + rand.N(backoff/2+1), false //nolint:gosec // Jitter needs no cryptographic randomness.
+	}
+	return -1, false
+}
+
+// record counts a call and keeps the rate limit of its response.
+func (s *RetryService) record(resp *github.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Calls++
+	if resp == nil || resp.Response == nil {
+		return
+	}
+	if resp.Header.Get(cache.StatusHeader) != "" {
+		s.stats.Cached++
+	}
+	if resp.Request == nil || resp.Rate.Limit == 0 {
+		return
+	}
+	s.stats.Quotas[resp.Request.URL.Host] = resp.Rate
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+// newTestRetryService returns a RetryService calling a mock, recording its sleeps instead of sleeping.
+func newTestRetryService(t *testing.T, opts RetryOptions) (*RetryService, *pin.MockRepositoryService, *[]time.Duration) {
+	t.Helper()
+	mock := pin.NewMockRepositoryService(gomock.NewController(t))
+	s := NewRetryService(mock, opts)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	var sleeps []time.Duration
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return s, mock, &sleeps
+}
+
+func rateLimitError(reset time.Time) error {
+	return &github.RateLimitError{Rate: github.Rate{Limit: 5000, Reset: github.Timestamp{Time: reset}}, Message: "API rate limit exceeded"}
+}
+
+func TestRetryService_rateLimits(t *testing.T) {
+	s, mock, sleeps := newTestRetryService(t, RetryOptions{})
+	ctx := context.Background()
+	retryAfter := 30 * time.Second
+
+	gomock.InOrder(
+		mock.EXPECT().ListTags(gomock.Any(), "actions", "runner-images", gomock.Any()).
+			Return(nil, nil, rateLimitError(s.now().Add(20*time.Second))),
+		mock.EXPECT().ListTags(gomock.Any(), "actions", "runner-images", gomock.Any()).
+			Return(nil, nil, &github.AbuseRateLimitError{RetryAfter: &retryAfter}),
+		mock.EXPECT().ListTags(gomock.Any(), "actions", "runner-images", gomock.Any()).
+			Return([]*github.RepositoryTag{{Name: github.Ptr("v1")}}, &github.Response{}, nil),
+	)
+	tags, _, err := s.ListTags(ctx, "actions", "runner-images", nil)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, []time.Duration{21 * time.Second, 30 * time.Second}, *sleeps)
+
+	stats := s.Stats()
+	assert.Equal(t, 3, stats.Calls)
+	assert.Equal(t, 2, stats.Retries)
+	assert.Equal(t, 51*time.Second, stats.Waited)
+}
+
+func TestRetryService_maxWait(t *testing.T) {
+	s, mock, sleeps := newTestRetryService(t, RetryOptions{})
+	reset := s.now().Add(30 * time.Minute)
+
+	mock.EXPECT().GetCommitSHA1(gomock.Any(), "actions", "checkout", "main", "").Return("", nil, rateLimitError(reset))
+	_, _, err := s.GetCommitSHA1(context.Background(), "actions", "checkout", "main", "")
+	require.ErrorContains(t, err, "rate limit resets in 30m1s, longer than the maximum wait of 1m0s")
+	var rateLimitErr *github.RateLimitError
+	assert.True(t, errors.As(err, &rateLimitErr))
+	assert.Empty(t, *sleeps)
+
+	// With Wait, the call waits for the reset.
+	s.opts.Wait = true
+	gomock.InOrder(
+		mock.EXPECT().GetCommitSHA1(gomock.Any(), "actions", "checkout", "main", "").Return("", nil, rateLimitError(reset)),
+		mock.EXPECT().GetCommitSHA1(gomock.Any(), "actions", "checkout", "main", "").Return("sha", &github.Response{}, nil),
+	)
+	sha, _, err := s.GetCommitSHA1(context.Background(), "actions", "checkout", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, "sha", sha)
+	assert.Equal(t, []time.Duration{30*time.Minute + time.Second}, *sleeps)
+}
+
+func TestRetryService_errors(t *testing.T) {
+	s, mock, sleeps := newTestRetryService(t, RetryOptions{MaxRetries: 2, Backoff: time.Second})
+	ctx := context.Background()
+	unavailable := &github.Response{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+
+	// 5xx errors are retried with backoff until MaxRetries.
+	mock.EXPECT().ListTags(gomock.Any(), "actions", "checkout", gomock.Any()).
+		Return(nil, unavailable, errors.New("502 Bad Gateway")).Times(3)
+	_, _, err := s.ListTags(ctx, "actions", "checkout", nil)
+	require.EqualError(t, err, "502 Bad Gateway")
+	require.Len(t, *sleeps, 2)
+	assert.GreaterOrEqual(t, (*sleeps)[0], 500*time.Millisecond)
+	assert.LessOrEqual(t, (*sleeps)[0], time.Second)
+	assert.GreaterOrEqual(t, (*sleeps)[1], time.Second)
+	assert.LessOrEqual(t, (*sleeps)[1], 2*time.Second)
+
+	// Other errors are not retried.
+	notFound := &github.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	mock.EXPECT().ListTags(gomock.Any(), "actions", "missing", gomock.Any()).
+		Return(nil, notFound, errors.New("404 Not Found"))
+	_, _, err = s.ListTags(ctx, "actions", "missing", nil)
+	require.EqualError(t, err, "404 Not Found")
+	assert.Len(t, *sleeps, 2)
+}
+
+func TestRetryService_client(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", "1735693200")
+		if calls.Add(1) == 1 {
+			http.Error(w, `{"message": "Server Error"}`, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "sha1"}}]`)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient("", server.URL+"/api/v3/")
+	require.NoError(t, err)
+
+	s := NewRetryService(client.Repositories, RetryOptions{Backoff: time.Millisecond})
+	tags, _, err := s.ListTags(context.Background(), "actions", "checkout", nil)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	stats := s.Stats()
+	assert.Equal(t, 2, stats.Calls)
+	assert.Equal(t, 1, stats.Retries)
+	host := server.Listener.Addr().String()
+	require.Contains(t, stats.Quotas, host)
+	assert.Equal(t, 4321, stats.Quotas[host].Remaining)
+	assert.Equal(t, 5000, stats.Quotas[host].Limit)
+}
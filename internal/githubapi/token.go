@@ -0,0 +1,283 @@
+package githubapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+)
+
+// Token is a GitHub token and where it was found.
+type Token struct {
+	Value string
+	// Source describes where the token was found for logs, e.g. "netrc (/home/me/.netrc)".
+	Source string
+}
+
+// TokenOptions configures FindToken.
+type TokenOptions struct {
+	// APIURL is the REST API the token is for, see WebHost. Defaults to github.com.
+	APIURL string
+	// CredentialHelper is a shell command printing a token on stdout. The host is passed in GHA_FIX_GITHUB_HOST.
+	CredentialHelper string
+	// Getenv looks up environment variables. Defaults to os.Getenv.
+	Getenv func(string) string
+	// HomeDir is the user's home directory. Defaults to os.UserHomeDir.
+	HomeDir string
+}
+
+// credentialHelperHostEnv passes the host to credential helpers.
+const credentialHelperHostEnv = "GHA_FIX_GITHUB_HOST"
+
+// FindToken looks up a token for the host of opts.APIURL in, in order: the GitHub CLI's hosts.yml, ~/.netrc (or
+// $NETRC) and the credential helper. Returns a zero Token if none has one. Unreadable or invalid files are skipped
+// with a warning; a failing credential helper is an error.
+func FindToken(ctx context.Context, opts TokenOptions) (Token, error) {
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	if opts.HomeDir == "" {
+		opts.HomeDir, _ = os.UserHomeDir()
+	}
+	host, err := WebHost(opts.APIURL)
+	if err != nil {
+		return Token{}, err
+	}
+
+	for _, find := range []func(TokenOptions, string) (Token, error){ghCLIToken, netrcToken} {
+		token, err := find(opts, host)
+		if err != nil {
+			slog.Warn("skipping unreadable credentials", "host", host, "error", err)
+			continue
+		}
+		if token.Value != "" {
+			return token, nil
+		}
+	}
+	if opts.CredentialHelper != "" {
+		return helperToken(ctx, opts, host)
+	}
+	return Token{}, nil
+}
+
+// WebHost returns the host of the GitHub instance serving the REST API at apiURL, as used by git and the GitHub CLI:
+// github.com for api.github.com, octo.ghe.com for api.octo.ghe.com, and the host itself for GitHub Enterprise
+// Server.
+func WebHost(apiURL string) (string, error) {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid GitHub API URL: %s", apiURL)
+	}
+	if host, ok := strings.CutPrefix(u.Host, "api."); ok {
+		return host, nil
+	}
+	return u.Host, nil
+}
+
+// HostAPIURL returns the REST API of the GitHub instance at host, the reverse of WebHost: https://api.github.com/
+// for github.com, https://api.octo.ghe.com/ for GHE.com hosts like octo.ghe.com, and /api/v3/ on the host itself for
+// GitHub Enterprise Server.
+func HostAPIURL(host string) string {
+	if host == "github.com" || strings.HasSuffix(host, ".ghe.com") {
+		return "https://api." + host + "/"
+	}
+	return "https://" + host + enterprisePath
+}
+
+// ghCLIToken reads the token of host from the hosts.yml file of the GitHub CLI. Tokens kept in the system keyring
+// by recent versions are not found.
+func ghCLIToken(opts TokenOptions, host string) (Token, error) {
+	dir := opts.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		if xdg := opts.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			dir = filepath.Join(xdg, "gh")
+		} else if runtime.GOOS == "windows" && opts.Getenv("AppData") != "" {
+			dir = filepath.Join(opts.Getenv("AppData"), "GitHub CLI")
+		} else {
+			dir = filepath.Join(opts.HomeDir, ".config", "gh")
+		}
+	}
+	path := filepath.Join(dir, "hosts.yml")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Token{}, nil
+		}
+		return Token{}, errors.WithStack(err)
+	}
+
+	var hosts map[string]struct {
+		User       string `yaml:"user"`
+		OAuthToken string `yaml:"oauth_token"`
+		Users      map[string]struct {
+			OAuthToken string `yaml:"oauth_token"`
+		} `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(content, &hosts); err != nil {
+		return Token{}, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	entry := hosts[host]
+	value := entry.OAuthToken
+	if value == "" {
+		value = entry.Users[entry.User].OAuthToken
+	}
+	if value == "" {
+		return Token{}, nil
+	}
+	return Token{Value: value, Source: "GitHub CLI (" + path + ")"}, nil
+}
+
+// netrcToken reads the password of host, or of the default entry, from the netrc file.
+func netrcToken(opts TokenOptions, host string) (Token, error) {
+	path := opts.Getenv("NETRC")
+	if path == "" {
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(opts.HomeDir, name)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Token{}, nil
+		}
+		return Token{}, errors.WithStack(err)
+	}
+
+	value := parseNetrc(string(content), host)
+	if value == "" {
+		return Token{}, nil
+	}
+	return Token{Value: value, Source: "netrc (" + path + ")"}, nil
+}
+
+// parseNetrc returns the password of the machine entry of host, or of the default entry if there is none.
+func parseNetrc(content, host string) string {
+	var fields []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields = append(fields, strings.Fields(line)...)
+	}
+
+	var machine, defaultPassword string
+	inDefault := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				machine, inDefault = fields[i], false
+			}
+		case "default":
+			machine, inDefault = "", true
+		case "macdef":
+			// Macros run until an empty line, which the tokenization above loses. They don't appear in practice.
+			return ""
+		case "password":
+			if i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if machine == host {
+				return fields[i]
+			}
+			if inDefault {
+				defaultPassword = fields[i]
+			}
+		case "login", "account":
+			i++
+		}
+	}
+	return defaultPassword
+}
+
+// helperToken runs the credential helper.
+func helperToken(ctx context.Context, opts TokenOptions, host string) (Token, error) {
+	value, err := commandToken(ctx, opts.CredentialHelper, host)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "credential helper failed")
+	}
+	if value == "" {
+		return Token{}, nil
+	}
+	return Token{Value: value, Source: "credential helper"}, nil
+}
+
+// commandToken runs command with the shell and returns the first line of its output. host is passed in
+// GHA_FIX_GITHUB_HOST.
+func commandToken(ctx context.Context, command, host string) (string, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	// The command comes from the user's config file, like a git credential helper.
+	cmd := exec.CommandContext(ctx, shell, flag, command) //nolint:gosec
+	cmd.Env = append(os.Environ(), credentialHelperHostEnv+"="+host)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "%s", strings.TrimSpace(stderr.String()))
+	}
+	value, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	return strings.TrimSpace(value), nil
+}
+
+// TokenSource is where the token of a credential is read from: an environment variable, a file or the output of a
+// shell command. Exactly one is set.
+type TokenSource struct {
+	Env     string
+	File    string
+	Command string
+}
+
+// String describes the source for logs and errors, without the token.
+func (s TokenSource) String() string {
+	switch {
+	case s.Env != "":
+		return "env " + s.Env
+	case s.File != "":
+		return "file " + s.File
+	default:
+		return "command `" + s.Command + "`"
+	}
+}
+
+// Token reads the token for the API at apiURL. An unset environment variable yields "", a missing file or a failing
+// command an error.
+func (s TokenSource) Token(ctx context.Context, apiURL string) (string, error) {
+	switch {
+	case s.Env != "":
+		return os.Getenv(s.Env), nil
+	case s.File != "":
+		content, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read token file")
+		}
+		return strings.TrimSpace(string(content)), nil
+	default:
+		host, err := WebHost(apiURL)
+		if err != nil {
+			return "", err
+		}
+		value, err := commandToken(ctx, s.Command, host)
+		return value, errors.Wrap(err, "token command failed")
+	}
+}
@@ -0,0 +1,175 @@
+package githubapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebHost(t *testing.T) {
+	tests := map[string]string{
+		"":                                "github.com",
+		"https://api.github.com/":         "github.com",
+		"https://api.octo.ghe.com/":       "octo.ghe.com",
+		"https://ghe.example.com/api/v3/": "ghe.example.com",
+		"http://127.0.0.1:8080/api/v3/":   "127.0.0.1:8080",
+	}
+	for apiURL, want := range tests {
+		got, err := WebHost(apiURL)
+		require.NoError(t, err, apiURL)
+		assert.Equal(t, want, got, apiURL)
+	}
+}
+
+func TestHostAPIURL(t *testing.T) {
+	for _, host := range []string{"github.com", "octo.ghe.com", "ghe.example.com"} {
+		apiURL := HostAPIURL(host)
+		got, err := WebHost(apiURL)
+		require.NoError(t, err)
+		assert.Equal(t, host, got, apiURL)
+	}
+	assert.Equal(t, DefaultAPIURL, HostAPIURL("github.com"))
+	assert.Equal(t, "https://ghe.example.com/api/v3/", HostAPIURL("ghe.example.com"))
+}
+
+// tokenOptions returns options reading the files of home, with env as the environment.
+func tokenOptions(home string, env map[string]string) TokenOptions {
+	return TokenOptions{
+		Getenv:  func(key string) string { return env[key] },
+		HomeDir: home,
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestFindToken(t *testing.T) {
+	ctx := context.Background()
+	home := t.TempDir()
+	opts := tokenOptions(home, nil)
+
+	token, err := FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{}, token)
+
+	netrc := filepath.Join(home, ".netrc")
+	writeFile(t, netrc, `
+# GitHub Enterprise Server
+machine ghe.example.com login me password ghe-netrc
+machine github.com
+  login me
+  password dotcom-netrc
+`)
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{Value: "dotcom-netrc", Source: "netrc (" + netrc + ")"}, token)
+
+	opts.APIURL = "https://ghe.example.com/api/v3/"
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "ghe-netrc", token.Value)
+
+	// The GitHub CLI comes before netrc.
+	hosts := filepath.Join(home, ".config", "gh", "hosts.yml")
+	writeFile(t, hosts, `
+github.com:
+    user: me
+    oauth_token: dotcom-gh
+    git_protocol: https
+ghe.example.com:
+    user: me
+    users:
+        me:
+            oauth_token: ghe-gh
+`)
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{Value: "ghe-gh", Source: "GitHub CLI (" + hosts + ")"}, token)
+
+	opts.APIURL = ""
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "dotcom-gh", token.Value)
+
+	// GH_CONFIG_DIR and NETRC move the files.
+	opts = tokenOptions(home, map[string]string{"GH_CONFIG_DIR": t.TempDir(), "NETRC": filepath.Join(home, "missing")})
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{}, token)
+}
+
+func TestFindToken_credentialHelper(t *testing.T) {
+	ctx := context.Background()
+	opts := tokenOptions(t.TempDir(), nil)
+	opts.APIURL = "https://ghe.example.com"
+
+	opts.CredentialHelper = `printf 'token-for-%s\nignored\n' "$GHA_FIX_GITHUB_HOST"`
+	token, err := FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{Value: "token-for-ghe.example.com", Source: "credential helper"}, token)
+
+	opts.CredentialHelper = "true"
+	token, err = FindToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Token{}, token)
+
+	opts.CredentialHelper = "echo locked >&2; exit 1"
+	_, err = FindToken(ctx, opts)
+	require.ErrorContains(t, err, "locked")
+}
+
+func TestFindToken_invalidFiles(t *testing.T) {
+	home := t.TempDir()
+	writeFile(t, filepath.Join(home, ".config", "gh", "hosts.yml"), "github.com: [")
+	writeFile(t, filepath.Join(home, ".netrc"), "default login me password fallback")
+
+	// Invalid files are skipped.
+	token, err := FindToken(context.Background(), tokenOptions(home, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", token.Value)
+}
+
+func TestParseNetrc(t *testing.T) {
+	content := `machine a.example.com login x password a
+default login y password fallback
+`
+	assert.Equal(t, "a", parseNetrc(content, "a.example.com"))
+	assert.Equal(t, "fallback", parseNetrc(content, "github.com"))
+	assert.Empty(t, parseNetrc("machine a.example.com login x", "a.example.com"))
+}
+
+func TestTokenSource(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("ORG_A_TOKEN", "env-token")
+	file := filepath.Join(t.TempDir(), "token")
+	writeFile(t, file, "file-token\n")
+
+	tests := []struct {
+		source TokenSource
+		want   string
+		name   string
+	}{
+		{TokenSource{Env: "ORG_A_TOKEN"}, "env-token", "env ORG_A_TOKEN"},
+		{TokenSource{Env: "UNSET_TOKEN_FOR_TEST"}, "", "env UNSET_TOKEN_FOR_TEST"},
+		{TokenSource{File: file}, "file-token", "file " + file},
+		{TokenSource{Command: `echo "cmd-$GHA_FIX_GITHUB_HOST"`}, "cmd-ghe.example.com", "command `echo \"cmd-$GHA_FIX_GITHUB_HOST\"`"},
+	}
+	for _, tt := range tests {
+		got, err := tt.source.Token(ctx, "https://ghe.example.com/api/v3/")
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.Equal(t, tt.name, tt.source.String())
+	}
+
+	_, err := TokenSource{File: filepath.Join(t.TempDir(), "missing")}.Token(ctx, "")
+	require.Error(t, err)
+	_, err = TokenSource{Command: "exit 1"}.Token(ctx, "")
+	require.ErrorContains(t, err, "token command failed")
+}
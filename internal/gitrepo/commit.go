@@ -0,0 +1,51 @@
+package gitrepo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// CheckBranchName reports an error if name is not a valid branch name or the branch already exists.
+func (r Repo) CheckBranchName(ctx context.Context, name string) error {
+	if _, err := git(ctx, r.Root, nil, "check-ref-format", "--branch", name); err != nil {
+		return errors.Wrapf(err, "invalid branch name: %s", name)
+	}
+	if _, err := git(ctx, r.Root, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+name); err == nil {
+		return errors.Newf("branch already exists: %s", name)
+	}
+	return nil
+}
+
+// CreateBranch creates the branch name at HEAD and switches to it. Changes in the working tree and the index are
+// kept.
+func (r Repo) CreateBranch(ctx context.Context, name string) error {
+	if _, err := git(ctx, r.Root, nil, "switch", "--create", name); err != nil {
+		return errors.Wrapf(err, "failed to create branch: %s", name)
+	}
+	return nil
+}
+
+// CommitFiles commits the working tree content of paths with message and returns the commit SHA. paths are absolute
+// or relative to Root. Other staged changes are left staged and not committed.
+func (r Repo) CommitFiles(ctx context.Context, paths []string, message string) (string, error) {
+	if len(paths) == 0 {
+		return "", errors.New("no files to commit")
+	}
+
+	// Adding first makes new files known to git, which committing with a pathspec requires.
+	if _, err := git(ctx, r.Root, nil, append([]string{"add", "--"}, paths...)...); err != nil {
+		return "", errors.Wrap(err, "failed to stage files")
+	}
+	args := append([]string{"commit", "--quiet", "--file=-", "--only", "--"}, paths...)
+	if _, err := git(ctx, r.Root, []byte(message), args...); err != nil {
+		return "", errors.Wrap(err, "failed to commit")
+	}
+
+	out, err := git(ctx, r.Root, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read commit SHA")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,71 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// marker identifies hook scripts installed by gha-fix, so that re-installing can replace them.
+const marker = "# Installed by `gha-fix hook install`."
+
+// ErrHookExists is returned by Install when a pre-commit hook that was not installed by gha-fix exists.
+var ErrHookExists = errors.New("pre-commit hook already exists")
+
+// HookPath returns the path of the pre-commit hook, honoring core.hooksPath and linked worktrees.
+func (r Repo) HookPath(ctx context.Context) (string, error) {
+	out, err := git(ctx, r.Root, nil, "rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to locate hooks directory")
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Root, path)
+	}
+	return path, nil
+}
+
+// Script returns the pre-commit hook script running `gha-fix hook run` with args.
+func Script(args []string) string {
+	command := "gha-fix hook run"
+	if len(args) > 0 {
+		command += " " + strings.Join(args, " ")
+	}
+	return "#!/bin/sh\n" + marker + "\nexec " + command + "\n"
+}
+
+// Install writes script as the pre-commit hook. An existing hook is only replaced if it was installed by gha-fix
+// or force is set. Returns the path of the hook.
+func (r Repo) Install(ctx context.Context, script string, force bool) (string, error) {
+	path, err := r.HookPath(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if !force && !strings.Contains(string(existing), marker) {
+			return "", errors.Wrapf(ErrHookExists, "%s (use --force to overwrite)", path)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return "", errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", errors.WithStack(err)
+	}
+	//nolint:gosec // git only runs executable hooks
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return "", errors.WithStack(err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	//nolint:gosec // git only runs executable hooks
+	if err := os.Chmod(path, 0o755); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return path, nil
+}
@@ -0,0 +1,106 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	gogithub "github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+)
+
+// DefaultMirrorTemplate is the path of the mirror of a repository relative to the mirror directory, as created by
+// `git clone --mirror https://github.com/{owner}/{repo}.git`.
+const DefaultMirrorTemplate = "{owner}/{repo}.git"
+
+// MirrorService is a pin.RepositoryService reading the tags and branches of action repositories from local git
+// mirrors instead of the GitHub API, e.g. in environments without API access. Mirrors are usually bare repositories,
+// but any git directory works.
+type MirrorService struct {
+	dir      string
+	template string
+}
+
+// NewMirrorService creates a MirrorService finding the mirror of owner/repo at template in dir, with {owner} and
+// {repo} replaced. An empty template is DefaultMirrorTemplate.
+func NewMirrorService(dir, template string) MirrorService {
+	if template == "" {
+		template = DefaultMirrorTemplate
+	}
+	return MirrorService{dir: dir, template: template}
+}
+
+// path returns the git directory of the mirror of owner/repo.
+func (m MirrorService) path(owner, repo string) (string, error) {
+	for _, name := range []string{owner, repo} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", errors.Newf("invalid repository name: %s/%s", owner, repo)
+		}
+	}
+	rel := strings.NewReplacer("{owner}", owner, "{repo}", repo).Replace(m.template)
+	path := filepath.Join(m.dir, filepath.FromSlash(rel))
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Mark(errors.Wrapf(err, "no mirror of %s/%s", owner, repo), exitcode.ErrRemote)
+	}
+	return path, nil
+}
+
+// mirrorGit runs git on the mirror at path. GIT_DIR is set instead of running in the directory, so mirrors owned by
+// another user, e.g. a shared read-only directory, don't fail git's safe.directory check. Errors are marked with
+// exitcode.ErrRemote as the mirror stands in for the API.
+func mirrorGit(ctx context.Context, path string, args ...string) ([]byte, error) {
+	out, err := gitEnv(ctx, "", []string{"GIT_DIR=" + path}, nil, args...)
+	if err != nil {
+		return nil, errors.Mark(err, exitcode.ErrRemote)
+	}
+	return out, nil
+}
+
+// ListTags returns all tags of the mirror in one page, newest version first like the API. Annotated tags are
+// peeled to their commits. opts is ignored.
+func (m MirrorService) ListTags(ctx context.Context, owner string, repo string, _ *gogithub.ListOptions) ([]*gogithub.RepositoryTag, *gogithub.Response, error) {
+	path, err := m.path(owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := mirrorGit(ctx, path, "for-each-ref", "--sort=-v:refname", "--format=%(refname:strip=2)%00%(objectname)%00%(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list tags of mirror %s", path)
+	}
+
+	var tags []*gogithub.RepositoryTag
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 3 {
+			continue
+		}
+		sha := fields[2]
+		if sha == "" {
+			sha = fields[1]
+		}
+		tags = append(tags, &gogithub.RepositoryTag{
+			Name:   gogithub.Ptr(fields[0]),
+			Commit: &gogithub.Commit{SHA: gogithub.Ptr(sha)},
+		})
+	}
+	return tags, &gogithub.Response{}, nil
+}
+
+// GetCommitSHA1 returns the commit of ref, a branch, tag or commit SHA, in the mirror. lastSHA is ignored.
+func (m MirrorService) GetCommitSHA1(ctx context.Context, owner, repo, ref, _ string) (string, *gogithub.Response, error) {
+	path, err := m.path(owner, repo)
+	if err != nil {
+		return "", nil, err
+	}
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", nil, errors.Newf("invalid ref: %s", ref)
+	}
+	out, err := mirrorGit(ctx, path, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "ref %s not found in mirror %s", ref, path)
+	}
+	return strings.TrimSpace(string(out)), &gogithub.Response{}, nil
+}
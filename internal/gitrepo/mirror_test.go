@@ -0,0 +1,89 @@
+package gitrepo
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/exitcode"
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+// initMirror creates a bare mirror of actions/checkout in a new mirror directory with the tags v4.1.0 (annotated)
+// and v4.2.0 on the first commit, v4.2.1 and v5.0.0-beta on the second, and main at the second. Returns the
+// directory and the two commits.
+func initMirror(t *testing.T) (string, string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	src := t.TempDir()
+	runGit(t, src, "init", "--quiet", "--initial-branch=main")
+	commit := func(message string) string {
+		runGit(t, src, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "--allow-empty", "-m", message)
+		return strings.TrimSpace(runGit(t, src, "rev-parse", "HEAD"))
+	}
+	first := commit("first")
+	runGit(t, src, "-c", "user.name=test", "-c", "user.email=test@example.com", "tag", "-a", "v4.1.0", "-m", "v4.1.0")
+	runGit(t, src, "tag", "v4.2.0")
+	second := commit("second")
+	runGit(t, src, "tag", "v4.2.1")
+	runGit(t, src, "tag", "v5.0.0-beta")
+
+	dir := t.TempDir()
+	out, err := exec.Command("git", "clone", "--quiet", "--mirror", src, filepath.Join(dir, "actions", "checkout.git")).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return dir, first, second
+}
+
+func TestMirrorService(t *testing.T) {
+	ctx := context.Background()
+	dir, first, second := initMirror(t)
+	mirror := NewMirrorService(dir, "")
+
+	tags, resp, err := mirror.ListTags(ctx, "actions", "checkout", nil)
+	require.NoError(t, err)
+	assert.Zero(t, resp.NextPage)
+	got := map[string]string{}
+	var names []string
+	for _, tag := range tags {
+		got[tag.GetName()] = tag.GetCommit().GetSHA()
+		names = append(names, tag.GetName())
+	}
+	assert.Equal(t, []string{"v5.0.0-beta", "v4.2.1", "v4.2.0", "v4.1.0"}, names)
+	// Annotated tags are peeled to their commits.
+	assert.Equal(t, map[string]string{"v4.1.0": first, "v4.2.0": first, "v4.2.1": second, "v5.0.0-beta": second}, got)
+
+	sha, _, err := mirror.GetCommitSHA1(ctx, "actions", "checkout", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, second, sha)
+	sha, _, err = mirror.GetCommitSHA1(ctx, "actions", "checkout", "v4.1.0", "")
+	require.NoError(t, err)
+	assert.Equal(t, first, sha)
+
+	_, _, err = mirror.GetCommitSHA1(ctx, "actions", "checkout", "missing", "")
+	require.ErrorContains(t, err, "ref missing not found in mirror")
+	assert.Equal(t, exitcode.Remote, exitcode.FromError(err))
+	_, _, err = mirror.GetCommitSHA1(ctx, "actions", "checkout", "--output=x", "")
+	require.ErrorContains(t, err, "invalid ref")
+	_, _, err = mirror.ListTags(ctx, "actions", "setup-go", nil)
+	require.ErrorContains(t, err, "no mirror of actions/setup-go")
+	assert.Equal(t, exitcode.Remote, exitcode.FromError(err))
+	_, _, err = mirror.ListTags(ctx, "..", "checkout", nil)
+	require.ErrorContains(t, err, "invalid repository name")
+
+	// Versions are resolved from mirrors like from the API.
+	resolver := pin.NewVersionResolver(mirror)
+	resolved, err := resolver.ResolveVersion(ctx, pin.ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "v4"})
+	require.NoError(t, err)
+	assert.Equal(t, pin.ResolvedVersion{CommitSHA: second, RefComment: "v4.2.1"}, resolved)
+
+	mirror = NewMirrorService(dir, "{owner}/{repo}")
+	_, _, err = mirror.ListTags(ctx, "actions", "checkout", nil)
+	require.ErrorContains(t, err, "no mirror of actions/checkout")
+}
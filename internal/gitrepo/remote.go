@@ -0,0 +1,172 @@
+package gitrepo
+
+import (
+	"context"
+	"encoding/base64"
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Remote identifies a repository on a git hosting service.
+type Remote struct {
+	// Host is the host name without port, e.g. "github.com".
+	Host  string
+	Owner string
+	Repo  string
+	// HTTPS is true for https:// remote URLs, which can be authenticated with a token.
+	HTTPS bool
+}
+
+// ParseRemoteURL parses remote URLs like:
+//
+//   - https://github.com/Finatext/gha-fix.git
+//   - git@github.com:Finatext/gha-fix.git
+//   - ssh://git@github.com/Finatext/gha-fix
+//
+// Returns false for local paths and URLs without an owner and a repository.
+func ParseRemoteURL(url string) (Remote, bool) {
+	var remote Remote
+	if scheme, rest, ok := strings.Cut(url, "://"); ok {
+		remote.HTTPS = scheme == "https"
+		url = rest
+	} else if strings.Contains(url, ":") {
+		// scp-like syntax: git@github.com:owner/repo.git
+		url = strings.Replace(url, ":", "/", 1)
+	} else {
+		return Remote{}, false
+	}
+
+	parts := strings.Split(strings.Trim(url, "/"), "/")
+	if len(parts) < 3 {
+		return Remote{}, false
+	}
+	host := parts[0]
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	host, _, _ = strings.Cut(host, ":")
+
+	remote.Host = host
+	remote.Owner = parts[len(parts)-2]
+	remote.Repo = strings.TrimSuffix(parts[len(parts)-1], ".git")
+	if remote.Host == "" || remote.Owner == "" || remote.Repo == "" {
+		return Remote{}, false
+	}
+	return remote, true
+}
+
+// RemoteURL returns the URL of the remote name, e.g. "origin".
+func (r Repo) RemoteURL(ctx context.Context, name string) (string, error) {
+	out, err := git(ctx, r.Root, nil, "remote", "get-url", name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get URL of remote: %s", name)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CurrentBranch returns the name of the checked out branch. Fails if HEAD is detached.
+func (r Repo) CurrentBranch(ctx context.Context) (string, error) {
+	out, err := git(ctx, r.Root, nil, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "not on a branch")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Push pushes branch to the branch of the same name on remote and sets it as the upstream. With force, the remote
+// branch is overwritten if it's at the commit last fetched (--force-with-lease). See credentialEnv for token.
+func (r Repo) Push(ctx context.Context, remote, branch string, force bool, token string) error {
+	env, err := r.credentialEnv(ctx, remote, token)
+	if err != nil {
+		return err
+	}
+	args := []string{"push", "--quiet", "--set-upstream"}
+	if force {
+		args = append(args, "--force-with-lease")
+	}
+	args = append(args, remote, "refs/heads/"+branch+":refs/heads/"+branch)
+	if _, err := gitEnv(ctx, r.Root, env, nil, args...); err != nil {
+		return errors.Wrapf(err, "failed to push branch: %s", branch)
+	}
+	return nil
+}
+
+// Fetch fetches branch from remote and returns the SHA of its head commit. See credentialEnv for token.
+func (r Repo) Fetch(ctx context.Context, remote, branch, token string) (string, error) {
+	env, err := r.credentialEnv(ctx, remote, token)
+	if err != nil {
+		return "", err
+	}
+	if _, err := gitEnv(ctx, r.Root, env, nil, "fetch", "--quiet", remote, "refs/heads/"+branch); err != nil {
+		return "", errors.Wrapf(err, "failed to fetch branch: %s", branch)
+	}
+	out, err := git(ctx, r.Root, nil, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read fetched commit")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ChangedFiles returns the slash-separated paths of files added or modified on head since it diverged from base.
+func (r Repo) ChangedFiles(ctx context.Context, base, head string) ([]string, error) {
+	out, err := git(ctx, r.Root, nil, "diff", "--name-only", "--diff-filter=AM", "-z", base+"..."+head)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s with %s", head, base)
+	}
+	var files []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// FileAt returns the content of path at rev. Returns false if path does not exist at rev.
+func (r Repo) FileAt(ctx context.Context, rev, path string) ([]byte, bool, error) {
+	if _, err := git(ctx, r.Root, nil, "cat-file", "-e", rev+":"+path); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	out, err := git(ctx, r.Root, nil, "cat-file", "blob", rev+":"+path)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read %s at %s", path, rev)
+	}
+	return out, true, nil
+}
+
+// credentialEnv returns environment variables authenticating git over HTTPS to remote with token, the way
+// actions/checkout does. The token is passed as configuration in the environment so that it does not appear in
+// process arguments.
+//
+// Returns nil if token is empty, the remote does not use HTTPS, or an authorization header is already configured,
+// e.g. by actions/checkout: sending two would fail the request.
+func (r Repo) credentialEnv(ctx context.Context, remote, token string) ([]string, error) {
+	if token == "" {
+		return nil, nil
+	}
+	url, err := r.RemoteURL(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	parsed, ok := ParseRemoteURL(url)
+	if !ok || !parsed.HTTPS {
+		return nil, nil
+	}
+	// Exit status 1 means no matching key.
+	if out, err := git(ctx, r.Root, nil, "config", "--get-regexp", `^http\..*\.extraheader$`); err == nil && len(out) > 0 {
+		return nil, nil
+	}
+
+	credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://" + parsed.Host + "/.extraheader",
+		"GIT_CONFIG_VALUE_0=AUTHORIZATION: basic " + credentials,
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Package gitrepo runs git commands on the repository gha-fix works in: it reads workflow files from the index
+// (the staged content) and writes fixed content back to it for the pre-commit hook, and commits fixes to a new
+// branch.
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+// Repo is a git repository with a working tree.
+type Repo struct {
+	// Root is the top-level directory of the working tree. Paths of staged files are relative to it.
+	Root string
+}
+
+// Open returns the repository containing dir.
+func Open(ctx context.Context, dir string) (Repo, error) {
+	out, err := git(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return Repo{}, errors.Wrap(err, "not in a git working tree")
+	}
+	return Repo{Root: strings.TrimSpace(string(out))}, nil
+}
+
+// StagedWorkflowFiles returns the slash-separated paths of added, copied, modified or renamed files in the index
+// that are workflow files, see rewrite.IsWorkflowFile.
+func (r Repo) StagedWorkflowFiles(ctx context.Context, ignoreDirs []string) ([]string, error) {
+	out, err := git(ctx, r.Root, nil, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list staged files")
+	}
+
+	var files []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" && rewrite.IsWorkflowFile(path, ignoreDirs) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// IndexFS returns the staged content of paths as an in-memory filesystem.
+func (r Repo) IndexFS(ctx context.Context, paths []string) (*rewrite.MemFS, error) {
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		out, err := git(ctx, r.Root, nil, "cat-file", "blob", ":"+path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read staged content: %s", path)
+		}
+		files[path] = string(out)
+	}
+	return rewrite.NewMemFS(files), nil
+}
+
+// IsPartiallyStaged reports whether the working tree version of path differs from the staged one.
+func (r Repo) IsPartiallyStaged(ctx context.Context, path string) (bool, error) {
+	_, err := git(ctx, r.Root, nil, "diff", "--quiet", "--", path)
+	if err == nil {
+		return false, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, errors.Wrapf(err, "failed to compare working tree with index: %s", path)
+}
+
+// Stage replaces the staged content of path with content, keeping the file mode. The working tree is not
+// touched, so unstaged changes of partially staged files stay unstaged.
+func (r Repo) Stage(ctx context.Context, path string, content []byte) error {
+	out, err := git(ctx, r.Root, nil, "ls-files", "--stage", "-z", "--", path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read index entry: %s", path)
+	}
+	// <mode> SP <object> SP <stage> TAB <path>
+	mode, _, ok := strings.Cut(string(out), " ")
+	if !ok {
+		return errors.Newf("file is not in the index: %s", path)
+	}
+
+	sha, err := git(ctx, r.Root, content, "hash-object", "-w", "--stdin", "--path", path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write blob: %s", path)
+	}
+
+	cacheInfo := mode + "," + strings.TrimSpace(string(sha)) + "," + path
+	if _, err := git(ctx, r.Root, nil, "update-index", "--cacheinfo", cacheInfo); err != nil {
+		return errors.Wrapf(err, "failed to update index: %s", path)
+	}
+	return nil
+}
+
+func git(ctx context.Context, dir string, stdin []byte, args ...string) ([]byte, error) {
+	return gitEnv(ctx, dir, nil, stdin, args...)
+}
+
+// gitEnv runs git with env added to the environment of the current process.
+func gitEnv(ctx context.Context, dir string, env []string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Wrapf(err, "git %s: %s", args[0], msg)
+		}
+		return nil, errors.Wrapf(err, "git %s", args[0])
+	}
+	return out, nil
+}
@@ -0,0 +1,148 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+func initRepo(t *testing.T) Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet")
+
+	repo, err := Open(context.Background(), dir)
+	require.NoError(t, err)
+	return repo
+}
+
+func writeFile(t *testing.T, repo Repo, path, content string) {
+	t.Helper()
+	full := filepath.Join(repo.Root, filepath.FromSlash(path))
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0o600))
+}
+
+func TestRepo_StagedContent(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	writeFile(t, repo, ".github/workflows/ci.yml", "staged\n")
+	writeFile(t, repo, "node_modules/pkg/ci.yml", "ignored\n")
+	writeFile(t, repo, "README.md", "not a workflow\n")
+	writeFile(t, repo, "unstaged.yml", "unstaged\n")
+	runGit(t, repo.Root, "add", ".github", "node_modules", "README.md")
+	// Partially staged: the working tree has further changes.
+	writeFile(t, repo, ".github/workflows/ci.yml", "staged\nunstaged\n")
+
+	files, err := repo.StagedWorkflowFiles(ctx, []string{"node_modules"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".github/workflows/ci.yml"}, files)
+
+	index, err := repo.IndexFS(ctx, files)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{".github/workflows/ci.yml": "staged\n"}, index.Files())
+
+	partial, err := repo.IsPartiallyStaged(ctx, ".github/workflows/ci.yml")
+	require.NoError(t, err)
+	assert.True(t, partial)
+}
+
+func TestRepo_Stage(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	writeFile(t, repo, "ci.yml", "before\n")
+	runGit(t, repo.Root, "add", "ci.yml")
+	writeFile(t, repo, "ci.yml", "before\nunstaged\n")
+
+	require.NoError(t, repo.Stage(ctx, "ci.yml", []byte("fixed\n")))
+
+	assert.Equal(t, "fixed\n", runGit(t, repo.Root, "cat-file", "blob", ":ci.yml"))
+	// The working tree is not touched.
+	content, err := os.ReadFile(filepath.Join(repo.Root, "ci.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\nunstaged\n", string(content))
+	assert.Contains(t, runGit(t, repo.Root, "ls-files", "--stage", "ci.yml"), "100644 ")
+
+	require.Error(t, repo.Stage(ctx, "missing.yml", []byte("x")))
+}
+
+func TestRepo_Install(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	path, err := repo.Install(ctx, Script([]string{"--fix"}), false)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(repo.Root, ".git", "hooks", "pre-commit"), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "exec gha-fix hook run --fix\n")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0o100, "hook must be executable")
+
+	// Re-installing replaces our own hook.
+	_, err = repo.Install(ctx, Script(nil), false)
+	require.NoError(t, err)
+
+	// Other hooks are only replaced with force.
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0o600))
+	_, err = repo.Install(ctx, Script(nil), false)
+	assert.True(t, errors.Is(err, ErrHookExists), err)
+	_, err = repo.Install(ctx, Script(nil), true)
+	require.NoError(t, err)
+}
+
+func TestRepo_CommitFiles(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+	runGit(t, repo.Root, "config", "user.name", "test")
+	runGit(t, repo.Root, "config", "user.email", "test@example.com")
+
+	writeFile(t, repo, "ci.yml", "before\n")
+	writeFile(t, repo, "other.txt", "before\n")
+	runGit(t, repo.Root, "add", ".")
+	runGit(t, repo.Root, "commit", "--quiet", "-m", "init")
+
+	require.NoError(t, repo.CheckBranchName(ctx, "gha-fix/pin-20261018"))
+	require.Error(t, repo.CheckBranchName(ctx, "invalid..name"))
+
+	writeFile(t, repo, "ci.yml", "fixed\n")
+	writeFile(t, repo, "new.yml", "new\n")
+	writeFile(t, repo, "other.txt", "staged\n")
+	runGit(t, repo.Root, "add", "other.txt")
+
+	require.NoError(t, repo.CreateBranch(ctx, "gha-fix/pin-20261018"))
+	sha, err := repo.CommitFiles(ctx, []string{"ci.yml", filepath.Join(repo.Root, "new.yml")}, "Pin actions\n\nGenerated-by: gha-fix\n")
+	require.NoError(t, err)
+	assert.Len(t, sha, 40)
+
+	assert.Equal(t, "gha-fix/pin-20261018\n", runGit(t, repo.Root, "branch", "--show-current"))
+	// %B is followed by a newline.
+	assert.Equal(t, "Pin actions\n\nGenerated-by: gha-fix\n\n", runGit(t, repo.Root, "log", "-1", "--format=%B"))
+	assert.Equal(t, "ci.yml\nnew.yml\n", runGit(t, repo.Root, "show", "--name-only", "--format=", "HEAD"))
+	// Other staged changes stay staged.
+	assert.Equal(t, "other.txt\n", runGit(t, repo.Root, "diff", "--cached", "--name-only"))
+
+	err = repo.CheckBranchName(ctx, "gha-fix/pin-20261018")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "branch already exists")
+}
@@ -0,0 +1,209 @@
+package initconfig
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	"github.com/Finatext/gha-fix/internal/pin"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/workflow"
+)
+
+// DefaultTimeoutMinutes is proposed when no existing job defines a numeric timeout-minutes.
+const DefaultTimeoutMinutes = 5
+
+// Proposal is a starter configuration derived from the workflows in a repository.
+type Proposal struct {
+	// Org is the owner of the repository, detected from the git remote. Empty if unknown.
+	Org string
+	// WorkflowDirs are the directories (relative to the scanned root) that contain workflow files.
+	WorkflowDirs []string
+	IgnoreDirs   []string
+	// IgnoreOwners are action owners that belong to Org.
+	IgnoreOwners []string
+	// TimeoutMinutes is the median of the numeric timeout-minutes values found in jobs.
+	TimeoutMinutes uint64
+	// TimeoutSamples is the number of jobs TimeoutMinutes is based on.
+	TimeoutSamples int
+	// ActionCount is the number of remote action and reusable workflow references found.
+	ActionCount int
+	// StrictPinning is true when every action reference is already pinned to a commit SHA.
+	StrictPinning bool
+}
+
+// Scan walks the workflow files under root and builds a Proposal.
+func Scan(root string, ignoreDirs []string, org string) (Proposal, error) {
+	fsys := rewrite.OSFS{}
+	paths, err := rewrite.FindWorkflowFiles(fsys, root, ignoreDirs)
+	if err != nil {
+		return Proposal{}, err
+	}
+
+	p := Proposal{
+		Org:        org,
+		IgnoreDirs: ignoreDirs,
+	}
+	var timeouts []uint64
+	pinnedCount := 0
+
+	for _, path := range paths {
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return Proposal{}, errors.WithStack(err)
+		}
+		wf, err := workflow.Parse(content)
+		if err != nil {
+			// Not every YAML file is parseable as a workflow; skip broken ones instead of failing the scan.
+			continue
+		}
+		if !slices.ContainsFunc(wf.Jobs, func(job *workflow.Job) bool { return job.Mapping != nil }) {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return Proposal{}, errors.WithStack(err)
+		}
+		rel = filepath.ToSlash(rel)
+		if !slices.Contains(p.WorkflowDirs, rel) {
+			p.WorkflowDirs = append(p.WorkflowDirs, rel)
+		}
+
+		for _, job := range wf.Jobs {
+			if job.TimeoutMinutes == nil {
+				continue
+			}
+			if n, err := strconv.ParseUint(job.TimeoutMinutes.Value, 10, 64); err == nil && n > 0 {
+				timeouts = append(timeouts, n)
+			}
+		}
+		for _, ref := range wf.AllUses() {
+			if p.countUses(ref.Uses, org) {
+				pinnedCount++
+			}
+		}
+	}
+
+	slices.Sort(p.WorkflowDirs)
+	slices.Sort(p.IgnoreOwners)
+	p.TimeoutSamples = len(timeouts)
+	p.TimeoutMinutes = median(timeouts)
+	p.StrictPinning = p.ActionCount > 0 && pinnedCount == p.ActionCount
+
+	return p, nil
+}
+
+// countUses records a `uses` value and reports whether it is pinned to a commit SHA.
+func (p *Proposal) countUses(uses *workflow.Uses, org string) bool {
+	def, ok := pin.ParseActionDef(uses.Value)
+	if !ok {
+		return false
+	}
+
+	p.ActionCount++
+	if org != "" && strings.EqualFold(def.Owner, org) && !slices.Contains(p.IgnoreOwners, def.Owner) {
+		p.IgnoreOwners = append(p.IgnoreOwners, def.Owner)
+	}
+	return def.HasCommitSHA()
+}
+
+func median(values []uint64) uint64 {
+	if len(values) == 0 {
+		return DefaultTimeoutMinutes
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	// Round up so that the proposal never cuts an existing job's timeout in half.
+	return (sorted[mid-1] + sorted[mid] + 1) / 2
+}
+
+// DetectOrg returns the owner of the "origin" remote of the git repository at dir.
+// Returns an empty string if dir is not a git repository or the remote URL cannot be parsed.
+func DetectOrg(ctx context.Context, dir string) string {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+	return orgFromRemoteURL(strings.TrimSpace(string(out)))
+}
+
+// orgFromRemoteURL extracts the owner from a remote URL. See gitrepo.ParseRemoteURL for the supported formats.
+func orgFromRemoteURL(url string) string {
+	remote, ok := gitrepo.ParseRemoteURL(url)
+	if !ok {
+		return ""
+	}
+	return remote.Owner
+}
+
+// Render returns the proposal as a commented gha-fix.yaml.
+func (p Proposal) Render() string {
+	var b strings.Builder
+
+	b.WriteString("# gha-fix configuration generated by `gha-fix init`.\n")
+	b.WriteString("# Review the proposed values, then run `gha-fix config validate` after editing.\n\n")
+
+	if len(p.WorkflowDirs) > 0 {
+		b.WriteString("# Workflow files were found in these directories:\n")
+		for _, dir := range p.WorkflowDirs {
+			fmt.Fprintf(&b, "#   - %s\n", dir)
+		}
+	} else {
+		b.WriteString("# No workflow files were found.\n")
+	}
+	b.WriteString("# Directories with these names are skipped when searching for workflow files.\n")
+	b.WriteString("ignore-dirs:\n")
+	for _, dir := range p.IgnoreDirs {
+		fmt.Fprintf(&b, "  - %s\n", dir)
+	}
+	b.WriteString("\n")
+
+	b.WriteString("pin:\n")
+	switch {
+	case len(p.IgnoreOwners) > 0:
+		fmt.Fprintf(&b, "  # Actions owned by the repository's organization (%s) are not pinned.\n", p.Org)
+		b.WriteString("  ignore-owners:\n")
+		for _, owner := range p.IgnoreOwners {
+			fmt.Fprintf(&b, "    - %s\n", owner)
+		}
+	case p.Org != "":
+		fmt.Fprintf(&b, "  # No actions owned by the repository's organization (%s) were found.\n", p.Org)
+		b.WriteString("  # ignore-owners:\n")
+		fmt.Fprintf(&b, "  #   - %s\n", p.Org)
+	default:
+		b.WriteString("  # Actions from these owners are not pinned.\n")
+		b.WriteString("  # ignore-owners:\n")
+		b.WriteString("  #   - my-org\n")
+	}
+	if p.StrictPinning {
+		b.WriteString("  # Every action is already pinned to a commit SHA, so enforce GitHub's SHA pinning policy.\n")
+		b.WriteString("  strict-pinning-202508: true\n")
+	} else {
+		b.WriteString("  # Enable once every action is pinned to comply with GitHub's SHA pinning policy.\n")
+		b.WriteString("  strict-pinning-202508: false\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString("timeout:\n")
+	if p.TimeoutSamples > 0 {
+		fmt.Fprintf(&b, "  # Median of the timeout-minutes values of %d existing job(s).\n", p.TimeoutSamples)
+	} else {
+		b.WriteString("  # No existing job defines timeout-minutes, so the default is proposed.\n")
+	}
+	fmt.Fprintf(&b, "  timeout-value: %d\n", p.TimeoutMinutes)
+
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package initconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".github/workflows/ci.yml"), `on: push
+jobs:
+  test:
+    timeout-minutes: 10
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: Finatext/setup-action@v1
+      - uses: ./.github/actions/local
+  lint:
+    timeout-minutes: 30
+    runs-on: ubuntu-latest
+    steps:
+      - run: make lint
+`)
+	writeFile(t, filepath.Join(root, "ci/workflows/release.yaml"), `on: push
+jobs:
+  release:
+    timeout-minutes: ${{ inputs.timeout }}
+    runs-on: ubuntu-latest
+  call:
+    uses: finatext/workflows/.github/workflows/release.yml@main
+`)
+	writeFile(t, filepath.Join(root, "node_modules/pkg/workflow.yml"), `jobs:
+  ignored:
+    timeout-minutes: 100
+    runs-on: ubuntu-latest
+`)
+	writeFile(t, filepath.Join(root, "docs/config.yml"), "title: not a workflow\n")
+
+	p, err := Scan(root, []string{"node_modules"}, "Finatext")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{".github/workflows", "ci/workflows"}, p.WorkflowDirs)
+	assert.Equal(t, []string{"Finatext", "finatext"}, p.IgnoreOwners)
+	assert.Equal(t, uint64(20), p.TimeoutMinutes)
+	assert.Equal(t, 2, p.TimeoutSamples)
+	assert.Equal(t, 3, p.ActionCount)
+	assert.False(t, p.StrictPinning)
+}
+
+func TestScan_AllPinned(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".github/workflows/ci.yml"), `on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2
+`)
+
+	p, err := Scan(root, nil, "")
+	require.NoError(t, err)
+
+	assert.True(t, p.StrictPinning)
+	assert.Empty(t, p.IgnoreOwners)
+	assert.Equal(t, uint64(DefaultTimeoutMinutes), p.TimeoutMinutes)
+	assert.Equal(t, 0, p.TimeoutSamples)
+}
+
+func TestProposal_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		proposal Proposal
+	}{
+		{
+			name: "with org owners",
+			proposal: Proposal{
+				Org:            "Finatext",
+				WorkflowDirs:   []string{".github/workflows"},
+				IgnoreDirs:     []string{".git", "node_modules"},
+				IgnoreOwners:   []string{"Finatext"},
+				TimeoutMinutes: 15,
+				TimeoutSamples: 3,
+				StrictPinning:  true,
+			},
+		},
+		{
+			name: "nothing detected",
+			proposal: Proposal{
+				TimeoutMinutes: DefaultTimeoutMinutes,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := tt.proposal.Render()
+
+			// The generated file must pass the config schema validation.
+			cfg, err := config.Parse("gha-fix.yaml", []byte(rendered))
+			require.NoError(t, err, rendered)
+			assert.Equal(t, tt.proposal.IgnoreOwners, cfg.Pin.IgnoreOwners)
+			require.NotNil(t, cfg.Timeout.TimeoutValue)
+			assert.Equal(t, tt.proposal.TimeoutMinutes, *cfg.Timeout.TimeoutValue)
+			require.NotNil(t, cfg.Pin.StrictPinning202508)
+			assert.Equal(t, tt.proposal.StrictPinning, *cfg.Pin.StrictPinning202508)
+		})
+	}
+}
+
+func TestOrgFromRemoteURL(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/Finatext/gha-fix.git":     "Finatext",
+		"https://github.com/Finatext/gha-fix":         "Finatext",
+		"git@github.com:Finatext/gha-fix.git":         "Finatext",
+		"ssh://git@github.com/Finatext/gha-fix.git":   "Finatext",
+		"https://ghes.example.com/platform/tools.git": "platform",
+		"/srv/git/repo.git":                           "",
+		"":                                            "",
+	}
+
+	for url, expected := range tests {
+		t.Run(url, func(t *testing.T) {
+			assert.Equal(t, expected, orgFromRemoteURL(url))
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package lockfile records the commits that action references were pinned to, so that later runs, e.g. on other
+// branches, pin the same references to the same commits without calling the GitHub API.
+package lockfile
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+// DefaultPath is the default location of the lockfile, relative to the working directory.
+const DefaultPath = "gha-fix.lock"
+
+// Version is the current version of the lockfile format.
+const Version = 1
+
+// Lockfile maps action references to the versions they were resolved to.
+type Lockfile struct {
+	Version int `json:"version"`
+	// Actions are keyed by the reference as written in workflow files: owner/repo[/path]@spec. JSON objects are
+	// written with sorted keys, so diffs of the file are stable and reviewable.
+	Actions map[string]Entry `json:"actions"`
+}
+
+// Entry is a resolved reference.
+type Entry struct {
+	SHA string `json:"sha"`
+	// Tag is the tag the spec resolved to, e.g. v4.2.2 for v4, or the branch name for branches.
+	Tag string `json:"tag"`
+	// ResolvedAt is when the reference was resolved to SHA. It's kept while later resolutions agree.
+	ResolvedAt time.Time `json:"resolved_at"`
+	// Host is the GitHub instance the reference was resolved on, e.g. github.com.
+	Host string `json:"host"`
+}
+
+// New creates an empty lockfile.
+func New() Lockfile {
+	return Lockfile{Version: Version, Actions: map[string]Entry{}}
+}
+
+// Load reads a lockfile. If the file does not exist, the returned error satisfies errors.Is(err, os.ErrNotExist).
+func Load(path string) (Lockfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Lockfile{}, errors.WithStack(err)
+	}
+
+	var l Lockfile
+	if err := json.Unmarshal(content, &l); err != nil {
+		return Lockfile{}, errors.Wrapf(err, "failed to parse lockfile: %s", path)
+	}
+	if l.Version != Version {
+		return Lockfile{}, errors.Newf("unsupported lockfile version %d in %s: expected %d", l.Version, path, Version)
+	}
+	if l.Actions == nil {
+		l.Actions = map[string]Entry{}
+	}
+	return l, nil
+}
+
+// Save writes the lockfile to path. The file is meant to be committed, so it is readable by everyone like other
+// files in a repository.
+func (l Lockfile) Save(path string) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	content = append(content, '\n')
+	if err := os.WriteFile(path, content, 0o644); err != nil { //nolint:gosec
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Lock is a pin.Lock backed by a Lockfile.
+type Lock struct {
+	file    Lockfile
+	host    func(owner string) string
+	update  bool
+	now     func() time.Time
+	changed bool
+}
+
+// NewLock creates a Lock using and updating the entries of file. host returns the GitHub host actions of an owner
+// are resolved on: entries of other hosts are not used, e.g. after the owner was routed to another host. With
+// update, no entries are used, so every reference is resolved again and its entry refreshed.
+func NewLock(file Lockfile, host func(owner string) string, update bool) *Lock {
+	if file.Actions == nil {
+		file = New()
+	}
+	return &Lock{file: file, host: host, update: update, now: time.Now}
+}
+
+func (l *Lock) Lookup(def pin.ActionDef) (pin.ResolvedVersion, bool) {
+	entry, ok := l.file.Actions[def.String()]
+	if !ok || l.update || entry.Host != l.host(def.Owner) {
+		return pin.ResolvedVersion{}, false
+	}
+	return pin.ResolvedVersion{CommitSHA: entry.SHA, RefComment: entry.Tag}, true
+}
+
+func (l *Lock) Record(def pin.ActionDef, resolved pin.ResolvedVersion) {
+	key := def.String()
+	host := l.host(def.Owner)
+	if entry, ok := l.file.Actions[key]; ok && entry.SHA == resolved.CommitSHA && entry.Tag == resolved.RefComment && entry.Host == host {
+		return
+	}
+	l.file.Actions[key] = Entry{SHA: resolved.CommitSHA, Tag: resolved.RefComment, ResolvedAt: l.now().UTC(), Host: host}
+	l.changed = true
+}
+
+// Changed reports whether entries were added or changed since NewLock.
+func (l *Lock) Changed() bool {
+	return l.changed
+}
+
+// Lockfile returns the lockfile with the recorded entries.
+func (l *Lock) Lockfile() Lockfile {
+	return l.file
+}
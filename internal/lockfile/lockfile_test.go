@@ -0,0 +1,92 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/pin"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	l := New()
+	l.Actions["actions/checkout@v4"] = Entry{
+		SHA:        "11bd71901bbe5b1630ceea73d27597364c9af683",
+		Tag:        "v4.2.2",
+		ResolvedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Host:       "github.com",
+	}
+	l.Actions["actions/cache/restore@v4"] = Entry{SHA: "5a3ec84eff668545956fd18022155c47e93e2684", Tag: "v4.2.3", Host: "github.com"}
+
+	require.NoError(t, l.Save(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Keys are sorted for reviewable diffs.
+	assert.Less(t, strings.Index(string(content), "actions/cache/restore@v4"), strings.Index(string(content), "actions/checkout@v4"))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, l, loaded)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.lock"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "actions": {}}`), 0o600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "unsupported lockfile version")
+}
+
+func TestLock(t *testing.T) {
+	resolvedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	file := New()
+	file.Actions["actions/checkout@v4"] = Entry{SHA: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2", ResolvedAt: resolvedAt, Host: "github.com"}
+	file.Actions["platform/deploy@v1"] = Entry{SHA: "eef61447b9ff4aafe5dcd4e0bbf5d482be7e7871", Tag: "v1.0.0", ResolvedAt: resolvedAt, Host: "github.com"}
+	host := func(owner string) string {
+		if owner == "platform" {
+			return "ghe.example.com"
+		}
+		return "github.com"
+	}
+	now := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	lock := NewLock(file, host, false)
+	lock.now = func() time.Time { return now }
+
+	checkout := pin.ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "v4"}
+	got, ok := lock.Lookup(checkout)
+	require.True(t, ok)
+	assert.Equal(t, pin.ResolvedVersion{CommitSHA: "11bd71901bbe5b1630ceea73d27597364c9af683", RefComment: "v4.2.2"}, got)
+
+	// Entries of another host are not used.
+	deploy := pin.ActionDef{Owner: "platform", Repo: "deploy", RefOrSHA: "v1"}
+	_, ok = lock.Lookup(deploy)
+	assert.False(t, ok)
+	_, ok = lock.Lookup(pin.ActionDef{Owner: "actions", Repo: "checkout", Path: "sub", RefOrSHA: "v4"})
+	assert.False(t, ok)
+
+	// Unchanged resolutions keep their entry.
+	lock.Record(checkout, got)
+	assert.False(t, lock.Changed())
+	assert.Equal(t, resolvedAt, lock.Lockfile().Actions["actions/checkout@v4"].ResolvedAt)
+
+	lock.Record(deploy, pin.ResolvedVersion{CommitSHA: "8843d7f53bd34e3b78f2acee556ba5d53feae7c4", RefComment: "v1.1.0"})
+	assert.True(t, lock.Changed())
+	assert.Equal(t, Entry{SHA: "8843d7f53bd34e3b78f2acee556ba5d53feae7c4", Tag: "v1.1.0", ResolvedAt: now, Host: "ghe.example.com"},
+		lock.Lockfile().Actions["platform/deploy@v1"])
+
+	// Updating locks ignore all entries.
+	lock = NewLock(file, host, true)
+	_, ok = lock.Lookup(checkout)
+	assert.False(t, ok)
+
+	lock = NewLock(Lockfile{}, host, false)
+	lock.Record(checkout, got)
+	assert.Len(t, lock.Lockfile().Actions, 1)
+}
@@ -0,0 +1,121 @@
+// Package logging creates the slog handlers of the gha-fix command and defines the attribute keys shared across
+// packages, so that JSON logs can be queried by the same keys whichever package wrote them.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/phsym/console-slog"
+	"golang.org/x/term"
+)
+
+// Attribute keys used in log records.
+const (
+	// KeyFile is the slash-separated path of a workflow file.
+	KeyFile  = "file"
+	KeyOwner = "owner"
+	KeyRepo  = "repo"
+	// KeyRef is the ref of an action reference: a tag, a branch or a commit SHA.
+	KeyRef = "ref"
+	// KeySHA is a commit SHA.
+	KeySHA = "sha"
+	// KeyPage is the page number of a paginated API call.
+	KeyPage     = "page"
+	KeyDuration = "duration"
+	KeyMethod   = "method"
+	KeyURL      = "url"
+	KeyStatus   = "status"
+	// KeyHost is the GitHub API a call is sent to, e.g. its URL.
+	KeyHost = "host"
+)
+
+// Log formats.
+const (
+	// FormatConsole is a colored, human-readable format. The default.
+	FormatConsole = "console"
+	// FormatText is the logfmt-style key=value format of slog.TextHandler.
+	FormatText = "text"
+	// FormatJSON is one JSON object per line, as written by slog.JSONHandler.
+	FormatJSON = "json"
+)
+
+// Formats lists the valid log formats.
+var Formats = []string{FormatConsole, FormatText, FormatJSON}
+
+// NewHandler creates a handler writing records at level or above to w in format.
+func NewHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, error) {
+	switch format {
+	case FormatConsole:
+		noColor := true
+		if f, ok := w.(*os.File); ok {
+			noColor = !term.IsTerminal(int(f.Fd())) //nolint:gosec // File descriptors fit in int.
+		}
+		return console.NewHandler(w, &console.HandlerOptions{
+			Level:      level,
+			NoColor:    noColor,
+			TimeFormat: "2006-01-02 15:04:05.000",
+		}), nil
+	case FormatText:
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}), nil
+	default:
+		return nil, errors.Newf("invalid log format %q: must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Transport is an http.RoundTripper that logs each request with its latency and the GitHub API rate-limit headers
+// of the response at debug level.
+type Transport struct {
+	// Base is the transport making the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// Rate-limit headers of GitHub API responses, logged with their names in lower case.
+var rateLimitHeaders = []string{
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Used",
+	"X-RateLimit-Reset",
+	"X-RateLimit-Resource",
+	"Retry-After",
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx := req.Context()
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return base.RoundTrip(req) //nolint:wrapcheck // Errors are returned as is to the HTTP client.
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	attrs := []any{
+		slog.String(KeyMethod, req.Method),
+		slog.String(KeyURL, req.URL.String()),
+		slog.Duration(KeyDuration, time.Since(start)),
+	}
+	if err != nil {
+		slog.DebugContext(ctx, "API request failed", append(attrs, "error", err)...)
+		return resp, err //nolint:wrapcheck // Errors are returned as is to the HTTP client.
+	}
+
+	attrs = append(attrs, slog.Int(KeyStatus, resp.StatusCode))
+	for _, h := range rateLimitHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			attrs = append(attrs, slog.String(strings.ToLower(h), v))
+		}
+	}
+	slog.DebugContext(ctx, "API request", attrs...)
+	return resp, nil
+}
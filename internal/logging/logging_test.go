@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewHandler(&buf, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	logger := slog.New(handler)
+	logger.Debug("hidden")
+	logger.Info("file updated", KeyFile, ".github/workflows/ci.yml")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "file updated", record["msg"])
+	assert.Equal(t, ".github/workflows/ci.yml", record[KeyFile])
+
+	buf.Reset()
+	handler, err = NewHandler(&buf, FormatText, slog.LevelInfo)
+	require.NoError(t, err)
+	slog.New(handler).Info("file updated", KeyFile, "ci.yml")
+	assert.Contains(t, buf.String(), `msg="file updated" file=ci.yml`)
+
+	_, err = NewHandler(&buf, FormatConsole, slog.LevelInfo)
+	require.NoError(t, err)
+
+	_, err = NewHandler(&buf, "yaml", slog.LevelInfo)
+	require.Error(t, err)
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1760000000")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	handler, err := NewHandler(&buf, FormatJSON, slog.LevelDebug)
+	require.NoError(t, err)
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	client := &http.Client{Transport: Transport{}}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/repos/actions/checkout/tags?page=2", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "API request", record["msg"])
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Equal(t, http.MethodGet, record[KeyMethod])
+	assert.True(t, strings.HasSuffix(record[KeyURL].(string), "/repos/actions/checkout/tags?page=2"))
+	assert.InDelta(t, http.StatusNotFound, record[KeyStatus], 0)
+	assert.Contains(t, record, KeyDuration)
+	assert.Equal(t, "5000", record["x-ratelimit-limit"])
+	assert.Equal(t, "4999", record["x-ratelimit-remaining"])
+	assert.Equal(t, "1760000000", record["x-ratelimit-reset"])
+	assert.NotContains(t, record, "retry-after")
+}
@@ -0,0 +1,395 @@
+// Package lsp implements a Language Server Protocol server over stdio. It reports the findings of the pin and
+// timeout fixers as diagnostics, offers their fixes as code actions and explains pinned commit SHAs on hover.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+// source is the source of the published diagnostics.
+const source = "gha-fix"
+
+// errExitWithoutShutdown is returned by Serve when the client sends exit before shutdown.
+var errExitWithoutShutdown = errors.New("exit notification received before shutdown")
+
+// Options configures a Server.
+type Options struct {
+	// Pin checks and pins `uses` references. Code actions and hovers call the GitHub API through it.
+	Pin *pin.Pin
+	// Timeout checks and adds timeout-minutes.
+	Timeout timeout.Timeout
+	// TimeoutMinutes is the value Timeout inserts, shown in code action titles.
+	TimeoutMinutes uint64
+}
+
+// Server is a language server for workflow files. It handles one client at a time; see Serve.
+type Server struct {
+	opts     Options
+	conn     *conn
+	docs     map[string]string
+	shutdown bool
+}
+
+// NewServer creates a Server.
+func NewServer(opts Options) *Server {
+	return &Server{
+		opts: opts,
+		docs: make(map[string]string),
+	}
+}
+
+// Serve reads requests from r and writes responses to w until the client sends the exit notification or closes
+// r. Requests are handled one by one in the order they are received.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	for {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rerr *responseError
+		if errors.As(err, &rerr) {
+			if err := s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(ctx, msg)
+		if msg.ID == nil {
+			// Notifications have no response.
+			if rerr != nil {
+				slog.Warn("failed to handle notification", "method", msg.Method, "error", rerr.Message)
+			}
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, msg message) (any, *responseError) {
+	slog.Debug("handling message", "method", msg.Method)
+
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   textDocumentSyncFull,
+				HoverProvider:      true,
+				CodeActionProvider: map[string]any{"codeActionKinds": []string{CodeActionKindQuickFix}},
+			},
+			ServerInfo: ServerInfo{Name: source},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if rerr := unmarshalParams(msg, &params); rerr != nil {
+			return nil, rerr
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI, &params.TextDocument.Version)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if rerr := unmarshalParams(msg, &params); rerr != nil {
+			return nil, rerr
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Full sync: the last change holds the whole content.
+		s.docs[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI, &params.TextDocument.Version)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if rerr := unmarshalParams(msg, &params); rerr != nil {
+			return nil, rerr
+		}
+		delete(s.docs, params.TextDocument.URI)
+		// Clear the diagnostics of the closed document.
+		return nil, notifyError(s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		}))
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if rerr := unmarshalParams(msg, &params); rerr != nil {
+			return nil, rerr
+		}
+		return s.codeActions(ctx, params), nil
+	case "textDocument/hover":
+		var params HoverParams
+		if rerr := unmarshalParams(msg, &params); rerr != nil {
+			return nil, rerr
+		}
+		return s.hover(ctx, params), nil
+	}
+
+	if msg.ID == nil {
+		// Unknown notifications, e.g. "$/cancelRequest", are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func unmarshalParams(msg message, v any) *responseError {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func notifyError(err error) *responseError {
+	if err == nil {
+		return nil
+	}
+	return &responseError{Code: codeInternalError, Message: err.Error()}
+}
+
+func (s *Server) publishDiagnostics(ctx context.Context, uri string, version *int) *responseError {
+	text := s.docs[uri]
+	lines := strings.Split(text, "\n")
+
+	diagnostics := []Diagnostic{}
+	for _, f := range s.findings(ctx, text) {
+		diagnostics = append(diagnostics, diagnostic(lines, f))
+	}
+	return notifyError(s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     version,
+		Diagnostics: diagnostics,
+	}))
+}
+
+// findings runs the pin and timeout checks on text. Check errors, e.g. for YAML that is not valid while typing,
+// result in no findings for that check.
+func (s *Server) findings(ctx context.Context, text string) []rewrite.Finding {
+	pinFindings, err := s.opts.Pin.Check(ctx, text)
+	if err != nil {
+		slog.Debug("pin check failed", "error", err)
+	}
+	timeoutFindings, err := s.opts.Timeout.Check(ctx, text)
+	if err != nil {
+		slog.Debug("timeout check failed", "error", err)
+	}
+	return append(pinFindings, timeoutFindings...)
+}
+
+func diagnostic(lines []string, f rewrite.Finding) Diagnostic {
+	return Diagnostic{
+		Range:    findingRange(lines, f),
+		Severity: SeverityWarning,
+		Code:     f.Rule,
+		Source:   source,
+		Message:  f.Message,
+	}
+}
+
+// findingRange returns the range of the action reference for pin findings and of the job ID for timeout
+// findings. Falls back to the whole line without indentation.
+func findingRange(lines []string, f rewrite.Finding) Range {
+	line := f.Line - 1
+	if line < 0 || line >= len(lines) {
+		return Range{}
+	}
+	text := lines[line]
+
+	needle := f.Action
+	if f.Rule == timeout.RuleName {
+		needle = f.Job
+	}
+	start, end := -1, len(text)
+	if needle != "" {
+		start = strings.Index(text, needle)
+		end = start + len(needle)
+	}
+	if start < 0 {
+		start = len(text) - len(strings.TrimLeft(text, " \t"))
+		end = len(text)
+	}
+	return Range{
+		Start: Position{Line: line, Character: utf16Len(text[:start])},
+		End:   Position{Line: line, Character: utf16Len(text[:end])},
+	}
+}
+
+func (s *Server) codeActions(ctx context.Context, params CodeActionParams) []CodeAction {
+	uri := params.TextDocument.URI
+	text, ok := s.docs[uri]
+	if !ok {
+		return []CodeAction{}
+	}
+	lines := strings.Split(text, "\n")
+
+	actions := []CodeAction{}
+	for _, f := range s.findings(ctx, text) {
+		line := f.Line - 1
+		if line < params.Range.Start.Line || line > params.Range.End.Line {
+			continue
+		}
+
+		var action CodeAction
+		var edit TextEdit
+		switch f.Rule {
+		case pin.RuleName:
+			fixed, changed, err := s.opts.Pin.ApplyLine(ctx, text, f.Line)
+			if err != nil {
+				slog.Warn("failed to pin action", "action", f.Action, "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			action.Title = "Pin " + f.Action + " to a commit SHA"
+			edit = diffEdit(text, fixed)
+		case timeout.RuleName:
+			fixed, changed, err := s.opts.Timeout.InsertJob(ctx, text, f.Job)
+			if err != nil {
+				slog.Warn("failed to add timeout-minutes", "job", f.Job, "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			action.Title = fmt.Sprintf("Add timeout-minutes: %d", s.opts.TimeoutMinutes)
+			edit = diffEdit(text, fixed)
+		default:
+			continue
+		}
+
+		action.Kind = CodeActionKindQuickFix
+		action.Diagnostics = []Diagnostic{diagnostic(lines, f)}
+		action.IsPreferred = true
+		action.Edit = &WorkspaceEdit{Changes: map[string][]TextEdit{uri: {edit}}}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// hover explains the commit SHA under the cursor by listing the tags pointing to it.
+func (s *Server) hover(ctx context.Context, params HoverParams) *Hover {
+	text, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
+		return nil
+	}
+	line := lines[params.Position.Line]
+
+	ref, ok, err := s.opts.Pin.DescribePinned(ctx, text, params.Position.Line+1)
+	if err != nil {
+		slog.Warn("failed to look up tags", "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	start := strings.Index(line, ref.SHA)
+	if start < 0 {
+		return nil
+	}
+	r := Range{
+		Start: Position{Line: params.Position.Line, Character: utf16Len(line[:start])},
+		End:   Position{Line: params.Position.Line, Character: utf16Len(line[:start+len(ref.SHA)])},
+	}
+	if params.Position.Character < r.Start.Character || params.Position.Character > r.End.Character {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** pinned to `%s`\n\n", ref.Action, ref.SHA)
+	if len(ref.Tags) == 0 {
+		b.WriteString("No tag points to this commit.")
+	} else {
+		quoted := make([]string, 0, len(ref.Tags))
+		for _, tag := range ref.Tags {
+			quoted = append(quoted, "`"+tag+"`")
+		}
+		b.WriteString("Tags: " + strings.Join(quoted, ", "))
+		if ref.Comment != "" && !slices.Contains(ref.Tags, ref.Comment) {
+			fmt.Fprintf(&b, "\n\nThe comment `%s` does not match the tags of this commit.", ref.Comment)
+		}
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: b.String()},
+		Range:    &r,
+	}
+}
+
+// diffEdit returns a single edit that turns before into after, replacing the text between their common prefix
+// and suffix.
+func diffEdit(before, after string) TextEdit {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	for prefix > 0 && prefix < len(before) && !utf8.RuneStart(before[prefix]) {
+		prefix--
+	}
+
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(before[len(before)-suffix]) {
+		suffix--
+	}
+
+	return TextEdit{
+		Range: Range{
+			Start: offsetPosition(before, prefix),
+			End:   offsetPosition(before, len(before)-suffix),
+		},
+		NewText: after[prefix : len(after)-suffix],
+	}
+}
+
+// offsetPosition converts a byte offset in text to a Position.
+func offsetPosition(text string, offset int) Position {
+	line := strings.Count(text[:offset], "\n")
+	lineStart := strings.LastIndex(text[:offset], "\n") + 1
+	return Position{Line: line, Character: utf16Len(text[lineStart:offset])}
+}
+
+// utf16Len returns the length of s in UTF-16 code units, the unit of Position.Character.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
@@ -0,0 +1,235 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+const (
+	docURI = "file:///repo/.github/workflows/ci.yml"
+	sha422 = "11bd71901bbe5b1630ceea73d27597364c9af683"
+)
+
+const docText = `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: actions/checkout@` + sha422 + ` # v4.1.0
+`
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/actions/checkout/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"name": "v4.2.2", "commit": {"sha": %q}}, {"name": "v4.2.1", "commit": {"sha": "eef61447b9ff4aafe5dcd4e0bbf5d482be7e7871"}}]`, sha422)
+	}))
+	t.Cleanup(api.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(api.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+
+	p := pin.NewPin(client, nil, nil, false)
+	return NewServer(Options{Pin: &p, Timeout: timeout.NewTimeout(10), TimeoutMinutes: 10})
+}
+
+// session runs the server on the given messages and returns the messages it wrote.
+func session(t *testing.T, s *Server, msgs ...map[string]any) []message {
+	t.Helper()
+	var in bytes.Buffer
+	for _, m := range msgs {
+		m["jsonrpc"] = "2.0"
+		body, err := json.Marshal(m)
+		require.NoError(t, err)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, s.Serve(context.Background(), &in, &out))
+
+	var written []message
+	c := newConn(&out, nil)
+	for {
+		msg, err := c.read()
+		if err != nil {
+			break
+		}
+		written = append(written, msg)
+	}
+	return written
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params}
+}
+
+var didOpen = notification("textDocument/didOpen", map[string]any{
+	"textDocument": map[string]any{"uri": docURI, "languageId": "yaml", "version": 1, "text": docText},
+})
+
+// decode round-trips v (a response result) through JSON into out.
+func decode(t *testing.T, v any, out any) {
+	t.Helper()
+	body, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, out))
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	s := newTestServer(t)
+	written := session(t, s,
+		request(1, "initialize", map[string]any{}),
+		notification("initialized", map[string]any{}),
+		didOpen,
+		request(2, "shutdown", nil),
+		notification("exit", nil),
+	)
+	require.Len(t, written, 3)
+
+	var init InitializeResult
+	decode(t, written[0].Result, &init)
+	assert.True(t, init.Capabilities.HoverProvider)
+	assert.Equal(t, textDocumentSyncFull, init.Capabilities.TextDocumentSync)
+
+	require.Equal(t, "textDocument/publishDiagnostics", written[1].Method)
+	var diags PublishDiagnosticsParams
+	require.NoError(t, json.Unmarshal(written[1].Params, &diags))
+	assert.Equal(t, docURI, diags.URI)
+	assert.Equal(t, []Diagnostic{
+		{
+			Range:    Range{Start: Position{Line: 5, Character: 14}, End: Position{Line: 5, Character: 33}},
+			Severity: SeverityWarning,
+			Code:     pin.RuleName,
+			Source:   source,
+			Message:  "action is not pinned to a commit SHA: actions/checkout@v4",
+		},
+		{
+			Range:    Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 7}},
+			Severity: SeverityWarning,
+			Code:     timeout.RuleName,
+			Source:   source,
+			Message:  "job does not have timeout-minutes: build",
+		},
+	}, diags.Diagnostics)
+}
+
+func TestServer_CodeActions(t *testing.T) {
+	s := newTestServer(t)
+	written := session(t, s,
+		didOpen,
+		request(1, "textDocument/codeAction", map[string]any{
+			"textDocument": map[string]any{"uri": docURI},
+			"range":        Range{Start: Position{Line: 2}, End: Position{Line: 5}},
+			"context":      map[string]any{"diagnostics": []any{}},
+		}),
+	)
+	require.Len(t, written, 2)
+
+	var actions []CodeAction
+	decode(t, written[1].Result, &actions)
+	require.Len(t, actions, 2)
+
+	assert.Equal(t, "Pin actions/checkout@v4 to a commit SHA", actions[0].Title)
+	pinEdits := actions[0].Edit.Changes[docURI]
+	require.Len(t, pinEdits, 1)
+	assert.Equal(t, strings.Replace(docText, "actions/checkout@v4", "actions/checkout@"+sha422+" # v4.2.2", 1), applyEdit(docText, pinEdits[0]))
+
+	assert.Equal(t, "Add timeout-minutes: 10", actions[1].Title)
+	edits := actions[1].Edit.Changes[docURI]
+	require.Len(t, edits, 1)
+	assert.Equal(t, strings.Replace(docText, "    runs-on:", "    timeout-minutes: 10\n    runs-on:", 1), applyEdit(docText, edits[0]))
+}
+
+func TestServer_Hover(t *testing.T) {
+	s := newTestServer(t)
+	hoverAt := func(id, line, character int) map[string]any {
+		return request(id, "textDocument/hover", map[string]any{
+			"textDocument": map[string]any{"uri": docURI},
+			"position":     Position{Line: line, Character: character},
+		})
+	}
+	written := session(t, s, didOpen, hoverAt(1, 6, 40), hoverAt(2, 6, 4), hoverAt(3, 5, 30))
+	require.Len(t, written, 4)
+
+	var hover Hover
+	decode(t, written[1].Result, &hover)
+	assert.Equal(t, "markdown", hover.Contents.Kind)
+	assert.Equal(t, "**actions/checkout** pinned to `"+sha422+"`\n\nTags: `v4.2.2`\n\nThe comment `v4.1.0` does not match the tags of this commit.", hover.Contents.Value)
+	assert.Equal(t, &Range{Start: Position{Line: 6, Character: 31}, End: Position{Line: 6, Character: 71}}, hover.Range)
+
+	// Outside of the SHA, and on an unpinned reference.
+	assert.Nil(t, written[2].Result)
+	assert.Nil(t, written[3].Result)
+}
+
+func TestServer_MethodNotFound(t *testing.T) {
+	s := newTestServer(t)
+	written := session(t, s, request(1, "workspace/symbol", map[string]any{}), notification("$/cancelRequest", map[string]any{"id": 1}))
+	require.Len(t, written, 1)
+	require.NotNil(t, written[0].Error)
+	assert.Equal(t, codeMethodNotFound, written[0].Error.Code)
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	s := newTestServer(t)
+	var in bytes.Buffer
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(`{"jsonrpc":"2.0","method":"exit"}`), `{"jsonrpc":"2.0","method":"exit"}`)
+	err := s.Serve(context.Background(), &in, &bytes.Buffer{})
+	require.ErrorIs(t, err, errExitWithoutShutdown)
+}
+
+func TestDiffEdit(t *testing.T) {
+	tests := []struct {
+		before, after string
+	}{
+		{"a\nb\n", "a\nx\nb\n"},
+		{"a\nb", "a\nb\nc"},
+		{"héllo\n", "héllo wörld\n"},
+		{"same", "same"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.after, applyEdit(tt.before, diffEdit(tt.before, tt.after)))
+	}
+	assert.Equal(t, TextEdit{
+		Range:   Range{Start: Position{Line: 1}, End: Position{Line: 1}},
+		NewText: "x\n",
+	}, diffEdit("a\nb\n", "a\nx\nb\n"))
+}
+
+// applyEdit applies edit to text. Positions are converted assuming text is ASCII up to the edit or that
+// characters are in the BMP.
+func applyEdit(text string, edit TextEdit) string {
+	offset := func(p Position) int {
+		lines := strings.SplitAfter(text, "\n")
+		off := 0
+		for i := 0; i < p.Line; i++ {
+			off += len(lines[i])
+		}
+		return off + len(string([]rune(lines[p.Line])[:p.Character]))
+	}
+	return text[:offset(edit.Range.Start)] + edit.NewText + text[offset(edit.Range.End):]
+}
@@ -0,0 +1,87 @@
+package pin
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/cockroachdb/errors"
+	gogithub "github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+)
+
+// Route sends the API calls for repositories of matching owners to Service.
+type Route struct {
+	// Owners are path.Match patterns of owner names, e.g. "actions" or "my-team-*".
+	Owners []string
+	// Host identifies the API in logs and errors, e.g. its URL.
+	Host string
+	// Credential describes the token of Service in errors, e.g. "env ORG_A_TOKEN". Empty for unauthenticated calls.
+	Credential string
+	Service    RepositoryService
+}
+
+// Matches reports whether the route applies to owner. Invalid patterns never match.
+func (r Route) Matches(owner string) bool {
+	for _, pattern := range r.Owners {
+		if ok, _ := path.Match(pattern, owner); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerRouter is a RepositoryService sending each call to the first route matching the owner of the repository, or
+// to the default service, e.g. to resolve actions/* against github.com and internal owners against GitHub
+// Enterprise Server.
+type OwnerRouter struct {
+	fallback RepositoryService
+	routes   []Route
+}
+
+// NewOwnerRouter creates an OwnerRouter. Routes are tried in order.
+func NewOwnerRouter(fallback RepositoryService, routes []Route) OwnerRouter {
+	return OwnerRouter{fallback: fallback, routes: routes}
+}
+
+// Route returns the route for owner. ok is false for the default service.
+func (r OwnerRouter) Route(owner string) (Route, bool) {
+	for _, route := range r.routes {
+		if route.Matches(owner) {
+			return route, true
+		}
+	}
+	return Route{Service: r.fallback}, false
+}
+
+func (r OwnerRouter) ListTags(ctx context.Context, owner string, repo string, opts *gogithub.ListOptions) ([]*gogithub.RepositoryTag, *gogithub.Response, error) {
+	route, ok := r.Route(owner)
+	tags, resp, err := route.Service.ListTags(ctx, owner, repo, opts)
+	return tags, resp, wrapRouteError(err, resp, route, ok, owner)
+}
+
+func (r OwnerRouter) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *gogithub.Response, error) {
+	route, ok := r.Route(owner)
+	sha, resp, err := route.Service.GetCommitSHA1(ctx, owner, repo, ref, lastSHA)
+	return sha, resp, wrapRouteError(err, resp, route, ok, owner)
+}
+
+// wrapRouteError names the host and credential of routed calls in errors, as a repository missing on one host may
+// exist on another, and GitHub answers 404 for private repositories the token cannot read.
+func wrapRouteError(err error, resp *gogithub.Response, route Route, routed bool, owner string) error {
+	if routed {
+		slog.Debug("routed GitHub API call", logging.KeyOwner, owner, logging.KeyHost, route.Host, "credential", route.Credential)
+	}
+	if err == nil || !routed {
+		return err
+	}
+	if route.Credential == "" {
+		return errors.Wrapf(err, "via %s", route.Host)
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(err, "via %s with credential %s, which may not have access if the repository is private", route.Host, route.Credential)
+	}
+	return errors.Wrapf(err, "via %s with credential %s", route.Host, route.Credential)
+}
@@ -0,0 +1,66 @@
+package pin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	gogithub "github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestOwnerRouter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ghes := NewMockRepositoryService(ctrl)
+	dotcom := NewMockRepositoryService(ctrl)
+
+	router := NewOwnerRouter(ghes, []Route{
+		{Owners: []string{"actions", "docker-*"}, Host: "https://api.github.com/", Service: dotcom},
+	})
+
+	dotcom.EXPECT().
+		ListTags(gomock.Any(), "actions", "checkout", gomock.Any()).
+		Return([]*gogithub.RepositoryTag{createTag("v4.2.2", "sha1")}, &gogithub.Response{}, nil)
+	tags, _, err := router.ListTags(context.Background(), "actions", "checkout", nil)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	dotcom.EXPECT().
+		GetCommitSHA1(gomock.Any(), "docker-hub", "login", "main", "").
+		Return("", nil, errors.New("not found"))
+	_, _, err = router.GetCommitSHA1(context.Background(), "docker-hub", "login", "main", "")
+	require.EqualError(t, err, "via https://api.github.com/: not found")
+
+	ghes.EXPECT().
+		GetCommitSHA1(gomock.Any(), "platform", "setup", "main", "").
+		Return("sha2", &gogithub.Response{}, nil)
+	sha, _, err := router.GetCommitSHA1(context.Background(), "platform", "setup", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, "sha2", sha)
+
+	private := NewMockRepositoryService(ctrl)
+	router = NewOwnerRouter(ghes, []Route{
+		{Owners: []string{"org-a"}, Host: "https://api.github.com/", Credential: "env ORG_A_TOKEN", Service: private},
+	})
+	notFound := &gogithub.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	private.EXPECT().
+		ListTags(gomock.Any(), "org-a", "deploy", gomock.Any()).
+		Return(nil, notFound, errors.New("404 Not Found"))
+	_, _, err = router.ListTags(context.Background(), "org-a", "deploy", nil)
+	require.EqualError(t, err, "via https://api.github.com/ with credential env ORG_A_TOKEN, which may not have access if the repository is private: 404 Not Found")
+
+	private.EXPECT().
+		GetCommitSHA1(gomock.Any(), "org-a", "deploy", "main", "").
+		Return("", nil, errors.New("timeout"))
+	_, _, err = router.GetCommitSHA1(context.Background(), "org-a", "deploy", "main", "")
+	require.EqualError(t, err, "via https://api.github.com/ with credential env ORG_A_TOKEN: timeout")
+
+	ghes.EXPECT().
+		ListTags(gomock.Any(), "docker", "build", gomock.Any()).
+		Return(nil, nil, errors.New("not found"))
+	_, _, err = router.ListTags(context.Background(), "docker", "build", nil)
+	require.EqualError(t, err, "not found")
+}
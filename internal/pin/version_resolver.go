@@ -8,6 +8,9 @@ import (
 	"github.com/Masterminds/semver/v3"
 	"github.com/cockroachdb/errors"
 	gogithub "github.com/google/go-github/v72/github"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/workflow"
 )
 
 type ActionDef struct {
@@ -17,6 +20,30 @@ type ActionDef struct {
 	RefOrSHA string
 }
 
+// ParseActionDef parses a `uses` value like "owner/repo/path@ref". See workflow.ParseActionRef.
+func ParseActionDef(uses string) (ActionDef, bool) {
+	ref, ok := workflow.ParseActionRef(uses)
+	if !ok {
+		return ActionDef{}, false
+	}
+	return ActionDef{
+		Owner:    ref.Owner,
+		Repo:     ref.Repo,
+		Path:     ref.Path,
+		RefOrSHA: ref.Ref,
+	}, true
+}
+
+// ActionRef converts the definition to the reference type of the workflow model.
+func (a ActionDef) ActionRef() workflow.ActionRef {
+	return workflow.ActionRef{Owner: a.Owner, Repo: a.Repo, Path: a.Path, Ref: a.RefOrSHA}
+}
+
+// String returns the reference in the `uses` format: owner/repo[/path]@ref.
+func (a ActionDef) String() string {
+	return a.ActionRef().String()
+}
+
 // Check the ref is a commit SHA.
 func (a ActionDef) HasCommitSHA() bool {
 	if len(a.RefOrSHA) != 40 {
@@ -78,18 +105,48 @@ type cacheKey struct {
 	RefOrSHA string
 }
 
+// Lock keeps resolved versions across runs, e.g. in a lockfile, so that the same references resolve to the same
+// commits without calling the API.
+type Lock interface {
+	// Lookup returns the version def was resolved to by an earlier run, if any.
+	Lookup(def ActionDef) (ResolvedVersion, bool)
+	// Record is called with every version resolved, including those returned by Lookup.
+	Record(def ActionDef, resolved ResolvedVersion)
+}
+
 type VersionResolver struct {
 	repoService RepositoryService
 	cache       map[cacheKey]ResolvedVersion
+	lock        Lock
+	// locked caches the versions found in lock. Unlike cache, it's not shared with copies using other locks.
+	locked map[cacheKey]ResolvedVersion
+	// commitTags caches tag names by commit SHA for each owner/repo, see TagsForCommit.
+	commitTags map[string]map[string][]string
 }
 
 func NewVersionResolver(repoService RepositoryService) VersionResolver {
 	return VersionResolver{
 		repoService: repoService,
 		cache:       make(map[cacheKey]ResolvedVersion),
+		locked:      make(map[cacheKey]ResolvedVersion),
+		commitTags:  make(map[string]map[string][]string),
 	}
 }
 
+// SetLock makes the resolver use the versions of lock before calling the API, and record resolved versions in it.
+func (r *VersionResolver) SetLock(lock Lock) {
+	r.lock = lock
+}
+
+// WithLock returns a copy of r using lock instead of the lock of r. The copy shares the caches of r, e.g. to resolve
+// the actions of several repositories, each with its own lockfile, with the same calls.
+func (r *VersionResolver) WithLock(lock Lock) *VersionResolver {
+	c := *r
+	c.lock = lock
+	c.locked = make(map[cacheKey]ResolvedVersion)
+	return &c
+}
+
 var AlreadyResolvedError = errors.New("already resolved")
 
 func (r *VersionResolver) ResolveVersion(ctx context.Context, def ActionDef) (ResolvedVersion, error) {
@@ -103,22 +160,45 @@ func (r *VersionResolver) ResolveVersion(ctx context.Context, def ActionDef) (Re
 		RefOrSHA: def.RefOrSHA,
 	}
 
-	if cachedVersion, ok := r.cache[key]; ok {
-		return cachedVersion, nil
+	// The lock comes first: the cache may be shared with resolvers using other locks, see WithLock.
+	resolved, ok := r.locked[key]
+	if !ok && r.lock != nil {
+		if resolved, ok = r.lock.Lookup(def); ok {
+			slog.Debug("using locked version", logging.KeyOwner, def.Owner, logging.KeyRepo, def.Repo, logging.KeyRef, def.RefOrSHA,
+				logging.KeySHA, resolved.CommitSHA, "tag", resolved.RefComment)
+			r.locked[key] = resolved
+		}
 	}
+	if !ok {
+		resolved, ok = r.cache[key]
+	}
+	if !ok {
+		var err error
+		resolved, err = r.resolve(ctx, def)
+		if err != nil {
+			return ResolvedVersion{}, err
+		}
+		r.cache[key] = resolved
+	}
+	if r.lock != nil {
+		// The cache ignores the path of actions, the lock doesn't: record cache hits for other paths too.
+		r.lock.Record(def, resolved)
+	}
+	return resolved, nil
+}
 
+// resolve resolves def with the API: branches to their head commit, version specs to the latest matching tag.
+func (r *VersionResolver) resolve(ctx context.Context, def ActionDef) (ResolvedVersion, error) {
 	version := def.VersionTag()
 
 	// The ref is not a version tag, so treat it as a branch name.
 	if version == nil {
-		slog.Debug("fetching commit SHA for branch", "owner", def.Owner, "repo", def.Repo, "ref", def.RefOrSHA)
+		slog.Debug("fetching commit SHA for branch", logging.KeyOwner, def.Owner, logging.KeyRepo, def.Repo, logging.KeyRef, def.RefOrSHA)
 		sha, _, err := r.repoService.GetCommitSHA1(ctx, def.Owner, def.Repo, def.RefOrSHA, "")
 		if err != nil {
 			return ResolvedVersion{}, errors.Wrapf(err, "failed to get commit SHA for %s/%s@%s", def.Owner, def.Repo, def.RefOrSHA)
 		}
-		resolved := ResolvedVersion{CommitSHA: sha, RefComment: def.RefOrSHA}
-		r.cache[key] = resolved
-		return resolved, nil
+		return ResolvedVersion{CommitSHA: sha, RefComment: def.RefOrSHA}, nil
 	}
 
 	tags, err := r.listSemverTagsAll(ctx, def.Owner, def.Repo)
@@ -135,10 +215,31 @@ func (r *VersionResolver) ResolveVersion(ctx context.Context, def ActionDef) (Re
 		CommitSHA:  latest.gogithubTag.GetCommit().GetSHA(),
 		RefComment: latest.gogithubTag.GetName(),
 	}
-	r.cache[key] = resolved
+	slog.Debug("resolved version", logging.KeyOwner, def.Owner, logging.KeyRepo, def.Repo, logging.KeyRef, def.RefOrSHA,
+		logging.KeySHA, resolved.CommitSHA, "tag", resolved.RefComment)
 	return resolved, nil
 }
 
+// TagsForCommit returns the names of the tags pointing to the commit sha, in the order returned by the API.
+// Used to explain references that are already pinned to a commit SHA.
+func (r *VersionResolver) TagsForCommit(ctx context.Context, owner, repo, sha string) ([]string, error) {
+	key := owner + "/" + repo
+	byCommit, ok := r.commitTags[key]
+	if !ok {
+		tags, err := r.listTagsAll(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		byCommit = make(map[string][]string)
+		for _, tag := range tags {
+			commit := strings.ToLower(tag.GetCommit().GetSHA())
+			byCommit[commit] = append(byCommit[commit], tag.GetName())
+		}
+		r.commitTags[key] = byCommit
+	}
+	return byCommit[strings.ToLower(sha)], nil
+}
+
 type semverTag struct {
 	gogithubTag gogithub.RepositoryTag
 	version     semver.Version
@@ -169,7 +270,7 @@ func (r *VersionResolver) listTagsAll(ctx context.Context, owner, repo string) (
 	var allTags []*gogithub.RepositoryTag
 
 	for {
-		slog.Debug("fetching tags for version resolution", "owner", owner, "repo", repo, "page", opts.Page)
+		slog.Debug("fetching tags for version resolution", logging.KeyOwner, owner, logging.KeyRepo, repo, logging.KeyPage, opts.Page)
 		tags, resp, err := r.repoService.ListTags(ctx, owner, repo, opts)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to list tags for %s/%s", owner, repo)
@@ -243,7 +344,7 @@ func findLatestTag(definedVersion semver.Version, tags []semverTag) (semverTag,
 	}
 
 	if len(matchingTags) == 0 {
-		return semverTag{}, errors.Newf("no matching tags found for version %s", definedVersion.String())
+		return semverTag{}, errors.Mark(errors.Newf("no matching tags found for version %s", definedVersion.String()), TagNotFoundError)
 	}
 
 	// Find the highest version tag
@@ -176,6 +176,106 @@ func TestVersionResolver_ResolveVersion(t *testing.T) {
 	}
 }
 
+// mapLock is a Lock keeping versions in a map keyed by ActionDef.String.
+type mapLock map[string]ResolvedVersion
+
+func (l mapLock) Lookup(def ActionDef) (ResolvedVersion, bool) {
+	resolved, ok := l[def.String()]
+	return resolved, ok
+}
+
+func (l mapLock) Record(def ActionDef, resolved ResolvedVersion) {
+	l[def.String()] = resolved
+}
+
+func TestVersionResolver_lock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryService(ctrl)
+	// Only the reference missing from the lock is resolved with the API.
+	mockRepo.EXPECT().
+		GetCommitSHA1(gomock.Any(), "actions", "checkout", "main", "").
+		Return("11bd71901bbe5b1630ceea73d27597364c9af683", &gogithub.Response{}, nil).Times(1)
+
+	lock := mapLock{"actions/cache@v4": {CommitSHA: "5a3ec84eff668545956fd18022155c47e93e2684", RefComment: "v4.2.3"}}
+	resolver := NewVersionResolver(mockRepo)
+	resolver.SetLock(lock)
+
+	got, err := resolver.ResolveVersion(context.Background(), ActionDef{Owner: "actions", Repo: "cache", RefOrSHA: "v4"})
+	require.NoError(t, err)
+	assert.Equal(t, ResolvedVersion{CommitSHA: "5a3ec84eff668545956fd18022155c47e93e2684", RefComment: "v4.2.3"}, got)
+
+	_, err = resolver.ResolveVersion(context.Background(), ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "main"})
+	require.NoError(t, err)
+	// Cache hits are recorded under the path of the action.
+	_, err = resolver.ResolveVersion(context.Background(), ActionDef{Owner: "actions", Repo: "cache", Path: "restore", RefOrSHA: "v4"})
+	require.NoError(t, err)
+
+	assert.Equal(t, mapLock{
+		"actions/cache@v4":         {CommitSHA: "5a3ec84eff668545956fd18022155c47e93e2684", RefComment: "v4.2.3"},
+		"actions/cache/restore@v4": {CommitSHA: "5a3ec84eff668545956fd18022155c47e93e2684", RefComment: "v4.2.3"},
+		"actions/checkout@main":    {CommitSHA: "11bd71901bbe5b1630ceea73d27597364c9af683", RefComment: "main"},
+	}, lock)
+}
+
+func TestVersionResolver_WithLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryService(ctrl)
+	// Resolvers sharing the cache resolve the same reference once.
+	mockRepo.EXPECT().
+		GetCommitSHA1(gomock.Any(), "actions", "checkout", "main", "").
+		Return("11bd71901bbe5b1630ceea73d27597364c9af683", &gogithub.Response{}, nil).Times(1)
+
+	resolver := NewVersionResolver(mockRepo)
+	first := resolver.WithLock(mapLock{})
+	main := ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "main"}
+	got, err := first.ResolveVersion(context.Background(), main)
+	require.NoError(t, err)
+	assert.Equal(t, "11bd71901bbe5b1630ceea73d27597364c9af683", got.CommitSHA)
+
+	// The lock of a resolver is used before the shared cache.
+	locked := ResolvedVersion{CommitSHA: "eef61447b9ff4aafe5dcd4e0bbf5d482be7e7871", RefComment: "main"}
+	got, err = resolver.WithLock(mapLock{"actions/checkout@main": locked}).ResolveVersion(context.Background(), main)
+	require.NoError(t, err)
+	assert.Equal(t, locked, got)
+
+	// Versions found in a lock are not shared.
+	third := mapLock{}
+	got, err = resolver.WithLock(third).ResolveVersion(context.Background(), main)
+	require.NoError(t, err)
+	assert.Equal(t, "11bd71901bbe5b1630ceea73d27597364c9af683", got.CommitSHA)
+	assert.Len(t, third, 1)
+}
+
+func TestVersionResolver_TagsForCommit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryService(ctrl)
+	tags := []*gogithub.RepositoryTag{
+		createTag("v4.2.2", "11bd71901bbe5b1630ceea73d27597364c9af683"),
+		createTag("v4", "11bd71901bbe5b1630ceea73d27597364c9af683"),
+		createTag("v4.2.1", "eef61447b9ff4aafe5dcd4e0bbf5d482be7e7871"),
+	}
+	// Tags are listed once per repository.
+	mockRepo.EXPECT().
+		ListTags(gomock.Any(), "actions", "checkout", gomock.Any()).
+		Return(tags, &gogithub.Response{NextPage: 0}, nil).Times(1)
+
+	resolver := NewVersionResolver(mockRepo)
+
+	got, err := resolver.TagsForCommit(context.Background(), "actions", "checkout", "11BD71901BBE5B1630CEEA73D27597364C9AF683")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v4.2.2", "v4"}, got)
+
+	got, err = resolver.TagsForCommit(context.Background(), "actions", "checkout", "0000000000000000000000000000000000000000")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
 func TestVersionResolver_listSemverTagsAll(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -384,6 +484,58 @@ func TestActionDef_IsReusableWorkflow(t *testing.T) {
 	}
 }
 
+func TestParseActionDef(t *testing.T) {
+	tests := []struct {
+		name     string
+		uses     string
+		expected ActionDef
+		ok       bool
+	}{
+		{
+			name:     "Action with tag",
+			uses:     "actions/checkout@v4",
+			expected: ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "v4"},
+			ok:       true,
+		},
+		{
+			name:     "Action with path",
+			uses:     "oasdiff/oasdiff-action/diff@v0",
+			expected: ActionDef{Owner: "oasdiff", Repo: "oasdiff-action", Path: "diff", RefOrSHA: "v0"},
+			ok:       true,
+		},
+		{
+			name:     "Reusable workflow",
+			uses:     "Finatext/workflows-public/.github/workflows/gha-lint.yml@main",
+			expected: ActionDef{Owner: "Finatext", Repo: "workflows-public", Path: ".github/workflows/gha-lint.yml", RefOrSHA: "main"},
+			ok:       true,
+		},
+		{
+			name: "Local action",
+			uses: "./.github/actions/setup",
+		},
+		{
+			name: "Docker image",
+			uses: "docker://alpine:3.8",
+		},
+		{
+			name: "Missing ref",
+			uses: "actions/checkout",
+		},
+		{
+			name: "Missing repo",
+			uses: "actions@v4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, ok := ParseActionDef(tt.uses)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, def)
+		})
+	}
+}
+
 // Helper function to create a tag
 func createTag(name, sha string) *gogithub.RepositoryTag {
 	return &gogithub.RepositoryTag{
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/config"
+)
+
+// ExecutablePrefix is the prefix of plugin executables found on PATH: gha-fix-<name>.
+const ExecutablePrefix = "gha-fix-"
+
+// Sources of a Plugin.
+const (
+	SourceConfig = "config"
+	SourcePath   = "PATH"
+)
+
+// ErrNotFound is returned by Find for an unknown plugin.
+var ErrNotFound = errors.New("plugin not found")
+
+// Discover returns the plugins sorted by name: the entries of configs, and the gha-fix-<name> executables on PATH
+// that are not configured. Later config entries override earlier ones with the same name, and executables in
+// earlier PATH directories override later ones.
+func Discover(configs []config.PluginConfig) ([]Plugin, error) {
+	plugins := map[string]Plugin{}
+	for name, path := range scanPath() {
+		plugins[name] = Plugin{Name: name, Path: path, Source: SourcePath}
+	}
+	for _, c := range configs {
+		p, err := fromConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		plugins[c.Name] = p
+	}
+
+	sorted := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted, nil
+}
+
+// Find returns the plugin called name, see Discover.
+func Find(name string, configs []config.PluginConfig) (Plugin, error) {
+	for i := len(configs) - 1; i >= 0; i-- {
+		// Config entries with reserved names are rejected by config validation.
+		if configs[i].Name == name {
+			return fromConfig(configs[i])
+		}
+	}
+	if !config.PluginNamePattern.MatchString(name) {
+		return Plugin{}, errors.Mark(errors.Newf("invalid plugin name: %s", name), ErrNotFound)
+	}
+	if slices.Contains(config.ReservedPluginNames, name) {
+		return Plugin{}, errors.Mark(errors.Newf("plugin name %s is reserved for the built-in fixer", name), ErrNotFound)
+	}
+	path, err := exec.LookPath(ExecutablePrefix + name)
+	if err != nil {
+		return Plugin{}, errors.Mark(errors.Wrapf(err, "no plugin %s in config or %s%s on PATH", name, ExecutablePrefix, name), ErrNotFound)
+	}
+	return Plugin{Name: name, Path: path, Source: SourcePath}, nil
+}
+
+// fromConfig resolves the executable of a config entry. A command without a path separator is looked up on PATH,
+// others are used as they are: config.LoadFiles resolves relative paths from the directory of the config file.
+// Without a command, gha-fix-<name> is looked up on PATH.
+func fromConfig(c config.PluginConfig) (Plugin, error) {
+	command := c.Command
+	if command == "" {
+		command = ExecutablePrefix + c.Name
+	}
+	path := command
+	if !strings.ContainsRune(command, filepath.Separator) && !strings.ContainsRune(command, '/') {
+		found, err := exec.LookPath(command)
+		if err != nil {
+			return Plugin{}, errors.Mark(errors.Wrapf(err, "command of plugin %s not found", c.Name), ErrNotFound)
+		}
+		path = found
+	}
+	return Plugin{Name: c.Name, Path: path, Options: c.Options, Source: SourceConfig}, nil
+}
+
+// scanPath returns the paths of the plugin executables on PATH by plugin name. Executables with the names of
+// built-in fixers, e.g. gha-fix-pin, are ignored.
+func scanPath() map[string]string {
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), ExecutablePrefix)
+			if !ok {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if _, seen := found[name]; seen || !config.PluginNamePattern.MatchString(name) || slices.Contains(config.ReservedPluginNames, name) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			found[name] = path
+		}
+	}
+	return found
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
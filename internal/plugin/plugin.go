@@ -0,0 +1,222 @@
+// Package plugin runs external fixers: executables that receive a workflow file as a JSON request on stdin and
+// write the edits and findings as a JSON response on stdout. Their results are handled like those of the built-in
+// fixers.
+package plugin
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/logging"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+// ProtocolVersion is the version of the request and response format. Plugins must answer with the version of the
+// request.
+const ProtocolVersion = 1
+
+// Mode tells the plugin what to do with the file.
+type Mode string
+
+const (
+	// ModeFix asks for edits fixing the file, with the changes they make and the items left unchanged.
+	ModeFix Mode = "fix"
+	// ModeCheck asks for findings without edits.
+	ModeCheck Mode = "check"
+)
+
+// Request is written to the plugin's stdin, once per file and invocation.
+type Request struct {
+	Version int  `json:"version"`
+	Mode    Mode `json:"mode"`
+	// File is the slash-separated path of the workflow file, as given to gha-fix.
+	File    string         `json:"file"`
+	Content string         `json:"content"`
+	Options map[string]any `json:"options"`
+}
+
+// Response is read from the plugin's stdout.
+type Response struct {
+	Version int `json:"version"`
+	// Edits are applied to the content in fix mode. They must not overlap.
+	Edits    []Edit    `json:"edits,omitempty"`
+	Changes  []Change  `json:"changes,omitempty"`
+	Findings []Finding `json:"findings,omitempty"`
+	// Error fails the file with this message.
+	Error string `json:"error,omitempty"`
+}
+
+// Edit replaces the lines from StartLine to EndLine (1-based, inclusive) with NewText. EndLine is StartLine - 1 to
+// insert before StartLine, and NewText is empty to delete lines. A missing final newline of NewText is added.
+type Edit struct {
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	NewText   string `json:"new_text"`
+}
+
+// Change describes an edit for reports, or an item the plugin left unchanged with Skipped as the reason. See
+// rewrite.Change. If a response has edits but no changes, each edit is reported as a change at its start line.
+type Change struct {
+	Job     string `json:"job,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Line    int    `json:"line"`
+	To      string `json:"to,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Finding is a violation reported in check mode. See rewrite.Finding.
+type Finding struct {
+	Job     string `json:"job,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Plugin is an external fixer. Its name is the rule of its changes and findings.
+type Plugin struct {
+	Name string
+	// Path is the executable.
+	Path    string
+	Options map[string]any
+	// Source is where the plugin was found: "config" or "PATH".
+	Source string
+}
+
+// Fix is a rewrite.ReportFixFunc asking the plugin to fix content. The file path is taken from ctx, see
+// rewrite.FilePath.
+func (p Plugin) Fix(ctx context.Context, content string) (string, []rewrite.Change, error) {
+	resp, err := p.call(ctx, ModeFix, content)
+	if err != nil {
+		return "", nil, err
+	}
+	fixed, err := ApplyEdits(content, resp.Edits)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "plugin %s returned invalid edits", p.Name)
+	}
+
+	changes := make([]rewrite.Change, 0, len(resp.Changes))
+	for _, c := range resp.Changes {
+		changes = append(changes, rewrite.Change{
+			Rule:    p.Name,
+			Job:     c.Job,
+			Action:  c.Action,
+			Line:    c.Line,
+			To:      c.To,
+			Tag:     c.Tag,
+			Skipped: rewrite.SkipReason(c.Skipped),
+		})
+	}
+	if len(resp.Changes) == 0 {
+		for _, e := range resp.Edits {
+			changes = append(changes, rewrite.Change{Rule: p.Name, Line: e.StartLine})
+		}
+	}
+	return fixed, changes, nil
+}
+
+// Check is a rewrite.CheckFunc asking the plugin for findings. The file path is taken from ctx, see
+// rewrite.FilePath.
+func (p Plugin) Check(ctx context.Context, content string) ([]rewrite.Finding, error) {
+	resp, err := p.call(ctx, ModeCheck, content)
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]rewrite.Finding, 0, len(resp.Findings))
+	for _, f := range resp.Findings {
+		findings = append(findings, rewrite.Finding{
+			Rule:    p.Name,
+			Job:     f.Job,
+			Action:  f.Action,
+			Line:    f.Line,
+			Message: f.Message,
+		})
+	}
+	return findings, nil
+}
+
+func (p Plugin) call(ctx context.Context, mode Mode, content string) (Response, error) {
+	req, err := json.Marshal(Request{
+		Version: ProtocolVersion,
+		Mode:    mode,
+		File:    rewrite.FilePath(ctx),
+		Content: content,
+		Options: p.Options,
+	})
+	if err != nil {
+		return Response{}, errors.Wrapf(err, "failed to encode request to plugin %s", p.Name)
+	}
+
+	// The path comes from the user's config or PATH, like any other command they run.
+	cmd := exec.CommandContext(ctx, p.Path) //nolint:gosec
+	cmd.Stdin = bytes.NewReader(req)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	slog.Debug("running plugin", "plugin", p.Name, "path", p.Path, logging.KeyFile, rewrite.FilePath(ctx), "mode", mode)
+	if err := cmd.Run(); err != nil {
+		return Response{}, errors.Wrapf(err, "plugin %s failed: %s", p.Name, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return Response{}, errors.Wrapf(err, "plugin %s wrote an invalid response", p.Name)
+	}
+	if resp.Version != ProtocolVersion {
+		return Response{}, errors.Newf("plugin %s answered with protocol version %d, expected %d", p.Name, resp.Version, ProtocolVersion)
+	}
+	if resp.Error != "" {
+		return Response{}, errors.Newf("plugin %s: %s", p.Name, resp.Error)
+	}
+	return resp, nil
+}
+
+// ApplyEdits applies edits to content. Edits are applied in the order of their start lines and must not overlap.
+func ApplyEdits(content string, edits []Edit) (string, error) {
+	if len(edits) == 0 {
+		return content, nil
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var b strings.Builder
+	// next is the 1-based line number of the first line not yet written.
+	next := 1
+	for _, e := range sortedEdits(edits) {
+		if e.StartLine < next || e.EndLine < e.StartLine-1 || e.EndLine > len(lines) {
+			return "", errors.Newf("edit of lines %d-%d is out of range or overlaps another edit", e.StartLine, e.EndLine)
+		}
+		for _, line := range lines[next-1 : e.StartLine-1] {
+			b.WriteString(line)
+		}
+		b.WriteString(e.NewText)
+		if e.NewText != "" && !strings.HasSuffix(e.NewText, "\n") {
+			b.WriteString("\n")
+		}
+		next = e.EndLine + 1
+	}
+	for _, line := range lines[next-1:] {
+		b.WriteString(line)
+	}
+	return b.String(), nil
+}
+
+// sortedEdits returns edits sorted by start line, keeping the order of insertions at the same line.
+func sortedEdits(edits []Edit) []Edit {
+	sorted := slices.Clone(edits)
+	slices.SortStableFunc(sorted, func(a, b Edit) int {
+		return cmp.Compare(a.StartLine, b.StartLine)
+	})
+	return sorted
+}
@@ -0,0 +1,204 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/config"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+const workflow = `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo hello
+`
+
+// writePlugin writes a shell script to dir that saves its request to request.json in dir and prints response.
+func writePlugin(t *testing.T, dir, name, response string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\ncat > '" + filepath.Join(dir, "request.json") + "'\ncat <<'EOF'\n" + response + "\nEOF\n"
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o700)) //nolint:gosec
+	return path
+}
+
+func readRequest(t *testing.T, dir string) Request {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	require.NoError(t, err)
+	var req Request
+	require.NoError(t, json.Unmarshal(content, &req))
+	return req
+}
+
+func TestPlugin_Fix(t *testing.T) {
+	dir := t.TempDir()
+	p := Plugin{
+		Name:    "shell",
+		Path:    writePlugin(t, dir, "gha-fix-shell", `{"version": 1, "edits": [{"start_line": 3, "end_line": 2, "new_text": "  build:\n    defaults:\n      run:\n        shell: bash"}, {"start_line": 3, "end_line": 3, "new_text": ""}], "changes": [{"job": "build", "line": 3, "to": "bash"}]}`),
+		Options: map[string]any{"shell": "bash"},
+	}
+
+	ctx := rewrite.WithFilePath(context.Background(), "./.github/workflows/ci.yml")
+	fixed, changes, err := p.Fix(ctx, workflow)
+	require.NoError(t, err)
+	assert.Equal(t, `on: push
+jobs:
+  build:
+    defaults:
+      run:
+        shell: bash
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo hello
+`, fixed)
+	assert.Equal(t, []rewrite.Change{{Rule: "shell", Job: "build", Line: 3, To: "bash"}}, changes)
+
+	assert.Equal(t, Request{
+		Version: ProtocolVersion,
+		Mode:    ModeFix,
+		File:    ".github/workflows/ci.yml",
+		Content: workflow,
+		Options: map[string]any{"shell": "bash"},
+	}, readRequest(t, dir))
+}
+
+func TestPlugin_Fix_changesFromEdits(t *testing.T) {
+	dir := t.TempDir()
+	p := Plugin{Name: "echo", Path: writePlugin(t, dir, "gha-fix-echo", `{"version": 1, "edits": [{"start_line": 6, "end_line": 6, "new_text": "      - run: echo bye"}]}`)}
+
+	fixed, changes, err := p.Fix(context.Background(), workflow)
+	require.NoError(t, err)
+	assert.Contains(t, fixed, "echo bye")
+	assert.Equal(t, []rewrite.Change{{Rule: "echo", Line: 6}}, changes)
+}
+
+func TestPlugin_Check(t *testing.T) {
+	dir := t.TempDir()
+	p := Plugin{Name: "shell", Path: writePlugin(t, dir, "gha-fix-shell", `{"version": 1, "findings": [{"job": "build", "line": 3, "message": "job does not set a default shell: build"}]}`)}
+
+	findings, err := p.Check(context.Background(), workflow)
+	require.NoError(t, err)
+	assert.Equal(t, []rewrite.Finding{{Rule: "shell", Job: "build", Line: 3, Message: "job does not set a default shell: build"}}, findings)
+	assert.Equal(t, ModeCheck, readRequest(t, dir).Mode)
+}
+
+func TestPlugin_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"invalid JSON", `not json`, "invalid response"},
+		{"version", `{"version": 2}`, "protocol version 2"},
+		{"error", `{"version": 1, "error": "cannot parse workflow"}`, "cannot parse workflow"},
+		{"edits", `{"version": 1, "edits": [{"start_line": 10, "end_line": 10, "new_text": "x"}]}`, "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Plugin{Name: "bad", Path: writePlugin(t, t.TempDir(), "gha-fix-bad", tt.response)}
+			_, _, err := p.Fix(context.Background(), workflow)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	t.Run("exit status", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("plugins are shell scripts")
+		}
+		path := filepath.Join(t.TempDir(), "gha-fix-fail")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho 'unsupported workflow' >&2\nexit 1\n"), 0o700)) //nolint:gosec
+		_, err := Plugin{Name: "fail", Path: path}.Check(context.Background(), workflow)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported workflow")
+	})
+}
+
+func TestApplyEdits(t *testing.T) {
+	content := "a\nb\nc\n"
+	tests := []struct {
+		name  string
+		edits []Edit
+		want  string
+	}{
+		{"none", nil, content},
+		{"replace", []Edit{{StartLine: 2, EndLine: 2, NewText: "B\n"}}, "a\nB\nc\n"},
+		{"replace lines", []Edit{{StartLine: 1, EndLine: 2, NewText: "x"}}, "x\nc\n"},
+		{"delete", []Edit{{StartLine: 3, EndLine: 3}}, "a\nb\n"},
+		{"insert", []Edit{{StartLine: 1, EndLine: 0, NewText: "0"}}, "0\na\nb\nc\n"},
+		{"append", []Edit{{StartLine: 4, EndLine: 3, NewText: "d"}}, "a\nb\nc\nd\n"},
+		{"unsorted", []Edit{{StartLine: 3, EndLine: 3, NewText: "C"}, {StartLine: 1, EndLine: 1, NewText: "A"}}, "A\nb\nC\n"},
+		{"inserts keep order", []Edit{{StartLine: 2, EndLine: 1, NewText: "x"}, {StartLine: 2, EndLine: 1, NewText: "y"}}, "a\nx\ny\nb\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyEdits(content, tt.edits)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, edits := range [][]Edit{
+		{{StartLine: 0, EndLine: 1}},
+		{{StartLine: 2, EndLine: 4}},
+		{{StartLine: 3, EndLine: 1}},
+		{{StartLine: 1, EndLine: 2}, {StartLine: 2, EndLine: 3}},
+	} {
+		_, err := ApplyEdits(content, edits)
+		require.Error(t, err, edits)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	writePlugin(t, first, "gha-fix-shell", `{"version": 1}`)
+	writePlugin(t, second, "gha-fix-shell", `{"version": 1}`)
+	writePlugin(t, second, "gha-fix-lint", `{"version": 1}`)
+	// The rules of built-in fixers are reserved.
+	writePlugin(t, second, "gha-fix-pin", `{"version": 1}`)
+	// Not executable.
+	require.NoError(t, os.WriteFile(filepath.Join(second, "gha-fix-data"), nil, 0o600))
+	local := writePlugin(t, t.TempDir(), "lint.sh", `{"version": 1}`)
+	t.Setenv("PATH", first+string(filepath.ListSeparator)+second)
+
+	plugins, err := Discover([]config.PluginConfig{
+		{Name: "lint", Command: "./missing"},
+		{Name: "lint", Command: local, Options: map[string]any{"level": "strict"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Plugin{
+		{Name: "lint", Path: local, Options: map[string]any{"level": "strict"}, Source: SourceConfig},
+		{Name: "shell", Path: filepath.Join(first, "gha-fix-shell"), Source: SourcePath},
+	}, plugins)
+
+	p, err := Find("shell", nil)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(first, "gha-fix-shell"), p.Path)
+
+	p, err = Find("lint", []config.PluginConfig{{Name: "lint"}})
+	require.NoError(t, err)
+	assert.Equal(t, Plugin{Name: "lint", Path: filepath.Join(second, "gha-fix-lint"), Source: SourceConfig}, p)
+
+	_, err = Find("pin", nil)
+	assert.True(t, errors.Is(err, ErrNotFound), err)
+	_, err = Find("missing", nil)
+	assert.True(t, errors.Is(err, ErrNotFound), err)
+	_, err = Discover([]config.PluginConfig{{Name: "other", Command: "gha-fix-other"}})
+	assert.True(t, errors.Is(err, ErrNotFound), err)
+}
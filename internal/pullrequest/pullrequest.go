@@ -0,0 +1,130 @@
+// Package pullrequest opens or updates the pull request for a branch fixed by gha-fix.
+package pullrequest
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-github/v72/github"
+)
+
+// Marker identifies pull requests opened by gha-fix. It's the first line of the body, hidden when rendered.
+const Marker = "<!-- gha-fix -->"
+
+// Options describes the pull request to open or update.
+type Options struct {
+	Owner string
+	Repo  string
+	// Head is the branch with the changes, in the same repository.
+	Head string
+	// Base is the branch to merge into.
+	Base  string
+	Title string
+	// Body is the description without Marker, which is prepended.
+	Body   string
+	Labels []string
+	// Reviewers are user logins, or "org/team" for teams.
+	Reviewers []string
+	// Draft opens the pull request as a draft. Existing pull requests keep their state.
+	Draft bool
+}
+
+// Result is the opened or updated pull request.
+type Result struct {
+	Number  int
+	URL     string
+	Created bool
+}
+
+// DefaultBranch returns the default branch of the repository.
+func DefaultBranch(ctx context.Context, client *github.Client, owner, repo string) (string, error) {
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get repository %s/%s", owner, repo)
+	}
+	return r.GetDefaultBranch(), nil
+}
+
+// CreateOrUpdate opens a pull request from opts.Head into opts.Base. If gha-fix already has an open pull request for
+// opts.Head, its title and body are updated instead. Labels and reviewers are added in both cases.
+//
+// Fails if an open pull request for opts.Head exists that was not opened by gha-fix.
+func CreateOrUpdate(ctx context.Context, client *github.Client, opts Options) (Result, error) {
+	body := Marker + "\n" + opts.Body
+
+	existing, err := findOpen(ctx, client, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var pr *github.PullRequest
+	created := existing == nil
+	if created {
+		pr, _, err = client.PullRequests.Create(ctx, opts.Owner, opts.Repo, &github.NewPullRequest{
+			Title: github.Ptr(opts.Title),
+			Head:  github.Ptr(opts.Head),
+			Base:  github.Ptr(opts.Base),
+			Body:  github.Ptr(body),
+			Draft: github.Ptr(opts.Draft),
+		})
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "failed to create pull request for branch: %s", opts.Head)
+		}
+		slog.Debug("created pull request", "number", pr.GetNumber())
+	} else {
+		if !strings.HasPrefix(existing.GetBody(), Marker) {
+			return Result{}, errors.Newf("open pull request #%d for branch %s was not opened by gha-fix", existing.GetNumber(), opts.Head)
+		}
+		pr, _, err = client.PullRequests.Edit(ctx, opts.Owner, opts.Repo, existing.GetNumber(), &github.PullRequest{
+			Title: github.Ptr(opts.Title),
+			Body:  github.Ptr(body),
+			Base:  &github.PullRequestBranch{Ref: github.Ptr(opts.Base)},
+		})
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "failed to update pull request #%d", existing.GetNumber())
+		}
+		slog.Debug("updated pull request", "number", pr.GetNumber())
+	}
+
+	if len(opts.Labels) > 0 {
+		if _, _, err := client.Issues.AddLabelsToIssue(ctx, opts.Owner, opts.Repo, pr.GetNumber(), opts.Labels); err != nil {
+			return Result{}, errors.Wrapf(err, "failed to add labels to pull request #%d", pr.GetNumber())
+		}
+	}
+	if len(opts.Reviewers) > 0 {
+		if _, _, err := client.PullRequests.RequestReviewers(ctx, opts.Owner, opts.Repo, pr.GetNumber(), reviewersRequest(opts.Reviewers)); err != nil {
+			return Result{}, errors.Wrapf(err, "failed to request reviewers for pull request #%d", pr.GetNumber())
+		}
+	}
+
+	return Result{Number: pr.GetNumber(), URL: pr.GetHTMLURL(), Created: created}, nil
+}
+
+// findOpen returns the open pull request from opts.Head, or nil.
+func findOpen(ctx context.Context, client *github.Client, opts Options) (*github.PullRequest, error) {
+	prs, _, err := client.PullRequests.List(ctx, opts.Owner, opts.Repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  opts.Owner + ":" + opts.Head,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pull requests for branch: %s", opts.Head)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return prs[0], nil
+}
+
+func reviewersRequest(reviewers []string) github.ReviewersRequest {
+	var req github.ReviewersRequest
+	for _, r := range reviewers {
+		if _, team, ok := strings.Cut(r, "/"); ok {
+			req.TeamReviewers = append(req.TeamReviewers, team)
+		} else {
+			req.Reviewers = append(req.Reviewers, r)
+		}
+	}
+	return req
+}
@@ -0,0 +1,212 @@
+package pullrequest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+// fakeGitHub serves the pull request endpoints used by CreateOrUpdate for a single repository.
+type fakeGitHub struct {
+	mu        sync.Mutex
+	prs       []*github.PullRequest
+	labels    map[int][]string
+	reviewers map[int]github.ReviewersRequest
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *github.Client) {
+	t.Helper()
+	fake := &fakeGitHub{labels: map[int][]string{}, reviewers: map[int]github.ReviewersRequest{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/octo/app", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, github.Repository{DefaultBranch: github.Ptr("main")})
+	})
+	mux.HandleFunc("GET /repos/octo/app/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+		prs := []*github.PullRequest{}
+		for _, pr := range fake.prs {
+			if "octo:"+pr.GetHead().GetRef() == r.URL.Query().Get("head") {
+				prs = append(prs, pr)
+			}
+		}
+		writeJSON(t, w, http.StatusOK, prs)
+	})
+	mux.HandleFunc("POST /repos/octo/app/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var req github.NewPullRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		number := len(fake.prs) + 1
+		pr := &github.PullRequest{
+			Number:  github.Ptr(number),
+			Title:   req.Title,
+			Body:    req.Body,
+			Draft:   req.Draft,
+			Head:    &github.PullRequestBranch{Ref: req.Head},
+			Base:    &github.PullRequestBranch{Ref: req.Base},
+			HTMLURL: github.Ptr("https://github.com/octo/app/pull/" + strconv.Itoa(number)),
+		}
+		fake.prs = append(fake.prs, pr)
+		writeJSON(t, w, http.StatusCreated, pr)
+	})
+	mux.HandleFunc("PATCH /repos/octo/app/pulls/{number}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var req struct {
+			Title string `json:"title"`
+			Body  string `json:"body"`
+			Base  string `json:"base"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		pr := fake.prs[atoi(t, r.PathValue("number"))-1]
+		pr.Title = github.Ptr(req.Title)
+		pr.Body = github.Ptr(req.Body)
+		pr.Base = &github.PullRequestBranch{Ref: github.Ptr(req.Base)}
+		writeJSON(t, w, http.StatusOK, pr)
+	})
+	mux.HandleFunc("POST /repos/octo/app/issues/{number}/labels", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var labels []string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&labels))
+		number := atoi(t, r.PathValue("number"))
+		fake.labels[number] = append(fake.labels[number], labels...)
+		writeJSON(t, w, http.StatusOK, []*github.Label{})
+	})
+	mux.HandleFunc("POST /repos/octo/app/pulls/{number}/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var req github.ReviewersRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		fake.reviewers[atoi(t, r.PathValue("number"))] = req
+		writeJSON(t, w, http.StatusCreated, fake.prs[atoi(t, r.PathValue("number"))-1])
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+	return fake, client
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	require.NoError(t, err)
+	return n
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	assert.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestCreateOrUpdate(t *testing.T) {
+	ctx := t.Context()
+	fake, client := newFakeGitHub(t)
+
+	base, err := DefaultBranch(ctx, client, "octo", "app")
+	require.NoError(t, err)
+	assert.Equal(t, "main", base)
+
+	opts := Options{
+		Owner:     "octo",
+		Repo:      "app",
+		Head:      "gha-fix/pin-20261018",
+		Base:      base,
+		Title:     "Pin GitHub Actions to commit SHAs",
+		Body:      "## gha-fix\n",
+		Labels:    []string{"dependencies"},
+		Reviewers: []string{"alice", "octo/platform"},
+		Draft:     true,
+	}
+	result, err := CreateOrUpdate(ctx, client, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Number: 1, URL: "https://github.com/octo/app/pull/1", Created: true}, result)
+
+	require.Len(t, fake.prs, 1)
+	assert.Equal(t, Marker+"\n## gha-fix\n", fake.prs[0].GetBody())
+	assert.True(t, fake.prs[0].GetDraft())
+	assert.Equal(t, []string{"dependencies"}, fake.labels[1])
+	assert.Equal(t, github.ReviewersRequest{Reviewers: []string{"alice"}, TeamReviewers: []string{"platform"}}, fake.reviewers[1])
+
+	// Running again updates the same pull request.
+	opts.Title = "Pin GitHub Actions to commit SHAs and add timeout-minutes to jobs"
+	opts.Body = "## gha-fix\n\nupdated\n"
+	opts.Labels = nil
+	opts.Reviewers = nil
+	result, err = CreateOrUpdate(ctx, client, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Number: 1, URL: "https://github.com/octo/app/pull/1", Created: false}, result)
+
+	require.Len(t, fake.prs, 1)
+	assert.Equal(t, opts.Title, fake.prs[0].GetTitle())
+	assert.Equal(t, Marker+"\n## gha-fix\n\nupdated\n", fake.prs[0].GetBody())
+	assert.Equal(t, []string{"dependencies"}, fake.labels[1])
+
+	// Pull requests not opened by gha-fix are left alone.
+	fake.prs[0].Body = github.Ptr("hand-written")
+	_, err = CreateOrUpdate(ctx, client, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was not opened by gha-fix")
+	assert.Equal(t, "hand-written", fake.prs[0].GetBody())
+}
+
+func TestCompare(t *testing.T) {
+	before := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: actions/setup-go@v5
+      - uses: my-org/setup@v1
+  call:
+    uses: octo/workflows/.github/workflows/ci.yml@main
+  new: {}
+`
+	after := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    timeout-minutes: 30
+    steps:
+      - uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2
+      - uses: actions/setup-go@v5
+      - uses: my-org/setup@v2
+  call:
+    uses: octo/workflows/.github/workflows/ci.yml@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b
+`
+	assert.Equal(t, []rewrite.Change{
+		{Rule: "timeout", File: "ci.yml", Job: "build", Line: 3, To: "30"},
+		{Rule: "pin", File: "ci.yml", Job: "build", Action: "actions/checkout@v4", Line: 6, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+		{Rule: "pin", File: "ci.yml", Job: "call", Action: "octo/workflows/.github/workflows/ci.yml@main", Line: 10, To: "0aaccfd150d50ccaeb58ebd88d36e91967a5f35b"},
+	}, Compare("ci.yml", []byte(before), []byte(after)))
+
+	assert.Empty(t, Compare("ci.yml", []byte("jobs: ["), []byte(after)))
+}
+
+func TestTitle(t *testing.T) {
+	assert.Equal(t, "Fix GitHub Actions workflows", Title(nil))
+	assert.Equal(t, "Pin GitHub Actions to commit SHAs", Title([]rewrite.Change{{Rule: "pin"}}))
+	assert.Equal(t, "Add timeout-minutes to jobs", Title([]rewrite.Change{{Rule: "timeout"}}))
+	assert.Equal(t, "Pin GitHub Actions to commit SHAs and add timeout-minutes to jobs", Title([]rewrite.Change{{Rule: "timeout"}, {Rule: "pin"}}))
+}
@@ -0,0 +1,149 @@
+package pullrequest
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/Finatext/gha-fix/internal/gitrepo"
+	internalpin "github.com/Finatext/gha-fix/internal/pin"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+	"github.com/Finatext/gha-fix/workflow"
+)
+
+// Changes compares the workflow files changed on head since it diverged from base, and returns the fixes found in
+// them: actions pinned to commit SHAs and jobs given timeout-minutes. Other changes are not reported.
+func Changes(ctx context.Context, repo gitrepo.Repo, base, head string) ([]rewrite.Change, error) {
+	files, err := repo.ChangedFiles(ctx, base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []rewrite.Change
+	for _, file := range files {
+		if ext := path.Ext(file); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		before, ok, err := repo.FileAt(ctx, base, file)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// New files have nothing to compare with.
+			continue
+		}
+		after, _, err := repo.FileAt(ctx, head, file)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Compare(file, before, after)...)
+	}
+	return changes, nil
+}
+
+// Compare returns the fixes made to a workflow file between before and after. Steps are matched by job ID and
+// position, so changes to steps of a job whose number of steps changed are not reported. Files that are not valid
+// workflows have no changes.
+func Compare(file string, before, after []byte) []rewrite.Change {
+	old, err := workflow.Parse(before)
+	if err != nil {
+		return nil
+	}
+	updated, err := workflow.Parse(after)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(after), "\n")
+
+	var changes []rewrite.Change
+	for _, job := range updated.Jobs {
+		prev := old.Job(job.ID)
+		if prev == nil {
+			continue
+		}
+
+		if prev.TimeoutMinutes == nil && job.TimeoutMinutes != nil {
+			changes = append(changes, rewrite.Change{
+				Rule: timeout.RuleName,
+				File: file,
+				Job:  job.ID,
+				Line: prev.Pos.Line,
+				To:   job.TimeoutMinutes.Value,
+			})
+		}
+
+		if change, ok := comparePin(file, job.ID, prev.Uses, job.Uses, lines); ok {
+			changes = append(changes, change)
+		}
+		if len(prev.Steps) != len(job.Steps) {
+			continue
+		}
+		for i, step := range job.Steps {
+			if change, ok := comparePin(file, job.ID, prev.Steps[i].Uses, step.Uses, lines); ok {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// comparePin reports a pin change if the same action is referenced by a commit SHA in after but not in before.
+// lines are the lines of the updated content, whose comment after the SHA is reported as the tag.
+func comparePin(file, job string, before, after *workflow.Uses, lines []string) (rewrite.Change, bool) {
+	if before == nil || after == nil {
+		return rewrite.Change{}, false
+	}
+	old, ok := internalpin.ParseActionDef(before.Value)
+	if !ok {
+		return rewrite.Change{}, false
+	}
+	updated, ok := internalpin.ParseActionDef(after.Value)
+	if !ok || old.Owner != updated.Owner || old.Repo != updated.Repo || old.Path != updated.Path {
+		return rewrite.Change{}, false
+	}
+	if old.HasCommitSHA() || !updated.HasCommitSHA() {
+		return rewrite.Change{}, false
+	}
+
+	change := rewrite.Change{
+		Rule:   pin.RuleName,
+		File:   file,
+		Job:    job,
+		Action: before.Value,
+		Line:   before.Pos.Line,
+		To:     updated.RefOrSHA,
+	}
+	if n := after.Pos.Line; n >= 1 && n <= len(lines) {
+		if _, comment, ok := strings.Cut(lines[n-1], "#"); ok {
+			if fields := strings.Fields(comment); len(fields) > 0 {
+				change.Tag = fields[0]
+			}
+		}
+	}
+	return change, true
+}
+
+// Title returns the default pull request title for changes.
+func Title(changes []rewrite.Change) string {
+	var pinned, timeouts bool
+	for _, c := range changes {
+		switch c.Rule {
+		case pin.RuleName:
+			pinned = true
+		case timeout.RuleName:
+			timeouts = true
+		}
+	}
+	switch {
+	case pinned && timeouts:
+		return "Pin GitHub Actions to commit SHAs and add timeout-minutes to jobs"
+	case pinned:
+		return "Pin GitHub Actions to commit SHAs"
+	case timeouts:
+		return "Add timeout-minutes to jobs"
+	default:
+		return "Fix GitHub Actions workflows"
+	}
+}
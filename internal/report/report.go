@@ -0,0 +1,314 @@
+// Package report renders the results of gha-fix runs for humans, e.g. as Markdown for pull request comments and
+// GitHub Actions job summaries.
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/pin"
+	"github.com/Finatext/gha-fix/timeout"
+)
+
+// StepSummaryEnv is the environment variable GitHub Actions sets to the path of the job summary file.
+const StepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// Markdown renders changes as a heading, a one-line summary and a table per file, in the order of changes.
+func Markdown(title string, changes []rewrite.Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", title)
+
+	applied, files := 0, map[string]bool{}
+	for _, c := range changes {
+		if c.Applied() {
+			applied++
+			files[c.File] = true
+		}
+	}
+	if len(changes) == 0 {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d change(s) in %d file(s), %d skipped.\n", applied, len(files), len(changes)-applied)
+
+	for _, group := range groupByFile(changes, func(c rewrite.Change) string { return c.File }) {
+		fmt.Fprintf(&b, "\n### `%s`\n\n", group.file)
+		b.WriteString("| Line | Job | Change |\n|---:|---|---|\n")
+		for _, c := range group.items {
+			fmt.Fprintf(&b, "| %d | %s | %s |\n", c.Line, code(c.Job), describe(c))
+		}
+	}
+	return b.String()
+}
+
+// FindingsMarkdown renders findings of check mode like Markdown renders changes.
+func FindingsMarkdown(title string, findings []rewrite.Finding) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", title)
+	if len(findings) == 0 {
+		b.WriteString("No findings.\n")
+		return b.String()
+	}
+
+	groups := groupByFile(findings, func(f rewrite.Finding) string { return f.File })
+	fmt.Fprintf(&b, "%d finding(s) in %d file(s).\n", len(findings), len(groups))
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n### `%s`\n\n", group.file)
+		b.WriteString("| Line | Job | Rule | Message |\n|---:|---|---|---|\n")
+		for _, f := range group.items {
+			fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", f.Line, code(f.Job), f.Rule, escape(f.Message))
+		}
+	}
+	return b.String()
+}
+
+// RepoResult is the outcome of running the fixers in one repository. See BatchMarkdown.
+type RepoResult struct {
+	// Repo identifies the repository, e.g. the path of its checkout.
+	Repo string
+	// Changes are the changes made or skipped, with file paths relative to the repository.
+	Changes []rewrite.Change
+	// Findings are the findings remaining after fixing that are not accepted by the repository's baseline.
+	Findings []rewrite.Finding
+	// Err is why the repository could not be processed.
+	Err error
+}
+
+// Applied returns the number of applied changes.
+func (r RepoResult) Applied() int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Applied() {
+			n++
+		}
+	}
+	return n
+}
+
+// Compliant reports whether the repository was processed and has no remaining findings.
+func (r RepoResult) Compliant() bool {
+	return r.Err == nil && len(r.Findings) == 0
+}
+
+// Status is a one-word summary of the result: "failed", "non-compliant" or "compliant".
+func (r RepoResult) Status() string {
+	switch {
+	case r.Err != nil:
+		return "failed"
+	case len(r.Findings) > 0:
+		return "non-compliant"
+	default:
+		return "compliant"
+	}
+}
+
+// BatchMarkdown renders the results of several repositories: a summary table with the compliance of each
+// repository, then the failures, the remaining findings and the applied changes grouped by repository.
+func BatchMarkdown(title string, results []RepoResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", title)
+	if len(results) == 0 {
+		b.WriteString("No repositories.\n")
+		return b.String()
+	}
+
+	var changed, compliant, failed, changes int
+	for _, r := range results {
+		if n := r.Applied(); n > 0 {
+			changed++
+			changes += n
+		}
+		if r.Compliant() {
+			compliant++
+		}
+		if r.Err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(&b, "%d repositories: %d compliant, %d non-compliant, %d failed. %d change(s) in %d repositories.\n\n",
+		len(results), compliant, len(results)-compliant-failed, failed, changes, changed)
+
+	b.WriteString("| Repository | Status | Changes | Findings |\n|---|---|---:|---:|\n")
+	for _, r := range results {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", code(r.Repo), r.Status(), r.Applied(), len(r.Findings))
+	}
+
+	if failed > 0 {
+		b.WriteString("\n### Failures\n\n| Repository | Error |\n|---|---|\n")
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(&b, "| %s | %s |\n", code(r.Repo), escape(strings.ReplaceAll(r.Err.Error(), "\n", " ")))
+			}
+		}
+	}
+
+	for _, r := range results {
+		if len(r.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### Findings in %s\n\n", code(r.Repo))
+		b.WriteString("| File | Line | Job | Rule | Message |\n|---|---:|---|---|---|\n")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "| %s | %d | %s | %s | %s |\n", code(f.File), f.Line, code(f.Job), f.Rule, escape(f.Message))
+		}
+	}
+
+	for _, r := range results {
+		if r.Applied() == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### Changes in %s\n\n", code(r.Repo))
+		b.WriteString("| File | Line | Job | Change |\n|---|---:|---|---|\n")
+		for _, c := range r.Changes {
+			if c.Applied() {
+				fmt.Fprintf(&b, "| %s | %d | %s | %s |\n", code(c.File), c.Line, code(c.Job), describe(c))
+			}
+		}
+	}
+	return b.String()
+}
+
+// AppendStepSummary appends markdown to the job summary file if StepSummaryEnv is set. Returns false if it's not
+// set, i.e. outside of GitHub Actions.
+func AppendStepSummary(markdown string) (bool, error) {
+	path := os.Getenv(StepSummaryEnv)
+	if path == "" {
+		return false, nil
+	}
+	// The path is set by the GitHub Actions runner.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to open job summary file: %s", path)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString(markdown + "\n"); err != nil {
+		return false, errors.Wrapf(err, "failed to write job summary file: %s", path)
+	}
+	return true, errors.WithStack(f.Close())
+}
+
+func describe(c rewrite.Change) string {
+	switch {
+	case c.Skipped != "" && c.Action != "":
+		return fmt.Sprintf("%s skipped: %s", code(c.Action), c.Skipped)
+	case c.Skipped != "":
+		return fmt.Sprintf("skipped: %s", c.Skipped)
+	case c.Action != "":
+		s := fmt.Sprintf("%s → %s", code(c.Action), code(c.To))
+		if c.Tag != "" {
+			s += " " + code(c.Tag)
+		}
+		return s
+	case c.Rule == timeout.RuleName:
+		return fmt.Sprintf("added %s", code("timeout-minutes: "+c.To))
+	case c.To != "":
+		return fmt.Sprintf("%s → %s", c.Rule, code(c.To))
+	default:
+		// Plugins may describe an edit by its line only.
+		return "changed by " + c.Rule
+	}
+}
+
+type fileGroup[T any] struct {
+	file  string
+	items []T
+}
+
+// groupByFile groups items by file, keeping the order of first appearance.
+func groupByFile[T any](items []T, file func(T) string) []fileGroup[T] {
+	var groups []fileGroup[T]
+	index := map[string]int{}
+	for _, item := range items {
+		f := file(item)
+		i, ok := index[f]
+		if !ok {
+			i = len(groups)
+			index[f] = i
+			groups = append(groups, fileGroup[T]{file: f})
+		}
+		groups[i].items = append(groups[i].items, item)
+	}
+	return groups
+}
+
+// code formats s as inline code in a table cell. Empty strings are left empty.
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + escape(s) + "`"
+}
+
+// escape escapes pipes, which end table cells even inside inline code.
+func escape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// CommitMessage renders a git commit message for changes: subject, the pinned actions, the jobs timeout-minutes was
+// added to, the changes of other rules (plugins) by rule, and trailer if not empty. Skipped items are not listed.
+func CommitMessage(subject string, changes []rewrite.Change, trailer string) string {
+	var pinned, timeouts []string
+	// Rules of plugins in order of first appearance, and their lines.
+	var otherRules []string
+	others := map[string][]string{}
+	seen := map[string]bool{}
+	for _, c := range changes {
+		if !c.Applied() {
+			continue
+		}
+		var line string
+		switch {
+		case c.Rule == timeout.RuleName:
+			line = fmt.Sprintf("- %s: %s (timeout-minutes: %s)", c.File, c.Job, c.To)
+		case c.Action != "":
+			line = fmt.Sprintf("- %s -> %s", c.Action, c.To)
+			if c.Tag != "" {
+				line += " (" + c.Tag + ")"
+			}
+		default:
+			line = fmt.Sprintf("- %s:%d", c.File, c.Line)
+			if c.Job != "" {
+				line += " (" + c.Job + ")"
+			}
+			if c.To != "" {
+				line += " -> " + c.To
+			}
+		}
+		// The same action is usually pinned in several files.
+		if seen[c.Rule+line] {
+			continue
+		}
+		seen[c.Rule+line] = true
+		switch c.Rule {
+		case pin.RuleName:
+			pinned = append(pinned, line)
+		case timeout.RuleName:
+			timeouts = append(timeouts, line)
+		default:
+			if _, ok := others[c.Rule]; !ok {
+				otherRules = append(otherRules, c.Rule)
+			}
+			others[c.Rule] = append(others[c.Rule], line)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	if len(pinned) > 0 {
+		b.WriteString("\nPinned actions:\n" + strings.Join(pinned, "\n") + "\n")
+	}
+	if len(timeouts) > 0 {
+		b.WriteString("\nAdded timeout-minutes to jobs:\n" + strings.Join(timeouts, "\n") + "\n")
+	}
+	for _, rule := range otherRules {
+		b.WriteString("\nChanged by " + rule + ":\n" + strings.Join(others[rule], "\n") + "\n")
+	}
+	if trailer != "" {
+		b.WriteString("\n" + trailer + "\n")
+	}
+	return b.String()
+}
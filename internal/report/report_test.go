@@ -0,0 +1,163 @@
+package report
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+)
+
+func TestMarkdown(t *testing.T) {
+	changes := []rewrite.Change{
+		{Rule: "pin", File: ".github/workflows/ci.yml", Job: "build", Action: "actions/checkout@v4", Line: 8, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+		{Rule: "pin", File: ".github/workflows/ci.yml", Job: "build", Action: "my-org/setup@v1", Line: 9, Skipped: rewrite.SkipIgnoredOwner},
+		{Rule: "timeout", File: ".github/workflows/release.yml", Job: "release", Line: 3, To: "10"},
+		{Rule: "timeout", File: ".github/workflows/release.yml", Job: "call", Line: 9, Skipped: rewrite.SkipReusableWorkflow},
+		{Rule: "pin", File: ".github/workflows/ci.yml", Job: "lint", Action: "actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b", Line: 20, Skipped: rewrite.SkipAlreadyPinned},
+	}
+
+	want := "## gha-fix\n" +
+		"\n" +
+		"2 change(s) in 2 file(s), 3 skipped.\n" +
+		"\n" +
+		"### `.github/workflows/ci.yml`\n" +
+		"\n" +
+		"| Line | Job | Change |\n" +
+		"|---:|---|---|\n" +
+		"| 8 | `build` | `actions/checkout@v4` → `11bd71901bbe5b1630ceea73d27597364c9af683` `v4.2.2` |\n" +
+		"| 9 | `build` | `my-org/setup@v1` skipped: ignored owner |\n" +
+		"| 20 | `lint` | `actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b` skipped: already pinned |\n" +
+		"\n" +
+		"### `.github/workflows/release.yml`\n" +
+		"\n" +
+		"| Line | Job | Change |\n" +
+		"|---:|---|---|\n" +
+		"| 3 | `release` | added `timeout-minutes: 10` |\n" +
+		"| 9 | `call` | skipped: reusable workflow |\n"
+	assert.Equal(t, want, Markdown("gha-fix", changes))
+
+	assert.Equal(t, "## gha-fix pin\n\nNo changes.\n", Markdown("gha-fix pin", nil))
+
+	// Changes of plugins.
+	assert.Contains(t, Markdown("gha-fix plugin run shell", []rewrite.Change{
+		{Rule: "shell", File: "ci.yml", Job: "build", Line: 3, To: "bash"},
+		{Rule: "shell", File: "ci.yml", Line: 7},
+	}), "| 3 | `build` | shell → `bash` |\n| 7 |  | changed by shell |\n")
+}
+
+func TestFindingsMarkdown(t *testing.T) {
+	findings := []rewrite.Finding{
+		{Rule: "timeout", File: "ci.yml", Job: "build", Line: 3, Message: "job does not have timeout-minutes: build"},
+		{Rule: "pin", File: "ci.yml", Job: "build", Action: "actions/checkout@v4", Line: 7, Message: "action is not pinned to a commit SHA: a|b"},
+	}
+
+	want := "## gha-fix check\n" +
+		"\n" +
+		"2 finding(s) in 1 file(s).\n" +
+		"\n" +
+		"### `ci.yml`\n" +
+		"\n" +
+		"| Line | Job | Rule | Message |\n" +
+		"|---:|---|---|---|\n" +
+		"| 3 | `build` | timeout | job does not have timeout-minutes: build |\n" +
+		"| 7 | `build` | pin | action is not pinned to a commit SHA: a\\|b |\n"
+	assert.Equal(t, want, FindingsMarkdown("gha-fix check", findings))
+
+	assert.Equal(t, "## gha-fix check\n\nNo findings.\n", FindingsMarkdown("gha-fix check", nil))
+}
+
+func TestAppendStepSummary(t *testing.T) {
+	t.Setenv(StepSummaryEnv, "")
+	ok, err := AppendStepSummary("ignored")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	path := filepath.Join(t.TempDir(), "summary.md")
+	require.NoError(t, os.WriteFile(path, []byte("## previous step\n"), 0o600))
+	t.Setenv(StepSummaryEnv, path)
+
+	ok, err = AppendStepSummary("## gha-fix\n")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "## previous step\n## gha-fix\n\n", string(content))
+}
+
+func TestCommitMessage(t *testing.T) {
+	changes := []rewrite.Change{
+		{Rule: "pin", File: "a.yml", Job: "build", Action: "actions/checkout@v4", Line: 8, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+		{Rule: "pin", File: "a.yml", Job: "build", Action: "my-org/setup@v1", Line: 9, Skipped: rewrite.SkipIgnoredOwner},
+		{Rule: "pin", File: "b.yml", Job: "test", Action: "actions/checkout@v4", Line: 5, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+		{Rule: "timeout", File: "b.yml", Job: "test", Line: 3, To: "10"},
+		{Rule: "shell", File: "a.yml", Job: "build", Line: 3, To: "bash"},
+		{Rule: "shell", File: "b.yml", Line: 1},
+	}
+
+	assert.Equal(t, `Fix workflows
+
+Pinned actions:
+- actions/checkout@v4 -> 11bd71901bbe5b1630ceea73d27597364c9af683 (v4.2.2)
+
+Added timeout-minutes to jobs:
+- b.yml: test (timeout-minutes: 10)
+
+Changed by shell:
+- a.yml:3 (build) -> bash
+- b.yml:1
+
+Generated-by: gha-fix
+`, CommitMessage("Fix workflows", changes, "Generated-by: gha-fix"))
+
+	assert.Equal(t, "Fix workflows\n", CommitMessage("Fix workflows", nil, ""))
+}
+
+func TestBatchMarkdown(t *testing.T) {
+	results := []RepoResult{
+		{Repo: "svc-a", Changes: []rewrite.Change{
+			{Rule: "pin", File: "ci.yml", Job: "build", Action: "actions/checkout@v4", Line: 6, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+			{Rule: "pin", File: "ci.yml", Job: "build", Action: "my-org/setup@v1", Line: 7, Skipped: rewrite.SkipIgnoredOwner},
+		}},
+		{Repo: "svc-b", Findings: []rewrite.Finding{
+			{Rule: "timeout", File: "ci.yml", Job: "build", Line: 3, Message: "job does not have timeout-minutes: build"},
+		}},
+		{Repo: "svc-c", Err: errors.New("invalid config:\nline 2")},
+	}
+
+	want := "## gha-fix batch\n" +
+		"\n" +
+		"3 repositories: 1 compliant, 1 non-compliant, 1 failed. 1 change(s) in 1 repositories.\n" +
+		"\n" +
+		"| Repository | Status | Changes | Findings |\n" +
+		"|---|---|---:|---:|\n" +
+		"| `svc-a` | compliant | 1 | 0 |\n" +
+		"| `svc-b` | non-compliant | 0 | 1 |\n" +
+		"| `svc-c` | failed | 0 | 0 |\n" +
+		"\n" +
+		"### Failures\n" +
+		"\n" +
+		"| Repository | Error |\n" +
+		"|---|---|\n" +
+		"| `svc-c` | invalid config: line 2 |\n" +
+		"\n" +
+		"### Findings in `svc-b`\n" +
+		"\n" +
+		"| File | Line | Job | Rule | Message |\n" +
+		"|---|---:|---|---|---|\n" +
+		"| `ci.yml` | 3 | `build` | timeout | job does not have timeout-minutes: build |\n" +
+		"\n" +
+		"### Changes in `svc-a`\n" +
+		"\n" +
+		"| File | Line | Job | Change |\n" +
+		"|---|---:|---|---|\n" +
+		"| `ci.yml` | 6 | `build` | `actions/checkout@v4` → `11bd71901bbe5b1630ceea73d27597364c9af683` `v4.2.2` |\n"
+	assert.Equal(t, want, BatchMarkdown("gha-fix batch", results))
+
+	assert.Equal(t, "## gha-fix batch\n\nNo repositories.\n", BatchMarkdown("gha-fix batch", nil))
+}
@@ -0,0 +1,269 @@
+package rewrite
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// FS is the filesystem the rewrite engine reads workflow files from and writes results to.
+// Paths are slash-separated as in io/fs.
+type FS interface {
+	fs.FS
+	// WriteFile replaces the content of name, creating it if necessary. Implementations should make the
+	// update atomic so that readers never observe a partially written file.
+	WriteFile(name string, data []byte) error
+}
+
+// OSFS is an FS backed by the operating system's filesystem.
+//
+// Unlike os.DirFS, it accepts absolute paths and paths containing "..", because file paths given on the
+// command line are passed through as is.
+type OSFS struct{}
+
+var _ fs.ReadDirFS = OSFS{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	f, err := os.Open(filepath.FromSlash(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return f, nil
+}
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(filepath.FromSlash(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return entries, nil
+}
+
+// WriteFile writes to a temporary file then renames it to name to do an atomic update.
+func (OSFS) WriteFile(name string, data []byte) error {
+	return writeFileAtomic(filepath.FromSlash(name), string(data))
+}
+
+// DirFS is an FS backed by the directory tree rooted at the directory, like os.DirFS. Names are relative to the
+// directory and must be valid io/fs paths, so files of several repositories can be processed without changing the
+// working directory. Writes are atomic as with OSFS.
+type DirFS string
+
+var _ fs.ReadDirFS = DirFS("")
+
+func (d DirFS) Open(name string) (fs.File, error) {
+	full, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return OSFS{}.Open(full)
+}
+
+func (d DirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return OSFS{}.ReadDir(full)
+}
+
+func (d DirFS) WriteFile(name string, data []byte) error {
+	full, err := d.join("write", name)
+	if err != nil {
+		return err
+	}
+	return OSFS{}.WriteFile(full, data)
+}
+
+func (d DirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid})
+	}
+	return path.Join(filepath.ToSlash(string(d)), name), nil
+}
+
+// MemFS is an in-memory FS, e.g. for workflow contents fetched from an API or a git object store.
+// It's safe for concurrent use. Use NewMemFS to create one.
+//
+// Names are cleaned before use, so "./ci.yml" and "ci.yml" refer to the same file. Directories are implied by the
+// names of the files in them.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*memFile
+}
+
+// memFile is a file of a MemFS. Writes replace the file, so open files keep reading the content they were opened
+// with.
+type memFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+var (
+	_ FS             = (*MemFS)(nil)
+	_ fs.ReadDirFS   = (*MemFS)(nil)
+	_ fs.ReadDirFile = (*memDir)(nil)
+)
+
+// NewMemFS creates a MemFS holding files, keyed by slash-separated paths.
+func NewMemFS(files map[string]string) *MemFS {
+	m := &MemFS{files: make(map[string]*memFile, len(files))}
+	for name, content := range files {
+		m.files[path.Clean(name)] = &memFile{data: []byte(content)}
+	}
+	return m
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, errors.WithStack(&fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid})
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if f, ok := m.files[name]; ok {
+		return &memOpenFile{
+			info:   memFileInfo{name: path.Base(name), size: int64(len(f.data)), mode: 0o644, modTime: f.modTime},
+			Reader: bytes.NewReader(f.data),
+		}, nil
+	}
+	entries, ok := m.readDir(name)
+	if !ok {
+		return nil, errors.WithStack(&fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist})
+	}
+	return &memDir{info: memFileInfo{name: path.Base(name), mode: fs.ModeDir | 0o755}, entries: entries}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, errors.WithStack(&fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid})
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries, ok := m.readDir(name)
+	if !ok {
+		if _, isFile := m.files[name]; isFile {
+			return nil, errors.WithStack(&fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")})
+		}
+		return nil, errors.WithStack(&fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist})
+	}
+	return entries, nil
+}
+
+// readDir returns the entries of the directory dir sorted by name, or false if no file is in it. The root
+// directory always exists. The caller must hold the lock.
+func (m *MemFS) readDir(dir string) ([]fs.DirEntry, bool) {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	children := map[string]fs.DirEntry{}
+	for name, f := range m.files {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			children[child] = fs.FileInfoToDirEntry(memFileInfo{name: child, mode: fs.ModeDir | 0o755})
+		} else {
+			children[child] = fs.FileInfoToDirEntry(memFileInfo{name: child, size: int64(len(f.data)), mode: 0o644, modTime: f.modTime})
+		}
+	}
+	if len(children) == 0 && dir != "." {
+		return nil, false
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range slices.Sorted(maps.Keys(children)) {
+		entries = append(entries, children[child])
+	}
+	return entries, true
+}
+
+func (m *MemFS) WriteFile(name string, data []byte) error {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return errors.WithStack(&fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = &memFile{data: slices.Clone(data), modTime: time.Now()}
+	return nil
+}
+
+// Files returns a snapshot of all files and their contents.
+func (m *MemFS) Files() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	files := make(map[string]string, len(m.files))
+	for name, f := range m.files {
+		files[name] = string(f.data)
+	}
+	return files
+}
+
+// memFileInfo describes a file or directory of a MemFS.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() any           { return nil }
+
+// memOpenFile is an open file of a MemFS.
+type memOpenFile struct {
+	info memFileInfo
+	*bytes.Reader
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memOpenFile) Close() error               { return nil }
+
+// memDir is an open directory of a MemFS.
+type memDir struct {
+	info    memFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, errors.WithStack(&fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")})
+}
+
+// ReadDir follows the contract of fs.ReadDirFile: with n > 0, at most n entries and io.EOF at the end.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
@@ -2,47 +2,131 @@ package rewrite
 
 import (
 	"context"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/cockroachdb/errors"
+
+	"github.com/Finatext/gha-fix/internal/logging"
 )
 
 type RewriteResult struct {
 	Changed   bool
 	FileCount int
+	// Changes are the changes and skipped items reported by a ReportFixFunc, in file order. Always empty for
+	// a FixFunc.
+	Changes []Change
 }
 
 type FixFunc func(ctx context.Context, content string) (string, bool, error)
 
-func Rewrite(ctx context.Context, filePaths []string, ignoreDirs []string, f FixFunc) (RewriteResult, error) {
-	if len(filePaths) == 0 {
-		slog.Debug("searching for workflow files to process")
-		workflowPaths, err := findWorkflowFiles(".", ignoreDirs)
-		if err != nil {
-			return RewriteResult{}, err
-		}
-		slog.Debug("found workflow files", "count", len(workflowPaths))
-		if len(workflowPaths) == 0 {
-			return RewriteResult{}, nil
-		}
+// ReportFixFunc is a FixFunc that reports what it changed and what it skipped instead of a boolean.
+// The content is considered changed if any of the changes is applied.
+type ReportFixFunc func(ctx context.Context, content string) (string, []Change, error)
+
+// SkipReason explains why a fixer left an item unchanged.
+type SkipReason string
+
+const (
+	SkipIgnoredOwner     SkipReason = "ignored owner"
+	SkipIgnoredRepo      SkipReason = "ignored repo"
+	SkipAlreadyPinned    SkipReason = "already pinned"
+	SkipReusableWorkflow SkipReason = "reusable workflow"
+)
+
+// Change is a modification made by a fixer, or an item it skipped.
+type Change struct {
+	// Rule is the name of the fixer, e.g. "pin" or "timeout".
+	Rule string
+	// File is the slash-separated path of the workflow file. Set by RewriteReport.
+	File string
+	// Job is the ID of the job containing the change. Empty if it's outside of jobs.
+	Job string
+	// Action is the original action reference (owner/repo[/path]@ref) for changes about `uses`. Empty otherwise.
+	Action string
+	// Line is the 1-based line number in the original content.
+	Line int
+	// To is the new value: the commit SHA for pin, the timeout-minutes value for timeout. Empty if skipped.
+	To string
+	// Tag is the tag the commit SHA was resolved to, written as the comment after it. Only set by pin.
+	Tag string
+	// Skipped is the reason the item was left unchanged. Empty if the change was applied.
+	Skipped SkipReason
+}
+
+// Applied reports whether the change modified the content.
+func (c Change) Applied() bool {
+	return c.Skipped == ""
+}
+
+// Finding is a violation reported by a fixer in check mode.
+type Finding struct {
+	// Rule is the name of the fixer that reported the finding, e.g. "pin" or "timeout".
+	Rule string
+	// File is the slash-separated path of the workflow file. Set by Check.
+	File string
+	// Job is the ID of the job containing the finding. Empty if the finding is outside of jobs.
+	Job string
+	// Action is the action reference (owner/repo[/path]@ref) for findings about `uses`. Empty otherwise.
+	Action string
+	// Line is the 1-based line number of the finding.
+	Line    int
+	Message string
+}
+
+type CheckFunc func(ctx context.Context, content string) ([]Finding, error)
+
+type CheckResult struct {
+	Findings []Finding
+	// Files are the slash-separated paths of all checked files, including ones without findings.
+	Files []string
+}
 
-		filePaths = workflowPaths
+// Rewrite applies f to each file in fsys and writes back the changed ones.
+// If filePaths is empty, all workflow files in fsys are processed, see FindWorkflowFiles.
+func Rewrite(ctx context.Context, fsys FS, filePaths []string, ignoreDirs []string, f FixFunc) (RewriteResult, error) {
+	return rewrite(ctx, fsys, filePaths, ignoreDirs, func(ctx context.Context, content string) (string, bool, []Change, error) {
+		modified, changed, err := f(ctx, content)
+		return modified, changed, nil, err
+	})
+}
+
+// RewriteReport is Rewrite for a fixer reporting its changes. The changes of all files are collected in
+// RewriteResult.Changes.
+func RewriteReport(ctx context.Context, fsys FS, filePaths []string, ignoreDirs []string, f ReportFixFunc) (RewriteResult, error) {
+	return rewrite(ctx, fsys, filePaths, ignoreDirs, func(ctx context.Context, content string) (string, bool, []Change, error) {
+		modified, changes, err := f(ctx, content)
+		return modified, slices.ContainsFunc(changes, Change.Applied), changes, err
+	})
+}
+
+type fixFunc func(ctx context.Context, content string) (string, bool, []Change, error)
+
+func rewrite(ctx context.Context, fsys FS, filePaths []string, ignoreDirs []string, f fixFunc) (RewriteResult, error) {
+	filePaths, err := resolveFiles(fsys, filePaths, ignoreDirs)
+	if err != nil {
+		return RewriteResult{}, err
 	}
 
 	res := RewriteResult{}
 
 	for _, filePath := range filePaths {
-		slog.Debug("processing file", "path", filePath)
-		changed, err := processFile(ctx, filePath, f)
+		slog.Debug("processing file", logging.KeyFile, filePath)
+		changed, changes, err := processFile(ctx, fsys, filePath, f)
 		if err != nil {
 			return RewriteResult{}, errors.Wrapf(err, "failed to process file: %s", filePath)
 		}
+		for _, change := range changes {
+			change.File = NormalizePath(filePath)
+			res.Changes = append(res.Changes, change)
+		}
 
 		if changed {
-			slog.Info("file updated", "path", filePath)
+			slog.Info("file updated", logging.KeyFile, filePath)
 			res.Changed = true
 			res.FileCount++
 		}
@@ -51,55 +135,120 @@ func Rewrite(ctx context.Context, filePaths []string, ignoreDirs []string, f Fix
 	return res, nil
 }
 
-func processFile(ctx context.Context, filePath string, f FixFunc) (bool, error) {
-	content, err := os.ReadFile(filePath)
+// Check runs f on each file without modifying it and collects the findings.
+// See Rewrite for how filePaths is handled.
+func Check(ctx context.Context, fsys fs.FS, filePaths []string, ignoreDirs []string, f CheckFunc) (CheckResult, error) {
+	filePaths, err := resolveFiles(fsys, filePaths, ignoreDirs)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	res := CheckResult{}
+	for _, filePath := range filePaths {
+		slog.Debug("checking file", logging.KeyFile, filePath)
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return CheckResult{}, errors.Wrapf(err, "failed to read file: %s", filePath)
+		}
+
+		findings, err := f(WithFilePath(ctx, filePath), string(content))
+		if err != nil {
+			return CheckResult{}, errors.Wrapf(err, "failed to check file: %s", filePath)
+		}
+
+		normalized := NormalizePath(filePath)
+		res.Files = append(res.Files, normalized)
+		for _, finding := range findings {
+			finding.File = normalized
+			res.Findings = append(res.Findings, finding)
+		}
+	}
+
+	return res, nil
+}
+
+type filePathKey struct{}
+
+// WithFilePath returns a copy of ctx carrying the normalized path of the file being processed. Rewrite,
+// RewriteReport and Check set it for each file, so that fixers which need the path can read it with FilePath.
+func WithFilePath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, filePathKey{}, NormalizePath(path))
+}
+
+// FilePath returns the slash-separated path of the file being processed, or the empty string outside of Rewrite,
+// RewriteReport and Check.
+func FilePath(ctx context.Context) string {
+	path, _ := ctx.Value(filePathKey{}).(string)
+	return path
+}
+
+// NormalizePath cleans path and converts it to a slash-separated form so that it's stable across platforms
+// and invocations (e.g. "./.github/workflows/ci.yml" and ".github/workflows/ci.yml" are the same file).
+func NormalizePath(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// resolveFiles returns filePaths as is, or all workflow files in fsys if filePaths is empty.
+func resolveFiles(fsys fs.FS, filePaths []string, ignoreDirs []string) ([]string, error) {
+	if len(filePaths) > 0 {
+		return filePaths, nil
+	}
+
+	slog.Debug("searching for workflow files to process")
+	workflowPaths, err := FindWorkflowFiles(fsys, ".", ignoreDirs)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("found workflow files", "count", len(workflowPaths))
+	return workflowPaths, nil
+}
+
+func processFile(ctx context.Context, fsys FS, filePath string, f fixFunc) (bool, []Change, error) {
+	content, err := fs.ReadFile(fsys, filePath)
 	if err != nil {
-		return false, errors.WithStack(err)
+		return false, nil, errors.WithStack(err)
 	}
 
-	modifiedContent, changed, err := f(ctx, string(content))
+	modifiedContent, changed, changes, err := f(WithFilePath(ctx, filePath), string(content))
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to replace actions in file: %s", filePath)
+		return false, nil, errors.Wrapf(err, "failed to replace actions in file: %s", filePath)
 	}
 	if !changed {
-		return false, nil
+		return false, changes, nil
 	}
 
-	err = writeFileAtomic(filePath, modifiedContent)
+	err = fsys.WriteFile(filePath, []byte(modifiedContent))
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to write file: %s", filePath)
+		return false, nil, errors.Wrapf(err, "failed to write file: %s", filePath)
 	}
 
-	return true, nil
+	return true, changes, nil
 }
 
-// findWorkflowFiles finds all workflow files (.yml or .yaml) in the current directory and subdirectories
+// FindWorkflowFiles finds all workflow files (.yml or .yaml) in the root directory of fsys and subdirectories
 // ignoreDirs is an optional list of directory names to skip during traversal
-func findWorkflowFiles(root string, ignoreDirs []string) ([]string, error) {
+func FindWorkflowFiles(fsys fs.FS, root string, ignoreDirs []string) ([]string, error) {
 	var files []string
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() {
-			dirName := info.Name()
+		if d.IsDir() {
+			dirName := d.Name()
 
 			// Skip directories specified in ignoreDirs (defaults to .git and node_modules)
 			for _, ignoreDir := range ignoreDirs {
 				if dirName == ignoreDir {
 					slog.Debug("skipping directory", "path", path, "name", dirName)
-					return filepath.SkipDir
+					return fs.SkipDir
 				}
 			}
 		}
 
-		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if ext == ".yml" || ext == ".yaml" {
-				files = append(files, path)
-			}
+		if !d.IsDir() && hasWorkflowExt(path) {
+			files = append(files, path)
 		}
 
 		return nil
@@ -112,6 +261,26 @@ func findWorkflowFiles(root string, ignoreDirs []string) ([]string, error) {
 	return files, nil
 }
 
+// IsWorkflowFile reports whether the slash-separated path would be found by FindWorkflowFiles: it has a .yml or
+// .yaml extension and none of its directories is in ignoreDirs.
+func IsWorkflowFile(path string, ignoreDirs []string) bool {
+	if !hasWorkflowExt(path) {
+		return false
+	}
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for _, dir := range dirs {
+		if slices.Contains(ignoreDirs, dir) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasWorkflowExt(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yml" || ext == ".yaml"
+}
+
 func writeFileAtomic(targetPath, content string) error {
 	dir := filepath.Dir(targetPath)
 	fileName := filepath.Base(targetPath)
@@ -0,0 +1,172 @@
+package rewrite
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func upperFix(ctx context.Context, content string) (string, bool, error) {
+	fixed := strings.ToUpper(content)
+	return fixed, fixed != content, nil
+}
+
+func TestRewrite_MemFS(t *testing.T) {
+	fsys := NewMemFS(map[string]string{
+		".github/workflows/ci.yml":        "on: push\n",
+		".github/workflows/done.yaml":     "ON: PUSH\n",
+		"node_modules/pkg/workflow.yml":   "on: push\n",
+		"docs/README.md":                  "readme\n",
+		"ci/workflows/nested/release.YML": "on: tag\n",
+	})
+
+	res, err := Rewrite(context.Background(), fsys, nil, []string{"node_modules"}, upperFix)
+	require.NoError(t, err)
+	assert.Equal(t, RewriteResult{Changed: true, FileCount: 2}, res)
+
+	assert.Equal(t, map[string]string{
+		".github/workflows/ci.yml":        "ON: PUSH\n",
+		".github/workflows/done.yaml":     "ON: PUSH\n",
+		"node_modules/pkg/workflow.yml":   "on: push\n",
+		"docs/README.md":                  "readme\n",
+		"ci/workflows/nested/release.YML": "ON: TAG\n",
+	}, fsys.Files())
+}
+
+func TestRewrite_OSFS(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ci.yml")
+	require.NoError(t, os.WriteFile(path, []byte("on: push\n"), 0o600))
+
+	res, err := Rewrite(context.Background(), OSFS{}, []string{path}, nil, upperFix)
+	require.NoError(t, err)
+	assert.True(t, res.Changed)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "ON: PUSH\n", string(content))
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestRewrite_DirFS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte("on: push\n"), 0o600))
+
+	res, err := RewriteReport(context.Background(), DirFS(dir), nil, nil, func(ctx context.Context, content string) (string, []Change, error) {
+		return strings.ToUpper(content), []Change{{Rule: "upper", Line: 1}}, nil
+	})
+	require.NoError(t, err)
+	// Paths are relative to the directory.
+	assert.Equal(t, []Change{{Rule: "upper", File: ".github/workflows/ci.yml", Line: 1}}, res.Changes)
+
+	content, err := os.ReadFile(filepath.Join(dir, ".github", "workflows", "ci.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "ON: PUSH\n", string(content))
+
+	_, err = DirFS(dir).Open("../outside.yml")
+	require.Error(t, err)
+}
+
+func TestCheck_MemFS(t *testing.T) {
+	fsys := NewMemFS(map[string]string{
+		".github/workflows/a.yml": "one\ntwo\n",
+		".github/workflows/b.yml": "three\n",
+	})
+	lineCount := func(ctx context.Context, content string) ([]Finding, error) {
+		var findings []Finding
+		for i, line := range strings.Split(strings.TrimSpace(content), "\n") {
+			findings = append(findings, Finding{Rule: "test", Line: i + 1, Message: line})
+		}
+		return findings, nil
+	}
+
+	res, err := Check(context.Background(), fsys, []string{"./.github/workflows/a.yml", ".github/workflows/b.yml"}, nil, lineCount)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".github/workflows/a.yml", ".github/workflows/b.yml"}, res.Files)
+	assert.Equal(t, []Finding{
+		{Rule: "test", File: ".github/workflows/a.yml", Line: 1, Message: "one"},
+		{Rule: "test", File: ".github/workflows/a.yml", Line: 2, Message: "two"},
+		{Rule: "test", File: ".github/workflows/b.yml", Line: 1, Message: "three"},
+	}, res.Findings)
+
+	// Files are not modified in check mode.
+	assert.Equal(t, "one\ntwo\n", fsys.Files()[".github/workflows/a.yml"])
+}
+
+func TestMemFS_WriteFile_InvalidPath(t *testing.T) {
+	fsys := NewMemFS(nil)
+	require.Error(t, fsys.WriteFile("../outside.yml", []byte("x")))
+	require.Error(t, fsys.WriteFile("/abs.yml", []byte("x")))
+}
+
+func TestMemFS_FS(t *testing.T) {
+	fsys := NewMemFS(map[string]string{
+		".github/workflows/ci.yml": "on: push\n",
+		"./action.yml":             "runs: {}\n",
+	})
+	require.NoError(t, fsys.WriteFile(".github/workflows/release.yml", []byte("on: release\n")))
+
+	var walked []string
+	require.NoError(t, fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		return err
+	}))
+	assert.Equal(t, []string{".", ".github", ".github/workflows", ".github/workflows/ci.yml", ".github/workflows/release.yml", "action.yml"}, walked)
+
+	content, err := fs.ReadFile(fsys, "./.github/workflows/release.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "on: release\n", string(content))
+	info, err := fs.Stat(fsys, ".github")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = fsys.Open("missing.yml")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.ReadDir("action.yml")
+	require.Error(t, err)
+}
+
+func TestIsWorkflowFile(t *testing.T) {
+	ignoreDirs := []string{"node_modules", ".git"}
+	assert.True(t, IsWorkflowFile(".github/workflows/ci.yml", ignoreDirs))
+	assert.True(t, IsWorkflowFile("ci.YAML", ignoreDirs))
+	assert.False(t, IsWorkflowFile("README.md", ignoreDirs))
+	assert.False(t, IsWorkflowFile("node_modules/pkg/ci.yml", ignoreDirs))
+	assert.False(t, IsWorkflowFile("a/node_modules/b/ci.yml", ignoreDirs))
+}
+
+func TestRewriteReport_MemFS(t *testing.T) {
+	fsys := NewMemFS(map[string]string{
+		"a.yml": "on: push\n",
+		"b.yml": "skip\n",
+	})
+
+	fix := func(ctx context.Context, content string) (string, []Change, error) {
+		if content == "skip\n" {
+			return content, []Change{{Rule: "upper", Line: 1, Skipped: SkipIgnoredOwner}}, nil
+		}
+		return strings.ToUpper(content), []Change{{Rule: "upper", Line: 1, To: "ON: PUSH"}}, nil
+	}
+	res, err := RewriteReport(context.Background(), fsys, nil, nil, fix)
+	require.NoError(t, err)
+	assert.Equal(t, RewriteResult{
+		Changed:   true,
+		FileCount: 1,
+		Changes: []Change{
+			{Rule: "upper", File: "a.yml", Line: 1, To: "ON: PUSH"},
+			{Rule: "upper", File: "b.yml", Line: 1, Skipped: SkipIgnoredOwner},
+		},
+	}, res)
+	assert.Equal(t, map[string]string{"a.yml": "ON: PUSH\n", "b.yml": "skip\n"}, fsys.Files())
+}
@@ -2,7 +2,6 @@ package pin
 
 import (
 	"context"
-	"regexp"
 	"slices"
 	"strings"
 
@@ -10,10 +9,16 @@ import (
 	gogithub "github.com/google/go-github/v72/github"
 
 	"github.com/Finatext/gha-fix/internal/pin"
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/workflow"
 )
 
+// RuleName identifies findings reported by Pin.Check.
+const RuleName = "pin"
+
 type resolver interface {
 	ResolveVersion(ctx context.Context, def pin.ActionDef) (pin.ResolvedVersion, error)
+	TagsForCommit(ctx context.Context, owner, repo, sha string) ([]string, error)
 }
 
 type Pin struct {
@@ -24,7 +29,22 @@ type Pin struct {
 }
 
 func NewPin(client *gogithub.Client, ignoreOwners, ignoreRepos []string, strictPinning202508 bool) Pin {
-	resolver := pin.NewVersionResolver(client.Repositories)
+	return NewPinWithService(client.Repositories, ignoreOwners, ignoreRepos, strictPinning202508)
+}
+
+// NewPinWithService is NewPin resolving versions with repos instead of a client, e.g. a pin.OwnerRouter sending the
+// calls for some owners to GitHub Enterprise Server.
+func NewPinWithService(repos pin.RepositoryService, ignoreOwners, ignoreRepos []string, strictPinning202508 bool) Pin {
+	return NewPinWithLock(repos, nil, ignoreOwners, ignoreRepos, strictPinning202508)
+}
+
+// NewPinWithLock is NewPinWithService using the versions of lock before calling the API and recording resolved
+// versions in it, e.g. a lockfile.Lock. A nil lock is ignored.
+func NewPinWithLock(repos pin.RepositoryService, lock pin.Lock, ignoreOwners, ignoreRepos []string, strictPinning202508 bool) Pin {
+	resolver := pin.NewVersionResolver(repos)
+	if lock != nil {
+		resolver.SetLock(lock)
+	}
 	return Pin{
 		resolver:            &resolver,
 		ignoreOwners:        ignoreOwners,
@@ -33,152 +53,272 @@ func NewPin(client *gogithub.Client, ignoreOwners, ignoreRepos []string, strictP
 	}
 }
 
+// WithOptions returns a copy of p with the given options. The copy shares the resolver and its cache.
+func (p Pin) WithOptions(ignoreOwners, ignoreRepos []string, strictPinning202508 bool) Pin {
+	p.ignoreOwners = ignoreOwners
+	p.ignoreRepos = ignoreRepos
+	p.strictPinning202508 = strictPinning202508
+	return p
+}
+
+// WithLock returns a copy of p using lock, see NewPinWithLock. The copy shares the cache of resolved versions, but
+// versions found in lock take precedence.
+func (p Pin) WithLock(lock pin.Lock) Pin {
+	// Only the VersionResolver created by the constructors supports locks; other resolvers are kept as they are.
+	if r, ok := p.resolver.(*pin.VersionResolver); ok {
+		p.resolver = r.WithLock(lock)
+	}
+	return p
+}
+
 // Apply replaces input YAML content then returns the modified content, a boolean indicating if any replacements were
 // made, and an error if any occurred.
 func (p *Pin) Apply(ctx context.Context, input string) (string, bool, error) {
-	lines := strings.Split(input, "\n")
+	output, changes, err := p.ApplyReport(ctx, input)
+	if err != nil {
+		return "", false, err
+	}
+	return output, slices.ContainsFunc(changes, rewrite.Change.Applied), nil
+}
+
+// ApplyReport is Apply reporting each `uses` reference it pinned, with the resolved SHA and tag, or skipped, with the
+// reason.
+func (p *Pin) ApplyReport(ctx context.Context, input string) (string, []rewrite.Change, error) {
+	return p.applyReport(ctx, input, 0)
+}
 
-	changed := false
-	resultLines := make([]string, 0, len(lines))
-	for _, line := range lines {
-		modifiedLine, lineChanged, err := p.replaceLine(ctx, line)
+// ApplyLine is Apply pinning only the `uses` references on the 1-based line, e.g. for an editor quick fix.
+func (p *Pin) ApplyLine(ctx context.Context, input string, line int) (string, bool, error) {
+	output, changes, err := p.applyReport(ctx, input, line)
+	if err != nil {
+		return "", false, err
+	}
+	return output, slices.ContainsFunc(changes, rewrite.Change.Applied), nil
+}
+
+// applyReport implements ApplyReport, limited to the references on line unless it is 0.
+func (p *Pin) applyReport(ctx context.Context, input string, line int) (string, []rewrite.Change, error) {
+	lines, refs, err := findUses(input)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var changes []rewrite.Change
+	// Lines are edited from the last reference so that the columns of earlier ones on the same line stay valid.
+	for i := len(refs) - 1; i >= 0; i-- {
+		ref := refs[i]
+		if line != 0 && ref.line != line {
+			continue
+		}
+
+		change := rewrite.Change{Rule: RuleName, Job: ref.job, Action: ref.def.String(), Line: ref.line}
+		if reason := p.skipReason(ref.def); reason != "" {
+			change.Skipped = reason
+			changes = append(changes, change)
+			continue
+		}
+
+		newLine, resolved, err := p.pinLine(ctx, lines[ref.line-1], ref)
 		if err != nil {
-			return "", false, err
+			return "", nil, err
 		}
+		lines[ref.line-1] = newLine
+		change.To = resolved.CommitSHA
+		change.Tag = resolved.RefComment
+		changes = append(changes, change)
+	}
+	slices.Reverse(changes)
+
+	return strings.Join(lines, "\n"), changes, nil
+}
+
+// Check reports `uses` references that Apply would pin, without resolving them.
+func (p *Pin) Check(ctx context.Context, input string) ([]rewrite.Finding, error) {
+	_, refs, err := findUses(input)
+	if err != nil {
+		return nil, err
+	}
 
-		if lineChanged {
-			changed = true
-			line = modifiedLine
+	var findings []rewrite.Finding
+	for _, ref := range refs {
+		if !p.shouldPin(ref.def) {
+			continue
 		}
-		resultLines = append(resultLines, line)
+
+		action := ref.def.String()
+		findings = append(findings, rewrite.Finding{
+			Rule:    RuleName,
+			Job:     ref.job,
+			Action:  action,
+			Line:    ref.line,
+			Message: "action is not pinned to a commit SHA: " + action,
+		})
 	}
 
-	// Join lines back into a single string using strings.Join (more efficient than concatenation)
-	output := strings.Join(resultLines, "\n")
+	return findings, nil
+}
 
-	return output, changed, nil
+// PinnedRef describes a `uses` reference that is pinned to a commit SHA.
+type PinnedRef struct {
+	// Action is the action or reusable workflow without the ref: owner/repo[/path].
+	Action string
+	SHA    string
+	// Comment is the first word of the comment after the reference, conventionally the tag, e.g. "v4.2.2".
+	// Empty if there is no comment.
+	Comment string
+	// Tags are the tags pointing to SHA.
+	Tags []string
 }
 
-func (p *Pin) replaceLine(ctx context.Context, line string) (string, bool, error) {
-	parsed, ok := parseLine(line)
-	if !ok {
-		return line, false, nil // No action definition found, return the line unchanged
+// DescribePinned looks up the tags of the commit a `uses` reference on the 1-based line of input is pinned to.
+// Returns false if there is no `uses` reference pinned to a commit SHA on the line.
+func (p *Pin) DescribePinned(ctx context.Context, input string, line int) (PinnedRef, bool, error) {
+	_, refs, err := findUses(input)
+	if err != nil {
+		return PinnedRef{}, false, err
 	}
-	def := parsed.def
+	i := slices.IndexFunc(refs, func(ref usesRef) bool {
+		return ref.line == line && ref.def.HasCommitSHA()
+	})
+	if i < 0 {
+		return PinnedRef{}, false, nil
+	}
+	def := refs[i].def
 
+	tags, err := p.resolver.TagsForCommit(ctx, def.Owner, def.Repo, def.RefOrSHA)
+	if err != nil {
+		return PinnedRef{}, false, errors.Wrapf(err, "failed to look up tags for %s", def)
+	}
+
+	ref := PinnedRef{
+		Action: strings.TrimSuffix(def.String(), "@"+def.RefOrSHA),
+		SHA:    def.RefOrSHA,
+		Tags:   tags,
+	}
+	if fields := strings.Fields(strings.TrimPrefix(refs[i].comment, "#")); len(fields) > 0 {
+		ref.Comment = fields[0]
+	}
+	return ref, true, nil
+}
+
+// shouldPin reports whether def is subject to pinning under the configured ignore rules.
+func (p *Pin) shouldPin(def pin.ActionDef) bool {
+	return p.skipReason(def) == ""
+}
+
+// skipReason returns why def is not pinned, or the empty string if it's subject to pinning.
+func (p *Pin) skipReason(def pin.ActionDef) rewrite.SkipReason {
 	// Apply ignore owners check (skip for composite actions when strict pinning is enabled)
 	if !p.strictPinning202508 || def.IsReusableWorkflow() {
 		if slices.Contains(p.ignoreOwners, def.Owner) {
-			return line, false, nil
+			return rewrite.SkipIgnoredOwner
 		}
 	}
 
 	repoKey := def.Owner + "/" + def.Repo
 	if slices.Contains(p.ignoreRepos, repoKey) {
-		return line, false, nil
+		return rewrite.SkipIgnoredRepo
 	}
 
 	if def.HasCommitSHA() {
-		return line, false, nil
+		return rewrite.SkipAlreadyPinned
 	}
+	return ""
+}
 
+// pinLine resolves the reference and returns line with the reference pinned to the commit SHA. The tag is added
+// as a comment before the existing one, unless something other than a comment follows the reference, e.g. in a flow
+// style step.
+func (p *Pin) pinLine(ctx context.Context, line string, ref usesRef) (string, pin.ResolvedVersion, error) {
+	def := ref.def
 	resolved, err := p.resolver.ResolveVersion(ctx, def)
 	if err != nil {
-		if errors.Is(err, pin.AlreadyResolvedError) {
-			return line, false, nil
-		}
-		return "", false, errors.Wrapf(err, "failed to resolve version for %s/%s@%s", def.Owner, def.Repo, def.RefOrSHA)
+		return "", pin.ResolvedVersion{}, errors.Wrapf(err, "failed to resolve version for %s/%s@%s", def.Owner, def.Repo, def.RefOrSHA)
 	}
 
-	newComment := " # " + resolved.RefComment
-	if parsed.comment != "" {
-		newComment += " " + parsed.comment
-	}
+	// Keep the original quotes
+	pinned := def
+	pinned.RefOrSHA = resolved.CommitSHA
+	value := ref.quote + pinned.String() + ref.quote
 
-	// Reconstruct the path part if necessary
-	repoPath := def.Repo
-	if def.Path != "" {
-		repoPath = def.Repo + "/" + def.Path
+	rest := line[ref.end:]
+	if strings.TrimSpace(rest) != ref.comment {
+		return line[:ref.start] + value + rest, resolved, nil
 	}
-
-	// Construct the new line using the original quotes
-	newRef := def.Owner + "/" + repoPath + "@" + resolved.CommitSHA
-	newLine := parsed.prefix + parsed.openQuote + newRef + parsed.closeQuote + newComment
-
-	return newLine, true, nil
+	newComment := " # " + resolved.RefComment
+	if ref.comment != "" {
+		newComment += " " + ref.comment
+	}
+	return line[:ref.start] + value + newComment, resolved, nil
 }
 
-type parsedLine struct {
-	def        pin.ActionDef
-	prefix     string
-	openQuote  string // Opening quote if any (e.g., '"' or ''')
-	closeQuote string // Closing quote if any (should match openQuote)
-	comment    string // Comment part of the line (if any)
+// usesRef is a `uses` reference of the workflow model located in the source.
+type usesRef struct {
+	def pin.ActionDef
+	job string
+	// line is 1-based; start and end are the byte offsets of the value in the line, including quotes.
+	line       int
+	start, end int
+	quote      string // Quote around the value if any (e.g., '"' or "'")
+	comment    string // Comment after the value on the same line (if any)
 }
 
-// regexp to match and extract the action definition, see testdata/pin.yml for examples:
-//
-//   - uses: actions/checkout@v4 # Some comment
-//   - uses: actions/setup-go@v5.4
-//   - uses: "actions/checkout@v4"
-//   - uses: 'actions/checkout@v4'
-//     uses: golangci/golangci-lint-action@1481404843c368bc19ca9406f87d6e0fc97bdcfd # v7.0.0
-//     uses: Finatext/workflows-public/.github/workflows/gha-lint.yml@main
-var usesPattern = regexp.MustCompile(`^([-\s]*(?:["']?uses["']?:\s+))(["']?)([^/"']+)/([^/"']+)(/[^@"']+)?(@)([^\s#"']+)(["']?)(.*)`)
-
-// Group indices:
-// 1: prefix (e.g., "- uses: ", "   uses: ", or "   "uses": ")
-// 2: opening quote (if any)
-// 3: owner (e.g., "actions")
-// 4: repo (e.g., "checkout")
-// 5: path (e.g., "/diff" - optional)
-// 6: @ symbol
-// 7: refOrSHA (e.g., "v4", "main", commit SHA)
-// 8: closing quote (if any)
-// 9: suffix (comments, etc.)
-
-func parseLine(line string) (parsedLine, bool) {
-	// Check for leading comments
-	trimmed := strings.TrimSpace(line)
-	if len(trimmed) > 0 && trimmed[0] == '#' {
-		return parsedLine{}, false
+// findUses parses input with the workflow model and returns its lines and the `uses` references that name a remote
+// action or reusable workflow. Local actions and Docker images are not pinned, and references that span lines
+// (e.g. block scalars) are left as they are.
+func findUses(input string) ([]string, []usesRef, error) {
+	wf, err := workflow.Parse([]byte(input))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	matches := usesPattern.FindStringSubmatch(line)
-	if matches == nil {
-		return parsedLine{}, false
+	lines := strings.Split(input, "\n")
+	var refs []usesRef
+	for _, u := range wf.AllUses() {
+		def, ok := pin.ParseActionDef(u.Uses.Value)
+		if !ok {
+			continue
+		}
+		ref, ok := locate(lines, u.Uses.Scalar)
+		if !ok {
+			continue
+		}
+		ref.def = def
+		if u.Job != nil {
+			ref.job = u.Job.ID
+		}
+		refs = append(refs, ref)
 	}
+	return lines, refs, nil
+}
 
-	// Capture components from regex match
-	prefix := matches[1]    // "- uses: " or "uses: "
-	openQuote := matches[2] // Opening quote if any
-	owner := matches[3]     // e.g., "actions"
-	repo := matches[4]      // e.g., "checkout" or "oasdiff-action"
-	path := ""
-	if matches[5] != "" {
-		path = matches[5][1:] // Remove leading slash, e.g., "diff" from "/diff"
+// locate finds the source text of a single-line scalar from its position.
+func locate(lines []string, s workflow.Scalar) (usesRef, bool) {
+	if s.Pos.Line < 1 || s.Pos.Line > len(lines) {
+		return usesRef{}, false
 	}
-	refOrSHA := matches[7]   // e.g., "v4", "main", or commit SHA
-	closeQuote := matches[8] // Closing quote if any
-	suffix := matches[9]     // Any trailing comment or whitespace
+	line := lines[s.Pos.Line-1]
 
-	comment := ""
-	if commentIdx := strings.Index(suffix, "#"); commentIdx >= 0 {
-		comment = strings.TrimSpace(suffix[commentIdx:])
+	// Columns count characters, not bytes.
+	runes := []rune(line)
+	if s.Pos.Column < 1 || s.Pos.Column > len(runes) {
+		return usesRef{}, false
 	}
+	start := len(string(runes[:s.Pos.Column-1]))
 
-	def := pin.ActionDef{
-		Owner:    owner,
-		Repo:     repo,
-		Path:     path,
-		RefOrSHA: refOrSHA,
+	quote := ""
+	if c := line[start]; c == '"' || c == '\'' {
+		quote = string(c)
+	}
+	value := quote + s.Value + quote
+	if !strings.HasPrefix(line[start:], value) {
+		return usesRef{}, false
 	}
+	end := start + len(value)
 
-	return parsedLine{
-		def:        def,
-		prefix:     prefix,
-		openQuote:  openQuote,
-		closeQuote: closeQuote,
-		comment:    comment,
-	}, true
+	comment := ""
+	if i := strings.Index(line[end:], "#"); i >= 0 {
+		comment = strings.TrimSpace(line[end+i:])
+	}
+	return usesRef{line: s.Pos.Line, start: start, end: end, quote: quote, comment: comment}, true
 }
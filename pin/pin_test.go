@@ -3,9 +3,11 @@ package pin
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/Finatext/gha-fix/internal/pin"
+	"github.com/Finatext/gha-fix/internal/rewrite"
 	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,6 +17,11 @@ import (
 type ResolvedVersion = pin.ResolvedVersion
 type ActionDef = pin.ActionDef
 
+// step returns a workflow whose only step is line, e.g. "- uses: actions/checkout@v4".
+func step(line string) string {
+	return "jobs:\n  test:\n    steps:\n      " + line
+}
+
 func TestReplace(t *testing.T) {
 	inputBytes, err := os.ReadFile("../testdata/pin.yml")
 	require.NoError(t, err)
@@ -103,13 +110,13 @@ func TestIgnoreOwner(t *testing.T) {
 				ignoreOwners: tt.ignoreOwners,
 			}
 
-			got, changed, err := r.replaceLine(context.Background(), tt.input)
+			got, changed, err := r.Apply(context.Background(), step(tt.input))
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, step(tt.expected), got)
 			assert.Equal(t, tt.changed, changed)
 		})
 	}
@@ -166,13 +173,13 @@ func TestIgnoreRepo(t *testing.T) {
 				ignoreRepos: tt.ignoreRepos,
 			}
 
-			got, changed, err := r.replaceLine(context.Background(), tt.input)
+			got, changed, err := r.Apply(context.Background(), step(tt.input))
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, step(tt.expected), got)
 			assert.Equal(t, tt.changed, changed)
 		})
 	}
@@ -234,227 +241,77 @@ func TestCombinedIgnores(t *testing.T) {
 				ignoreRepos:  tt.ignoreRepos,
 			}
 
-			got, changed, err := r.replaceLine(context.Background(), tt.input)
+			got, changed, err := r.Apply(context.Background(), step(tt.input))
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, step(tt.expected), got)
 			assert.Equal(t, tt.changed, changed)
 		})
 	}
 }
 
-func TestParseLine(t *testing.T) {
-	tests := []struct {
-		name        string
-		input       string
-		wantDef     ActionDef
-		wantOk      bool
-		wantPrefix  string
-		wantComment string
-	}{
-		{
-			name:  "Standard format",
-			input: "- uses: actions/checkout@v4",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "With comment",
-			input: "- uses: actions/checkout@v4 # Some comment",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "# Some comment",
-		},
-		{
-			name:  "With path",
-			input: "- uses: oasdiff/oasdiff-action/diff@v0",
-			wantDef: ActionDef{
-				Owner:    "oasdiff",
-				Repo:     "oasdiff-action",
-				Path:     "diff",
-				RefOrSHA: "v0",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "With deep path",
-			input: "- uses: oasdiff/oasdiff-action/tools/diff@v0",
-			wantDef: ActionDef{
-				Owner:    "oasdiff",
-				Repo:     "oasdiff-action",
-				Path:     "tools/diff",
-				RefOrSHA: "v0",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "With commit SHA",
-			input: "- uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "11bd71901bbe5b1630ceea73d27597364c9af683",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "No dash prefix",
-			input: "uses: actions/checkout@v4",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "With quoted YAML string",
-			input: "      uses: \"actions/checkout@v4\"",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "      uses: ",
-			wantComment: "",
-		},
-		{
-			name:  "With quoted uses keyword",
-			input: "      \"uses\": \"actions/checkout@v4\"",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "      \"uses\": ",
-			wantComment: "",
-		},
-		{
-			name:  "With single quoted uses keyword",
-			input: "      'uses': 'actions/checkout@v4'",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "      'uses': ",
-			wantComment: "",
-		},
-		{
-			name:        "Not a uses line",
-			input:       "run: echo hello",
-			wantDef:     ActionDef{},
-			wantOk:      false,
-			wantPrefix:  "",
-			wantComment: "",
-		},
-		{
-			name:        "Empty line",
-			input:       "",
-			wantDef:     ActionDef{},
-			wantOk:      false,
-			wantPrefix:  "",
-			wantComment: "",
-		},
+func TestFindUses(t *testing.T) {
+	input := `jobs:
+  build:
+    steps:
+      # - uses: actions/checkout@v3
+      - uses: actions/checkout@v4 # Some comment
+      - uses: "oasdiff/oasdiff-action/diff@v0"
+      - 'uses': 'actions/setup-go@v5'  # With spaces
+      - {name: Cache, uses: actions/cache@v4}
+      - uses: ./local-action
+      - uses: docker://alpine:3
+      - uses: >-
+          actions/upload-artifact@v4
+      - run: |
+          uses: actions/checkout@v4
+  call:
+    uses: Finatext/workflows-public/.github/workflows/gha-lint.yml@main
+`
+	lines, refs, err := findUses(input)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Split(input, "\n"), lines)
+
+	assert.Equal(t, []usesRef{
 		{
-			name:  "With single quotes",
-			input: "- uses: 'actions/checkout@v4'",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "- uses: ",
-			wantComment: "",
+			def:  ActionDef{Owner: "actions", Repo: "checkout", RefOrSHA: "v4"},
+			job:  "build",
+			line: 5, start: 14, end: 33,
+			comment: "# Some comment",
 		},
 		{
-			name:  "With comment and indentation",
-			input: "    uses: actions/checkout@v4  # This is a comment with spaces",
-			wantDef: ActionDef{
-				Owner:    "actions",
-				Repo:     "checkout",
-				Path:     "",
-				RefOrSHA: "v4",
-			},
-			wantOk:      true,
-			wantPrefix:  "    uses: ",
-			wantComment: "# This is a comment with spaces",
+			def:  ActionDef{Owner: "oasdiff", Repo: "oasdiff-action", Path: "diff", RefOrSHA: "v0"},
+			job:  "build",
+			line: 6, start: 14, end: 46,
+			quote: `"`,
 		},
 		{
-			name:        "With leading comment",
-			input:       "  # This is a leading comment\n- uses: actions/checkout@v4",
-			wantDef:     ActionDef{},
-			wantOk:      false, // Leading comment should not be parsed as an action line
-			wantPrefix:  "",
-			wantComment: "",
+			def:  ActionDef{Owner: "actions", Repo: "setup-go", RefOrSHA: "v5"},
+			job:  "build",
+			line: 7, start: 16, end: 37,
+			quote:   "'",
+			comment: "# With spaces",
 		},
 		{
-			name:        "With uses in the middle of the line",
-			input:       "Some text with uses: actions/checkout@v4 in the middle",
-			wantDef:     ActionDef{},
-			wantOk:      false, // Should not match "uses" if not at the start of the line
-			wantPrefix:  "",
-			wantComment: "",
+			def:  ActionDef{Owner: "actions", Repo: "cache", RefOrSHA: "v4"},
+			job:  "build",
+			line: 8, start: 28, end: 44,
 		},
 		{
-			name:        "With uses in a comment",
-			input:       "# This comment has uses: actions/checkout@v4",
-			wantDef:     ActionDef{},
-			wantOk:      false, // Should not match "uses" in a comment
-			wantPrefix:  "",
-			wantComment: "",
+			def:  ActionDef{Owner: "Finatext", Repo: "workflows-public", Path: ".github/workflows/gha-lint.yml", RefOrSHA: "main"},
+			job:  "call",
+			line: 16, start: 10, end: 71,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotParsed, gotOk := parseLine(tt.input)
-			assert.Equal(t, tt.wantOk, gotOk)
+	}, refs)
 
-			if gotOk {
-				assert.Equal(t, tt.wantDef, gotParsed.def)
-				assert.Equal(t, tt.wantPrefix, gotParsed.prefix)
-				assert.Equal(t, tt.wantComment, gotParsed.comment)
-			}
-		})
-	}
+	_, _, err = findUses("jobs: [")
+	require.Error(t, err)
 }
 
-func TestReplaceLine(t *testing.T) {
+func TestApplyLine(t *testing.T) {
 	tests := []struct {
 		name           string
 		input          string
@@ -477,8 +334,8 @@ func TestReplaceLine(t *testing.T) {
 		},
 		{
 			name:     "Action with subdirectory path",
-			input:    "uses: oasdiff/oasdiff-action/diff@v0",
-			expected: "uses: oasdiff/oasdiff-action/diff@1c611ffb1253a72924624aa4fb662e302b3565d3 # v0.0.21",
+			input:    "- uses: oasdiff/oasdiff-action/diff@v0",
+			expected: "- uses: oasdiff/oasdiff-action/diff@1c611ffb1253a72924624aa4fb662e302b3565d3 # v0.0.21",
 			changed:  true,
 			resolveResults: map[string]ResolvedVersion{
 				"oasdiff/oasdiff-action@v0": {
@@ -489,8 +346,8 @@ func TestReplaceLine(t *testing.T) {
 		},
 		{
 			name:     "Action with deep subdirectory path",
-			input:    "uses: oasdiff/oasdiff-action/tools/diff@v0",
-			expected: "uses: oasdiff/oasdiff-action/tools/diff@1c611ffb1253a72924624aa4fb662e302b3565d3 # v0.0.21",
+			input:    "- uses: oasdiff/oasdiff-action/tools/diff@v0",
+			expected: "- uses: oasdiff/oasdiff-action/tools/diff@1c611ffb1253a72924624aa4fb662e302b3565d3 # v0.0.21",
 			changed:  true,
 			resolveResults: map[string]ResolvedVersion{
 				"oasdiff/oasdiff-action@v0": {
@@ -501,8 +358,8 @@ func TestReplaceLine(t *testing.T) {
 		},
 		{
 			name:     "Action with version and comment",
-			input:    "uses: actions/checkout@v4 # Existing comment",
-			expected: "uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2 # Existing comment",
+			input:    "- uses: actions/checkout@v4 # Existing comment",
+			expected: "- uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2 # Existing comment",
 			changed:  true,
 			resolveResults: map[string]ResolvedVersion{
 				"actions/checkout@v4": {
@@ -513,15 +370,46 @@ func TestReplaceLine(t *testing.T) {
 		},
 		{
 			name:           "Already has SHA",
-			input:          "uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4",
-			expected:       "uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4",
+			input:          "- uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4",
+			expected:       "- uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4",
+			changed:        false,
+			resolveResults: map[string]ResolvedVersion{},
+		},
+		{
+			name:     "Quoted key and value",
+			input:    `- 'uses': "actions/checkout@v4"  # Existing comment`,
+			expected: `- 'uses': "actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683" # v4.2.2 # Existing comment`,
+			changed:  true,
+			resolveResults: map[string]ResolvedVersion{
+				"actions/checkout@v4": {
+					CommitSHA:  "11bd71901bbe5b1630ceea73d27597364c9af683",
+					RefComment: "v4.2.2",
+				},
+			},
+		},
+		{
+			name:     "Flow style step",
+			input:    "- {uses: actions/checkout@v4, with: {fetch-depth: 0}}",
+			expected: "- {uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683, with: {fetch-depth: 0}}",
+			changed:  true,
+			resolveResults: map[string]ResolvedVersion{
+				"actions/checkout@v4": {
+					CommitSHA:  "11bd71901bbe5b1630ceea73d27597364c9af683",
+					RefComment: "v4.2.2",
+				},
+			},
+		},
+		{
+			name:           "Local action",
+			input:          "- uses: ./.github/actions/setup",
+			expected:       "- uses: ./.github/actions/setup",
 			changed:        false,
 			resolveResults: map[string]ResolvedVersion{},
 		},
 		{
 			name:           "Not an action line",
-			input:          "run: echo hello",
-			expected:       "run: echo hello",
+			input:          "- run: echo hello",
+			expected:       "- run: echo hello",
 			changed:        false,
 			resolveResults: map[string]ResolvedVersion{},
 		},
@@ -537,13 +425,13 @@ func TestReplaceLine(t *testing.T) {
 				ignoreOwners: []string{},
 			}
 
-			got, changed, err := r.replaceLine(context.Background(), tt.input)
+			got, changed, err := r.Apply(context.Background(), step(tt.input))
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, step(tt.expected), got)
 			assert.Equal(t, tt.changed, changed)
 		})
 	}
@@ -551,6 +439,12 @@ func TestReplaceLine(t *testing.T) {
 
 type mockResolver struct {
 	resolveResult map[string]ResolvedVersion
+	// commitTags maps owner/repo@sha to tag names.
+	commitTags map[string][]string
+}
+
+func (m *mockResolver) TagsForCommit(ctx context.Context, owner, repo, sha string) ([]string, error) {
+	return m.commitTags[owner+"/"+repo+"@"+sha], nil
 }
 
 func (m *mockResolver) ResolveVersion(ctx context.Context, def ActionDef) (ResolvedVersion, error) {
@@ -686,14 +580,125 @@ func TestStrictPinning202508(t *testing.T) {
 				strictPinning202508: tt.strictPinning202508,
 			}
 
-			got, changed, err := r.replaceLine(context.Background(), tt.input)
+			got, changed, err := r.Apply(context.Background(), step(tt.input))
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, step(tt.expected), got)
 			assert.Equal(t, tt.changed, changed)
 		})
 	}
 }
+
+func TestCheck(t *testing.T) {
+	inputBytes, err := os.ReadFile("../testdata/pin.yml")
+	require.NoError(t, err)
+
+	// Check must not resolve versions, so the resolver has no results.
+	r := &Pin{
+		resolver:     &mockResolver{resolveResult: map[string]ResolvedVersion{}},
+		ignoreOwners: []string{"Finatext"},
+	}
+	findings, err := r.Check(context.Background(), string(inputBytes))
+	require.NoError(t, err)
+
+	var actions []string
+	for _, f := range findings {
+		assert.Equal(t, RuleName, f.Rule)
+		assert.Equal(t, "lint", f.Job)
+		actions = append(actions, f.Action)
+	}
+	assert.Equal(t, []string{
+		"actions/checkout@v4",
+		"actions/checkout@v3",
+		"actions/checkout@v4.2",
+		"actions/setup-go@v5.4",
+		"oasdiff/oasdiff-action/diff@v0",
+	}, actions)
+	assert.Equal(t, 16, findings[0].Line)
+}
+
+func TestDescribePinned(t *testing.T) {
+	p := &Pin{
+		resolver: &mockResolver{commitTags: map[string][]string{
+			"actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683": {"v4.2.2", "v4"},
+		}},
+	}
+
+	got, ok, err := p.DescribePinned(context.Background(), step("- uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2 latest"), 4)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, PinnedRef{
+		Action:  "actions/checkout",
+		SHA:     "11bd71901bbe5b1630ceea73d27597364c9af683",
+		Comment: "v4.2.2",
+		Tags:    []string{"v4.2.2", "v4"},
+	}, got)
+
+	_, ok, err = p.DescribePinned(context.Background(), step("- uses: actions/checkout@v4"), 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestApplyReport(t *testing.T) {
+	input := `jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v4
+      - uses: my-org/setup@v1
+      - uses: docker/login-action@v3
+      - uses: actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b # v5.0.1
+`
+	p := &Pin{
+		resolver: &mockResolver{resolveResult: map[string]ResolvedVersion{
+			"actions/checkout@v4": {CommitSHA: "11bd71901bbe5b1630ceea73d27597364c9af683", RefComment: "v4.2.2"},
+		}},
+		ignoreOwners: []string{"my-org"},
+		ignoreRepos:  []string{"docker/login-action"},
+	}
+
+	got, changes, err := p.ApplyReport(context.Background(), input)
+	require.NoError(t, err)
+	assert.Contains(t, got, "      - uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2\n")
+	assert.Equal(t, []rewrite.Change{
+		{Rule: RuleName, Job: "build", Action: "actions/checkout@v4", Line: 4, To: "11bd71901bbe5b1630ceea73d27597364c9af683", Tag: "v4.2.2"},
+		{Rule: RuleName, Job: "build", Action: "my-org/setup@v1", Line: 5, Skipped: rewrite.SkipIgnoredOwner},
+		{Rule: RuleName, Job: "build", Action: "docker/login-action@v3", Line: 6, Skipped: rewrite.SkipIgnoredRepo},
+		{Rule: RuleName, Job: "build", Action: "actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b", Line: 7, Skipped: rewrite.SkipAlreadyPinned},
+	}, changes)
+
+	// ApplyLine pins only the references on the given line.
+	got, changed, err := p.ApplyLine(context.Background(), strings.Replace(input, "my-org/setup@v1", "actions/checkout@v4", 1), 5)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, got, "      - uses: actions/checkout@v4\n")
+	assert.Contains(t, got, "      - uses: actions/checkout@11bd71901bbe5b1630ceea73d27597364c9af683 # v4.2.2\n")
+
+	// Apply reports a change only if a reference was pinned.
+	_, changed, err = p.Apply(context.Background(), strings.Replace(input, "actions/checkout@v4", "my-org/checkout@v4", 1))
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestApply_CompositeAction(t *testing.T) {
+	p := &Pin{
+		resolver: &mockResolver{resolveResult: map[string]ResolvedVersion{
+			"actions/setup-go@v5": {CommitSHA: "0aaccfd150d50ccaeb58ebd88d36e91967a5f35b", RefComment: "v5.4.0"},
+		}},
+	}
+
+	got, changed, err := p.Apply(context.Background(), `runs:
+  using: composite
+  steps:
+    - uses: actions/setup-go@v5
+`)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `runs:
+  using: composite
+  steps:
+    - uses: actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b # v5.4.0
+`, got)
+}
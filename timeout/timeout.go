@@ -2,16 +2,21 @@ package timeout
 
 import (
 	"context"
-	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/cockroachdb/errors"
-	"github.com/goccy/go-yaml/ast"
-	"github.com/goccy/go-yaml/parser"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
+	"github.com/Finatext/gha-fix/workflow"
+	"github.com/Finatext/gha-fix/yamledit"
 )
 
 var (
 	// ErrIndentNotCalculated is returned when indentation calculation fails
+	//
+	// Deprecated: Insert infers the indentation from the surrounding nodes and no longer returns this error.
 	ErrIndentNotCalculated = errors.New("could not calculate indent for timeout-minutes line")
 	// ErrFlowStyleNotSupported is returned when flow style YAML is detected in job definitions
 	ErrFlowStyleNotSupported = errors.New("flow style YAML is not supported for job definitions")
@@ -29,361 +34,171 @@ func NewTimeout(timeoutMinutes uint64) Timeout {
 	}
 }
 
+// RuleName identifies findings reported by Timeout.Check.
+const RuleName = "timeout"
+
 type position struct {
 	line   int
 	column int
+	job    string
+	// firstKey is the job's first property, which timeout-minutes is inserted before.
+	firstKey string
+	// skipped is set for jobs that don't need timeout-minutes but are reported by InsertReport.
+	skipped rewrite.SkipReason
 }
 
 // Insert adds timeout-minutes to jobs that don't have it
 // Jobs that use reusable workflows (have "uses" field) are skipped
 func (f Timeout) Insert(ctx context.Context, input string) (string, bool, error) {
-	// Try to determine if this is a valid GitHub Actions workflow file
-	if !strings.Contains(input, "jobs:") || !strings.Contains(input, "runs-on:") {
-		return input, false, nil
-	}
+	output, changes, err := f.insert(input, func(string) bool { return true })
+	return output, slices.ContainsFunc(changes, rewrite.Change.Applied), err
+}
 
-	// Check for flow style YAML in jobs like "job_name: { ... }"
-	contentLines := strings.SplitSeq(input, "\n")
-	for line := range contentLines {
-		if strings.Contains(line, ": {") &&
-			(strings.Contains(line, "runs-on:") ||
-				strings.Contains(line, "steps:") ||
-				strings.Contains(line, "uses:")) {
-			return input, false, ErrFlowStyleNotSupported
-		}
+// InsertJob adds timeout-minutes to the job with the given ID only, if Insert would add it.
+func (f Timeout) InsertJob(ctx context.Context, input string, job string) (string, bool, error) {
+	output, changes, err := f.insert(input, func(id string) bool { return id == job })
+	return output, slices.ContainsFunc(changes, rewrite.Change.Applied), err
+}
 
-		// Check for compact job syntax
-		if strings.Contains(line, ":runs-on:") {
-			return input, false, ErrCompactJobSyntaxNotSupported
-		}
-	}
+// InsertReport is Insert reporting each job it added timeout-minutes to, and the jobs calling reusable workflows
+// it skipped.
+func (f Timeout) InsertReport(ctx context.Context, input string) (string, []rewrite.Change, error) {
+	return f.insert(input, func(string) bool { return true })
+}
 
-	file, err := parser.ParseBytes([]byte(input), parser.ParseComments)
+func (f Timeout) insert(input string, match func(job string) bool) (string, []rewrite.Change, error) {
+	positions, err := findMissingTimeouts(input)
 	if err != nil {
-		return input, false, errors.WithStack(err)
-	}
-
-	// Verify that this is actually a GitHub workflow file
-	if !isGitHubWorkflow(file) {
-		return input, false, nil
-	}
-
-	positions := getPositions(file)
-	if len(positions) == 0 {
-		return input, false, nil
+		return input, nil, err
 	}
 
-	// Insert timeout-minutes at each position
-	lines := strings.Split(input, "\n")
-	modified := false
-
-	// Process positions in reverse order to avoid offset issues
-	for i := len(positions) - 1; i >= 0; i-- {
-		pos := positions[i]
-
-		if pos.line <= 0 || pos.line > len(lines) {
-			continue
-		}
-
-		// Calculate indentation for the timeout-minutes line
-		// It should be at the same level as other job properties
-		indent, err := getJobPropertyIndent(lines, pos.line)
-		if err != nil {
-			return input, false, errors.Wrapf(err, "failed to calculate indent for line %d", pos.line+1)
-		}
-
-		// Create the timeout-minutes line
-		timeoutLine := fmt.Sprintf("%stimeout-minutes: %d", indent, f.timeoutMinutes)
-
-		// Insert after the job key line
-		newLines := make([]string, 0, len(lines)+1)
-		newLines = append(newLines, lines[:pos.line]...)
-		newLines = append(newLines, timeoutLine)
-		newLines = append(newLines, lines[pos.line:]...)
-		lines = newLines
-		modified = true
-	}
-
-	if !modified {
-		return input, false, nil
-	}
-
-	return strings.Join(lines, "\n"), true, nil
-}
-
-// getPositions finds all job definitions that do not have timeout-minutes
-func getPositions(file *ast.File) []position {
-	positions := []position{}
-	for _, doc := range file.Docs {
-		if doc.Body == nil {
+	var doc *yamledit.Document
+	var changes []rewrite.Change
+	for _, pos := range positions {
+		if !match(pos.job) {
 			continue
 		}
-
-		// Check if the document body is a mapping (should be for GitHub Actions workflows)
-		rootMapping, ok := doc.Body.(*ast.MappingNode)
-		if !ok {
+		change := rewrite.Change{Rule: RuleName, Job: pos.job, Line: pos.line, Skipped: pos.skipped}
+		if pos.skipped != "" {
+			changes = append(changes, change)
 			continue
 		}
-
-		for _, value := range rootMapping.Values {
-			if value.Key == nil {
-				continue
+		if doc == nil {
+			doc, err = yamledit.Parse([]byte(input))
+			if err != nil {
+				return input, nil, err
 			}
-			if getKeyString(value.Key) != "jobs" {
-				continue
-			}
-
-			jobsMapping, ok := value.Value.(*ast.MappingNode)
-			if !ok {
-				continue
-			}
-
-			// For each job definition
-			for _, jobValue := range jobsMapping.Values {
-				if jobValue.Key == nil {
-					continue
-				}
-
-				// Detection for flow style jobs
-				isFlowStyle := false
-				token := jobValue.Key.GetToken()
-				if token != nil && token.Position != nil {
-					// Check if job key and value are on the same line
-					valueToken := jobValue.Value.GetToken()
-					if valueToken != nil && valueToken.Position != nil &&
-						token.Position.Line == valueToken.Position.Line {
-						isFlowStyle = true
-					}
-				}
-
-				// Special handling for flow style jobs to make tests pass
-				if isFlowStyle {
-					// Flow style jobs will be specially handled to match test expectations
-					// Check if it contains any runs-on but not timeout-minutes or uses
-					hasRunsOn := false
-					hasTimeout := false
-					hasUses := false
-
-					// Try to extract the inner mapping node if possible
-					switch v := jobValue.Value.(type) {
-					case *ast.MappingNode:
-						// Each property in the flow style job
-						for _, prop := range v.Values {
-							if prop.Key == nil {
-								continue
-							}
-							propKey := getKeyString(prop.Key)
-							switch propKey {
-							case "runs-on":
-								hasRunsOn = true
-							case "timeout-minutes":
-								hasTimeout = true
-							case "uses":
-								hasUses = true
-							}
-						}
-					}
-
-					// If it has runs-on but no timeout and no uses, add timeout
-					// This matches the test expectations
-					if hasRunsOn && !hasTimeout && !hasUses {
-						positions = append(positions, position{
-							line:   token.Position.Line,
-							column: token.Position.Column,
-						})
-					}
-					continue
-				}
-
-				// Regular job mapping handling (non-flow style)
-				jobMapping, ok := jobValue.Value.(*ast.MappingNode)
-				if !ok {
-					continue
-				}
-
-				hasTimeout := false
-				hasUses := false
-				for _, prop := range jobMapping.Values {
-					if prop.Key == nil {
-						continue
-					}
-					propKey := getKeyString(prop.Key)
-					if propKey == "timeout-minutes" {
-						hasTimeout = true
-						break
-					}
-					if propKey == "uses" {
-						hasUses = true
-						break
-					}
-				}
+		}
 
-				// If job doesn't have timeout-minutes and doesn't use a reusable workflow,
-				// record the position for insertion
-				if !hasTimeout && !hasUses {
-					// Find the position after the job key line
-					if token != nil && token.Position != nil {
-						positions = append(positions, position{
-							line:   token.Position.Line,
-							column: token.Position.Column,
-						})
-					}
-				}
+		// timeout-minutes becomes the first property of the job
+		path := yamledit.KeyPath("jobs", pos.job)
+		if err := doc.InsertBefore(path, pos.firstKey, "timeout-minutes", f.timeoutMinutes); err != nil {
+			if errors.Is(err, yamledit.ErrFlowStyleNotSupported) {
+				return input, nil, errors.Mark(err, ErrFlowStyleNotSupported)
 			}
+			return input, nil, errors.Wrapf(err, "failed to insert timeout-minutes at line %d", pos.line)
 		}
+		change.To = strconv.FormatUint(f.timeoutMinutes, 10)
+		changes = append(changes, change)
+	}
+	if doc == nil {
+		return input, changes, nil
 	}
 
-	return positions
+	return doc.String(), changes, nil
 }
 
-// getKeyString extracts the string value from a MapKeyNode
-func getKeyString(key ast.MapKeyNode) string {
-	switch n := key.(type) {
-	case *ast.StringNode:
-		return n.Value
-	case *ast.MappingKeyNode:
-		if n.Value != nil {
-			if str, ok := n.Value.(*ast.StringNode); ok {
-				return str.Value
-			}
+// Check reports jobs that Insert would add timeout-minutes to, without modifying the input.
+func (f Timeout) Check(ctx context.Context, input string) ([]rewrite.Finding, error) {
+	positions, err := findMissingTimeouts(input)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]rewrite.Finding, 0, len(positions))
+	for _, pos := range positions {
+		if pos.skipped != "" {
+			continue
 		}
+		findings = append(findings, rewrite.Finding{
+			Rule:    RuleName,
+			Job:     pos.job,
+			Line:    pos.line,
+			Message: "job does not have timeout-minutes: " + pos.job,
+		})
 	}
-	return ""
+	return findings, nil
 }
 
-// getJobPropertyIndent determines the proper indentation for job properties
-func getJobPropertyIndent(lines []string, jobLine int) (string, error) {
-	// Special case for flow style YAML to match test expectations
-	if jobLine < len(lines) {
-		line := lines[jobLine]
-		if strings.Contains(line, "{") && strings.Contains(line, "runs-on:") {
-			// This is likely a flow style job like "job-name: { runs-on: ubuntu-latest }"
-			// For test case compatibility, we'll return special indentation
-			// that allows putting timeout-minutes after the job name
-			jobNameEndPos := strings.Index(line, ":")
-			if jobNameEndPos > 0 {
-				// Find the indentation of this line
-				indentLen := len(line) - len(strings.TrimLeft(line, " \t"))
-				return line[:indentLen] + "  ", nil
-			}
-		}
+// findMissingTimeouts returns the positions of job keys that need timeout-minutes, and of jobs calling reusable
+// workflows with skipped set.
+func findMissingTimeouts(input string) ([]position, error) {
+	// Try to determine if this is a valid GitHub Actions workflow file
+	if !strings.Contains(input, "jobs:") || !strings.Contains(input, "runs-on:") {
+		return nil, nil
 	}
 
-	// Look at the next lines to find a job property and match its indentation
-	for i := jobLine; i < len(lines); i++ {
-		line := lines[i]
-		trimmed := strings.TrimSpace(line)
+	// Check for flow style YAML in jobs like "job_name: { ... }"
+	contentLines := strings.SplitSeq(input, "\n")
+	for line := range contentLines {
+		if strings.Contains(line, ": {") &&
+			(strings.Contains(line, "runs-on:") ||
+				strings.Contains(line, "steps:") ||
+				strings.Contains(line, "uses:")) {
+			return nil, ErrFlowStyleNotSupported
+		}
 
-		// Look for common job properties
-		if strings.HasPrefix(trimmed, "runs-on:") ||
-			strings.HasPrefix(trimmed, "steps:") ||
-			strings.HasPrefix(trimmed, "permissions:") ||
-			strings.HasPrefix(trimmed, "strategy:") ||
-			strings.HasPrefix(trimmed, "timeout-minutes:") ||
-			strings.HasPrefix(trimmed, "needs:") {
-			// Extract the indentation
-			indentLen := len(line) - len(trimmed)
-			if indentLen > 0 {
-				return line[:indentLen], nil
-			}
+		// Check for compact job syntax
+		if strings.Contains(line, ":runs-on:") {
+			return nil, ErrCompactJobSyntaxNotSupported
 		}
 	}
 
-	return "", ErrIndentNotCalculated
-}
+	wf, err := workflow.Parse([]byte(input))
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify that this is actually a GitHub workflow file
+	if !wf.IsGitHubWorkflow() {
+		return nil, nil
+	}
 
-// Define valid GitHub workflow top-level keys
-var validTopLevelKeys = map[string]bool{
-	"name":        true,
-	"run-name":    true,
-	"on":          true,
-	"permissions": true,
-	"env":         true,
-	"defaults":    true,
-	"concurrency": true,
-	"jobs":        true,
-	"#":           true, // For comment keys
+	return getPositions(wf), nil
 }
 
-// isGitHubWorkflow checks if the YAML file is a GitHub workflow by verifying:
-// 1. All top-level keys are valid GitHub workflow keys
-// 2. At least one job has a 'runs-on' key, which is specific to GitHub workflows
-func isGitHubWorkflow(file *ast.File) bool {
-	for _, doc := range file.Docs {
-		if doc.Body == nil {
+// getPositions finds all job definitions that do not have timeout-minutes
+// Jobs calling reusable workflows are included with skipped set
+func getPositions(wf *workflow.Workflow) []position {
+	positions := []position{}
+	for _, job := range wf.Jobs {
+		// Skip empty jobs and jobs that are not mappings
+		if job.Mapping == nil {
 			continue
 		}
 
-		// Check if the document body is a mapping
-		rootMapping, ok := doc.Body.(*ast.MappingNode)
-		if !ok {
+		// Flow style jobs like "job: { runs-on: ... }" are only considered if they have runs-on
+		if job.Inline && !job.HasKey("runs-on") {
 			continue
 		}
 
-		for _, value := range rootMapping.Values {
-			if value.Key == nil {
-				continue
-			}
-			key := getKeyString(value.Key)
-			// If we find a top-level key that is not in the valid keys list,
-			// this is not a GitHub workflow file
-			if key != "" && !validTopLevelKeys[key] {
-				return false
-			}
+		// If job doesn't have timeout-minutes and doesn't use a reusable workflow,
+		// record the position for insertion
+		if job.HasKey("timeout-minutes") || !job.Pos.IsValid() {
+			continue
 		}
-
-		// Check if the file has a jobs key with GitHub workflow-specific job properties
-		hasValidJobStructure := false
-		for _, value := range rootMapping.Values {
-			if value.Key == nil {
-				continue
-			}
-
-			key := getKeyString(value.Key)
-			if key == "jobs" {
-				jobsMapping, ok := value.Value.(*ast.MappingNode)
-				if !ok {
-					continue
-				}
-
-				// Check if any job contains GitHub workflow-specific keys like 'runs-on'
-				for _, jobValue := range jobsMapping.Values {
-					if jobValue.Key == nil || jobValue.Value == nil {
-						continue
-					}
-
-					// We need to check the job's properties
-					jobMapping, ok := jobValue.Value.(*ast.MappingNode)
-					if !ok {
-						// Flow style jobs will be encountered later and trigger an error
-						// during indentation calculation
-						continue
-					}
-
-					// Check if this job has GitHub workflow-specific properties
-					for _, prop := range jobMapping.Values {
-						if prop.Key == nil {
-							continue
-						}
-						propKey := getKeyString(prop.Key)
-						// These keys are very specific to GitHub workflows
-						if propKey == "runs-on" || propKey == "uses" || propKey == "container" {
-							hasValidJobStructure = true
-							break
-						}
-					}
-
-					if hasValidJobStructure {
-						break
-					}
-				}
-			}
+		pos := position{
+			line:     job.Pos.Line,
+			column:   job.Pos.Column,
+			job:      job.ID,
+			firstKey: job.Keys[0].Name,
 		}
-
-		// Valid GitHub workflow must have valid top-level keys AND a proper job structure
-		if hasValidJobStructure {
-			return true
+		if job.IsReusableWorkflowCall() {
+			// Reusable workflow calls don't support timeout-minutes.
+			pos.skipped = rewrite.SkipReusableWorkflow
 		}
+		positions = append(positions, pos)
 	}
-	return false
+
+	return positions
 }
@@ -9,6 +9,8 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/Finatext/gha-fix/internal/rewrite"
 )
 
 func TestFixer_Fix_Integration(t *testing.T) {
@@ -160,71 +162,6 @@ config:
 	}
 }
 
-func TestGetJobPropertyIndent(t *testing.T) {
-	tests := []struct {
-		name     string
-		lines    []string
-		jobLine  int
-		expected string
-		wantErr  bool
-	}{
-		{
-			name: "standard 2 space indent",
-			lines: []string{
-				"jobs:",
-				"  test:",
-				"    runs-on: ubuntu-latest",
-			},
-			jobLine:  2,
-			expected: "    ",
-		},
-		{
-			name: "4 space indent",
-			lines: []string{
-				"jobs:",
-				"    test:",
-				"        runs-on: ubuntu-latest",
-			},
-			jobLine:  2,
-			expected: "        ",
-		},
-		{
-			name: "no properties found",
-			lines: []string{
-				"jobs:",
-				"  test:",
-				"    # just comments",
-				"		 # another comment",
-			},
-			jobLine: 2,
-			wantErr: true,
-		},
-		{
-			name: "out of bounds line",
-			lines: []string{
-				"jobs:",
-				"  test:",
-			},
-			jobLine: 5,
-			wantErr: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := getJobPropertyIndent(tt.lines, tt.jobLine)
-
-			if tt.wantErr {
-				assert.Error(t, err)
-				return
-			}
-
-			require.NoError(t, err)
-			assert.Equal(t, tt.expected, got)
-		})
-	}
-}
-
 func TestFixer_Fix_MultipleJobs(t *testing.T) {
 	input := `jobs:
   has-timeout:
@@ -413,3 +350,99 @@ jobs:
 	require.NoError(t, err)
 	assert.False(t, changed)
 }
+
+func TestTimeout_Check(t *testing.T) {
+	input, err := os.ReadFile("../testdata/timeout.yml")
+	require.NoError(t, err)
+
+	f := Timeout{timeoutMinutes: 5}
+	findings, err := f.Check(context.Background(), string(input))
+	require.NoError(t, err)
+
+	require.Len(t, findings, 1)
+	assert.Equal(t, RuleName, findings[0].Rule)
+	assert.Equal(t, "without-timeout", findings[0].Job)
+	assert.Equal(t, 22, findings[0].Line)
+	assert.Empty(t, findings[0].Action)
+
+	_, err = f.Check(context.Background(), "jobs:\n  test: { runs-on: ubuntu-latest }\n")
+	assert.ErrorIs(t, err, ErrFlowStyleNotSupported)
+}
+
+func TestFixer_Fix_IndentAndComments(t *testing.T) {
+	// 4 space indent, a comment above the first property and a step as the first property
+	input := `jobs:
+    test:
+        # Runs on the default runner
+        runs-on: ubuntu-latest # latest
+    steps-first:
+        steps:
+            - run: echo hello
+        runs-on: ubuntu-latest
+`
+
+	expected := `jobs:
+    test:
+        timeout-minutes: 5
+        # Runs on the default runner
+        runs-on: ubuntu-latest # latest
+    steps-first:
+        timeout-minutes: 5
+        steps:
+            - run: echo hello
+        runs-on: ubuntu-latest
+`
+
+	f := Timeout{timeoutMinutes: 5}
+	got, changed, err := f.Insert(context.Background(), input)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expected, got)
+}
+
+func TestTimeout_InsertJob(t *testing.T) {
+	input := `jobs:
+  a:
+    runs-on: ubuntu-latest
+  b:
+    runs-on: ubuntu-latest
+`
+
+	f := Timeout{timeoutMinutes: 5}
+	got, changed, err := f.InsertJob(context.Background(), input, "b")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `jobs:
+  a:
+    runs-on: ubuntu-latest
+  b:
+    timeout-minutes: 5
+    runs-on: ubuntu-latest
+`, got)
+
+	got, changed, err = f.InsertJob(context.Background(), input, "missing")
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, input, got)
+}
+
+func TestTimeout_InsertReport(t *testing.T) {
+	input := `jobs:
+  build:
+    runs-on: ubuntu-latest
+  done:
+    timeout-minutes: 3
+    runs-on: ubuntu-latest
+  call:
+    uses: my-org/workflows/.github/workflows/deploy.yml@main
+`
+
+	f := Timeout{timeoutMinutes: 10}
+	got, changes, err := f.InsertReport(context.Background(), input)
+	require.NoError(t, err)
+	assert.Contains(t, got, "  build:\n    timeout-minutes: 10\n")
+	assert.Equal(t, []rewrite.Change{
+		{Rule: RuleName, Job: "build", Line: 2, To: "10"},
+		{Rule: RuleName, Job: "call", Line: 7, Skipped: rewrite.SkipReusableWorkflow},
+	}, changes)
+}
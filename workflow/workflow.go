@@ -0,0 +1,567 @@
+// Package workflow parses GitHub Actions workflow files into a typed document model.
+//
+// The model keeps the source position of every node and a reference to the underlying goccy/go-yaml AST, so that
+// fixers and external tools can report findings and edit files without re-deriving the workflow structure.
+package workflow
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// Position is a 1-based line and column in the source.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position points into the source.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func positionOf(node ast.Node) Position {
+	if node == nil {
+		return Position{}
+	}
+	tk := node.GetToken()
+	if tk == nil || tk.Position == nil {
+		return Position{}
+	}
+	return Position{Line: tk.Position.Line, Column: tk.Position.Column}
+}
+
+// Scalar is a scalar value with its position.
+type Scalar struct {
+	Value string
+	Pos   Position
+	Node  ast.Node
+}
+
+// IsExpression reports whether the value is a `${{ ... }}` expression.
+func (s Scalar) IsExpression() bool {
+	return strings.HasPrefix(strings.TrimSpace(s.Value), "${{")
+}
+
+// Key is a mapping key with its position.
+type Key struct {
+	Name string
+	Pos  Position
+}
+
+// Workflow is a parsed workflow file.
+type Workflow struct {
+	Name *Scalar
+	// On lists the events that trigger the workflow, in source order.
+	On          []Trigger
+	Permissions *Permissions
+	Jobs        []*Job
+	// ActionSteps are the `runs.steps` of a composite action metadata file (action.yml), which is parsed like a
+	// workflow so that the `uses` references of its steps can be found.
+	ActionSteps []*Step
+	// Keys are the top-level keys in source order.
+	Keys []Key
+
+	// File is the parsed AST including comments.
+	File *ast.File
+	// Body is the root mapping of the document. Nil if the document is not a mapping.
+	Body *ast.MappingNode
+}
+
+// Trigger is an event listed under `on`.
+type Trigger struct {
+	Event string
+	Pos   Position
+	// Config is the event configuration, e.g. the `branches` filter. Nil for events without configuration.
+	Config ast.Node
+}
+
+// Permissions is a `permissions` block of a workflow or a job.
+type Permissions struct {
+	Pos Position
+	// All is set for the shorthand forms `read-all`, `write-all` and `{}` (as an empty string with no Scopes).
+	All *Scalar
+	// Scopes lists individual permissions, e.g. `contents: read`, in source order.
+	Scopes []Permission
+}
+
+// Permission is a single scope of a permissions block.
+type Permission struct {
+	Scope  string
+	Access Scalar
+	Pos    Position
+}
+
+// Job is an entry under `jobs`.
+type Job struct {
+	ID string
+	// Pos is the position of the job ID key.
+	Pos Position
+	// Inline is true when the job definition starts on the same line as its ID, e.g. flow style `job: { ... }`.
+	Inline bool
+
+	Name           *Scalar
+	RunsOn         []Scalar
+	TimeoutMinutes *Scalar
+	// Uses is set for jobs calling a reusable workflow.
+	Uses        *Uses
+	Needs       []Scalar
+	Permissions *Permissions
+	Steps       []*Step
+	// Keys are the job's keys in source order.
+	Keys []Key
+
+	// Node is the `id: ...` mapping entry of the job.
+	Node *ast.MappingValueNode
+	// Mapping is the job definition. Nil if the job is not a mapping, e.g. an empty job.
+	Mapping *ast.MappingNode
+}
+
+// HasKey reports whether the job defines key.
+func (j *Job) HasKey(key string) bool {
+	for _, k := range j.Keys {
+		if k.Name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReusableWorkflowCall reports whether the job calls a reusable workflow instead of running steps.
+func (j *Job) IsReusableWorkflowCall() bool {
+	return j.HasKey("uses")
+}
+
+// Step is an item of a job's `steps`.
+type Step struct {
+	// Index is the 0-based index in the job's steps.
+	Index int
+	Pos   Position
+	ID    *Scalar
+	Name  *Scalar
+	Uses  *Uses
+	Run   *Scalar
+	// Keys are the step's keys in source order.
+	Keys []Key
+
+	// Mapping is the step definition. Nil if the step is not a mapping.
+	Mapping *ast.MappingNode
+}
+
+// Uses is a `uses` reference of a step or a job.
+type Uses struct {
+	Scalar
+}
+
+// Action parses the reference. See ParseActionRef.
+func (u Uses) Action() (ActionRef, bool) {
+	return ParseActionRef(u.Value)
+}
+
+// ActionRef is a remote action or reusable workflow reference: owner/repo[/path]@ref.
+type ActionRef struct {
+	Owner string
+	Repo  string
+	// Path is the subdirectory or workflow file path after the repository name. Empty if none.
+	Path string
+	Ref  string
+}
+
+// String returns the reference in the `uses` format: owner/repo[/path]@ref.
+func (a ActionRef) String() string {
+	name := a.Owner + "/" + a.Repo
+	if a.Path != "" {
+		name += "/" + a.Path
+	}
+	return name + "@" + a.Ref
+}
+
+// ParseActionRef parses a `uses` value like "owner/repo/path@ref".
+// Local actions ("./path") and Docker images ("docker://...") are not remote references and return false.
+func ParseActionRef(uses string) (ActionRef, bool) {
+	name, ref, ok := strings.Cut(uses, "@")
+	if !ok || ref == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "://") {
+		return ActionRef{}, false
+	}
+
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ActionRef{}, false
+	}
+
+	a := ActionRef{
+		Owner: parts[0],
+		Repo:  parts[1],
+		Ref:   ref,
+	}
+	if len(parts) == 3 {
+		a.Path = parts[2]
+	}
+	return a, true
+}
+
+// UsesRef is a `uses` reference together with the job (and step) it belongs to.
+type UsesRef struct {
+	// Job is nil for the steps of a composite action.
+	Job *Job
+	// Step is nil for reusable workflow calls.
+	Step *Step
+	Uses *Uses
+}
+
+// AllUses returns all `uses` references of the jobs in source order, followed by those of ActionSteps.
+func (w *Workflow) AllUses() []UsesRef {
+	var refs []UsesRef
+	for _, job := range w.Jobs {
+		if job.Uses != nil {
+			refs = append(refs, UsesRef{Job: job, Uses: job.Uses})
+		}
+		for _, step := range job.Steps {
+			if step.Uses != nil {
+				refs = append(refs, UsesRef{Job: job, Step: step, Uses: step.Uses})
+			}
+		}
+	}
+	for _, step := range w.ActionSteps {
+		if step.Uses != nil {
+			refs = append(refs, UsesRef{Step: step, Uses: step.Uses})
+		}
+	}
+	return refs
+}
+
+// JobAt returns the job whose definition contains line, or nil.
+func (w *Workflow) JobAt(line int) *Job {
+	var found *Job
+	for _, job := range w.Jobs {
+		if job.Pos.Line > line {
+			break
+		}
+		found = job
+	}
+	return found
+}
+
+// Job returns the job with id, or nil.
+func (w *Workflow) Job(id string) *Job {
+	for _, job := range w.Jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// validTopLevelKeys are the keys allowed at the top level of a GitHub workflow.
+var validTopLevelKeys = map[string]bool{
+	"name":        true,
+	"run-name":    true,
+	"on":          true,
+	"permissions": true,
+	"env":         true,
+	"defaults":    true,
+	"concurrency": true,
+	"jobs":        true,
+	"#":           true, // For comment keys
+}
+
+// IsGitHubWorkflow reports whether the document looks like a GitHub workflow rather than another YAML file:
+// all top-level keys are valid workflow keys, and at least one job has `runs-on`, `uses` or `container`.
+func (w *Workflow) IsGitHubWorkflow() bool {
+	if w.Body == nil {
+		return false
+	}
+	for _, key := range w.Keys {
+		if key.Name != "" && !validTopLevelKeys[key.Name] {
+			return false
+		}
+	}
+	for _, job := range w.Jobs {
+		if job.HasKey("runs-on") || job.HasKey("uses") || job.HasKey("container") {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses workflow content. Comments are kept in the AST.
+//
+// Parse does not validate the workflow: documents that are not workflows are returned with the fields that could
+// be found. Use IsGitHubWorkflow to tell workflows from other YAML files. Only the first document with a mapping
+// body is modeled.
+func Parse(content []byte) (*Workflow, error) {
+	file, err := parser.ParseBytes(content, parser.ParseComments)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	w := &Workflow{File: file}
+	for _, doc := range file.Docs {
+		if body, ok := doc.Body.(*ast.MappingNode); ok {
+			w.Body = body
+			break
+		}
+	}
+	if w.Body == nil {
+		return w, nil
+	}
+
+	for _, entry := range w.Body.Values {
+		name := KeyString(entry.Key)
+		w.Keys = append(w.Keys, Key{Name: name, Pos: positionOf(entry.Key)})
+
+		switch name {
+		case "name":
+			w.Name = scalar(entry.Value)
+		case "on":
+			w.On = parseTriggers(entry.Value)
+		case "permissions":
+			w.Permissions = parsePermissions(entry)
+		case "jobs":
+			jobs, ok := entry.Value.(*ast.MappingNode)
+			if !ok {
+				continue
+			}
+			for _, jobEntry := range jobs.Values {
+				w.Jobs = append(w.Jobs, parseJob(jobEntry))
+			}
+		case "runs":
+			w.ActionSteps = parseActionSteps(entry.Value)
+		}
+	}
+
+	return w, nil
+}
+
+// parseActionSteps returns the steps of a composite action's `runs` mapping.
+func parseActionSteps(node ast.Node) []*Step {
+	runs, ok := node.(*ast.MappingNode)
+	if !ok {
+		return nil
+	}
+	var steps []*Step
+	for _, prop := range runs.Values {
+		if KeyString(prop.Key) != "steps" {
+			continue
+		}
+		seq, ok := prop.Value.(*ast.SequenceNode)
+		if !ok {
+			continue
+		}
+		for i, stepNode := range seq.Values {
+			steps = append(steps, parseStep(i, stepNode))
+		}
+	}
+	return steps
+}
+
+func parseTriggers(node ast.Node) []Trigger {
+	switch n := node.(type) {
+	case *ast.SequenceNode:
+		triggers := make([]Trigger, 0, len(n.Values))
+		for _, v := range n.Values {
+			if s := scalar(v); s != nil {
+				triggers = append(triggers, Trigger{Event: s.Value, Pos: s.Pos})
+			}
+		}
+		return triggers
+	case *ast.MappingNode:
+		triggers := make([]Trigger, 0, len(n.Values))
+		for _, v := range n.Values {
+			t := Trigger{Event: KeyString(v.Key), Pos: positionOf(v.Key)}
+			if _, isNull := v.Value.(*ast.NullNode); !isNull {
+				t.Config = v.Value
+			}
+			triggers = append(triggers, t)
+		}
+		return triggers
+	default:
+		if s := scalar(node); s != nil {
+			return []Trigger{{Event: s.Value, Pos: s.Pos}}
+		}
+	}
+	return nil
+}
+
+func parsePermissions(entry *ast.MappingValueNode) *Permissions {
+	p := &Permissions{Pos: positionOf(entry.Key)}
+	mapping, ok := entry.Value.(*ast.MappingNode)
+	if !ok {
+		p.All = scalar(entry.Value)
+		return p
+	}
+	for _, v := range mapping.Values {
+		access := scalar(v.Value)
+		if access == nil {
+			continue
+		}
+		p.Scopes = append(p.Scopes, Permission{Scope: KeyString(v.Key), Access: *access, Pos: positionOf(v.Key)})
+	}
+	if len(p.Scopes) == 0 {
+		p.All = &Scalar{Pos: positionOf(mapping), Node: mapping}
+	}
+	return p
+}
+
+func parseJob(entry *ast.MappingValueNode) *Job {
+	job := &Job{
+		ID:   KeyString(entry.Key),
+		Pos:  positionOf(entry.Key),
+		Node: entry,
+	}
+	if valuePos := positionOf(entry.Value); valuePos.IsValid() && valuePos.Line == job.Pos.Line {
+		job.Inline = true
+	}
+
+	mapping, ok := entry.Value.(*ast.MappingNode)
+	if !ok {
+		return job
+	}
+	job.Mapping = mapping
+
+	for _, prop := range mapping.Values {
+		name := KeyString(prop.Key)
+		job.Keys = append(job.Keys, Key{Name: name, Pos: positionOf(prop.Key)})
+
+		switch name {
+		case "name":
+			job.Name = scalar(prop.Value)
+		case "runs-on":
+			job.RunsOn = parseRunsOn(prop.Value)
+		case "timeout-minutes":
+			job.TimeoutMinutes = scalar(prop.Value)
+		case "uses":
+			if s := scalar(prop.Value); s != nil {
+				job.Uses = &Uses{Scalar: *s}
+			}
+		case "needs":
+			job.Needs = scalars(prop.Value)
+		case "permissions":
+			job.Permissions = parsePermissions(prop)
+		case "steps":
+			steps, ok := prop.Value.(*ast.SequenceNode)
+			if !ok {
+				continue
+			}
+			for i, stepNode := range steps.Values {
+				job.Steps = append(job.Steps, parseStep(i, stepNode))
+			}
+		}
+	}
+
+	return job
+}
+
+// parseRunsOn returns runner labels from the scalar, sequence and `{group, labels}` forms.
+func parseRunsOn(node ast.Node) []Scalar {
+	mapping, ok := node.(*ast.MappingNode)
+	if !ok {
+		return scalars(node)
+	}
+	var labels []Scalar
+	for _, v := range mapping.Values {
+		if KeyString(v.Key) == "labels" {
+			labels = append(labels, scalars(v.Value)...)
+		}
+	}
+	return labels
+}
+
+func parseStep(index int, node ast.Node) *Step {
+	step := &Step{Index: index, Pos: positionOf(node)}
+	mapping, ok := node.(*ast.MappingNode)
+	if !ok {
+		return step
+	}
+	step.Mapping = mapping
+
+	for _, prop := range mapping.Values {
+		name := KeyString(prop.Key)
+		step.Keys = append(step.Keys, Key{Name: name, Pos: positionOf(prop.Key)})
+
+		switch name {
+		case "id":
+			step.ID = scalar(prop.Value)
+		case "name":
+			step.Name = scalar(prop.Value)
+		case "uses":
+			if s := scalar(prop.Value); s != nil {
+				step.Uses = &Uses{Scalar: *s}
+			}
+		case "run":
+			step.Run = scalar(prop.Value)
+		}
+	}
+	return step
+}
+
+// KeyString extracts the string value from a mapping key, including explicit `? key` keys.
+func KeyString(key ast.MapKeyNode) string {
+	if key == nil {
+		return ""
+	}
+	if n, ok := key.(*ast.MappingKeyNode); ok {
+		if n.Value == nil {
+			return ""
+		}
+		if s := scalar(n.Value); s != nil {
+			return s.Value
+		}
+		return ""
+	}
+	if s := scalar(key); s != nil {
+		return s.Value
+	}
+	return ""
+}
+
+// scalar returns the value of a scalar node, or nil for collections and null.
+func scalar(node ast.Node) *Scalar {
+	if node == nil {
+		return nil
+	}
+	if tagged, ok := node.(*ast.TagNode); ok {
+		return scalar(tagged.Value)
+	}
+	if _, ok := node.(ast.ScalarNode); !ok {
+		return nil
+	}
+	if _, ok := node.(*ast.NullNode); ok {
+		return nil
+	}
+	value := ""
+	switch n := node.(type) {
+	case *ast.StringNode:
+		value = n.Value
+	case *ast.LiteralNode:
+		value = n.Value.Value
+	default:
+		tk := node.GetToken()
+		if tk == nil {
+			return nil
+		}
+		value = tk.Value
+	}
+	return &Scalar{Value: value, Pos: positionOf(node), Node: node}
+}
+
+// scalars returns the values of a scalar or a sequence of scalars.
+func scalars(node ast.Node) []Scalar {
+	seq, ok := node.(*ast.SequenceNode)
+	if !ok {
+		if s := scalar(node); s != nil {
+			return []Scalar{*s}
+		}
+		return nil
+	}
+	values := make([]Scalar, 0, len(seq.Values))
+	for _, v := range seq.Values {
+		if s := scalar(v); s != nil {
+			values = append(values, *s)
+		}
+	}
+	return values
+}
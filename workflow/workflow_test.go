@@ -0,0 +1,244 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `name: CI
+on:
+  push:
+    branches: [main]
+  pull_request:
+permissions: read-all
+jobs:
+  # Build job
+  build:
+    name: Build
+    runs-on: [self-hosted, linux]
+    timeout-minutes: ${{ inputs.timeout }}
+    permissions:
+      contents: read
+      id-token: write
+    steps:
+      - uses: actions/checkout@v4
+      - id: test
+        run: go test ./...
+      - uses: ./local-action
+  deploy:
+    needs: build
+    uses: octo-org/workflows/.github/workflows/deploy.yml@v1
+  group:
+    runs-on:
+      group: large
+      labels: ubuntu-latest
+    timeout-minutes: 10
+    steps:
+      - uses: actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b # v5.0.2
+`
+
+func TestParse(t *testing.T) {
+	wf, err := Parse([]byte(sample))
+	require.NoError(t, err)
+
+	require.NotNil(t, wf.Name)
+	assert.Equal(t, "CI", wf.Name.Value)
+	assert.True(t, wf.IsGitHubWorkflow())
+
+	require.Len(t, wf.On, 2)
+	assert.Equal(t, "push", wf.On[0].Event)
+	assert.Equal(t, Position{Line: 3, Column: 3}, wf.On[0].Pos)
+	assert.NotNil(t, wf.On[0].Config)
+	assert.Equal(t, "pull_request", wf.On[1].Event)
+	assert.Nil(t, wf.On[1].Config)
+
+	require.NotNil(t, wf.Permissions)
+	require.NotNil(t, wf.Permissions.All)
+	assert.Equal(t, "read-all", wf.Permissions.All.Value)
+	assert.Empty(t, wf.Permissions.Scopes)
+
+	require.Len(t, wf.Jobs, 3)
+
+	build := wf.Job("build")
+	require.NotNil(t, build)
+	assert.Equal(t, Position{Line: 9, Column: 3}, build.Pos)
+	assert.False(t, build.Inline)
+	assert.Equal(t, "Build", build.Name.Value)
+	assert.Equal(t, []string{"self-hosted", "linux"}, values(build.RunsOn))
+	require.NotNil(t, build.TimeoutMinutes)
+	assert.True(t, build.TimeoutMinutes.IsExpression())
+	require.NotNil(t, build.Permissions)
+	assert.Nil(t, build.Permissions.All)
+	require.Len(t, build.Permissions.Scopes, 2)
+	assert.Equal(t, "id-token", build.Permissions.Scopes[1].Scope)
+	assert.Equal(t, "write", build.Permissions.Scopes[1].Access.Value)
+	require.Len(t, build.Steps, 3)
+	assert.Equal(t, Position{Line: 17, Column: 15}, build.Steps[0].Uses.Pos)
+	assert.Equal(t, "test", build.Steps[1].ID.Value)
+	assert.Equal(t, "go test ./...", build.Steps[1].Run.Value)
+	assert.False(t, build.IsReusableWorkflowCall())
+
+	deploy := wf.Job("deploy")
+	require.NotNil(t, deploy)
+	assert.True(t, deploy.IsReusableWorkflowCall())
+	assert.Equal(t, []string{"build"}, values(deploy.Needs))
+	assert.Empty(t, deploy.Steps)
+
+	group := wf.Job("group")
+	require.NotNil(t, group)
+	assert.Equal(t, []string{"ubuntu-latest"}, values(group.RunsOn))
+	assert.Equal(t, "10", group.TimeoutMinutes.Value)
+	assert.False(t, group.TimeoutMinutes.IsExpression())
+
+	assert.Nil(t, wf.Job("missing"))
+}
+
+func TestWorkflow_AllUses(t *testing.T) {
+	wf, err := Parse([]byte(sample))
+	require.NoError(t, err)
+
+	var got []string
+	for _, ref := range wf.AllUses() {
+		got = append(got, ref.Job.ID+":"+ref.Uses.Value)
+	}
+	assert.Equal(t, []string{
+		"build:actions/checkout@v4",
+		"build:./local-action",
+		"deploy:octo-org/workflows/.github/workflows/deploy.yml@v1",
+		"group:actions/setup-go@0aaccfd150d50ccaeb58ebd88d36e91967a5f35b",
+	}, got)
+
+	assert.Nil(t, wf.AllUses()[2].Step)
+	assert.Equal(t, 2, wf.AllUses()[1].Step.Index)
+}
+
+func TestWorkflow_AllUses_CompositeAction(t *testing.T) {
+	wf, err := Parse([]byte(`name: Setup
+runs:
+  using: composite
+  steps:
+    - uses: actions/setup-go@v5
+    - run: make
+      shell: bash
+    - uses: actions/cache@v4
+`))
+	require.NoError(t, err)
+	assert.False(t, wf.IsGitHubWorkflow())
+
+	refs := wf.AllUses()
+	require.Len(t, refs, 2)
+	assert.Nil(t, refs[0].Job)
+	assert.Equal(t, "actions/setup-go@v5", refs[0].Uses.Value)
+	assert.Equal(t, Position{Line: 5, Column: 13}, refs[0].Uses.Pos)
+	assert.Equal(t, 2, refs[1].Step.Index)
+}
+
+func TestWorkflow_JobAt(t *testing.T) {
+	wf, err := Parse([]byte(sample))
+	require.NoError(t, err)
+
+	assert.Nil(t, wf.JobAt(1))
+	assert.Nil(t, wf.JobAt(8))
+	assert.Equal(t, "build", wf.JobAt(9).ID)
+	assert.Equal(t, "build", wf.JobAt(20).ID)
+	assert.Equal(t, "deploy", wf.JobAt(22).ID)
+	assert.Equal(t, "group", wf.JobAt(100).ID)
+}
+
+func TestParse_Triggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"scalar", "on: push\n", []string{"push"}},
+		{"sequence", "on: [push, pull_request]\n", []string{"push", "pull_request"}},
+		{"mapping", "on:\n  workflow_dispatch:\n  schedule:\n    - cron: '0 0 * * *'\n", []string{"workflow_dispatch", "schedule"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, err := Parse([]byte(tt.content))
+			require.NoError(t, err)
+			var events []string
+			for _, trigger := range wf.On {
+				events = append(events, trigger.Event)
+			}
+			assert.Equal(t, tt.want, events)
+		})
+	}
+}
+
+func TestWorkflow_IsGitHubWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"workflow", "on: push\njobs:\n  a:\n    runs-on: ubuntu-latest\n", true},
+		{"reusable workflow call", "on: push\njobs:\n  a:\n    uses: o/r/.github/workflows/w.yml@v1\n", true},
+		{"unknown top-level key", "on: push\nservices:\n  a: {}\njobs:\n  a:\n    runs-on: ubuntu-latest\n", false},
+		{"no runnable job", "on: push\njobs:\n  a:\n    steps: []\n", false},
+		{"not a mapping", "- a\n- b\n", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, err := Parse([]byte(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, wf.IsGitHubWorkflow())
+		})
+	}
+}
+
+func TestParse_InlineJob(t *testing.T) {
+	wf, err := Parse([]byte("on: push\njobs:\n  a: { runs-on: ubuntu-latest }\n  b:\n"))
+	require.NoError(t, err)
+
+	require.Len(t, wf.Jobs, 2)
+	assert.True(t, wf.Jobs[0].Inline)
+	assert.Equal(t, []string{"ubuntu-latest"}, values(wf.Jobs[0].RunsOn))
+	// An empty job has no mapping.
+	assert.Nil(t, wf.Jobs[1].Mapping)
+}
+
+func TestParse_Error(t *testing.T) {
+	_, err := Parse([]byte("on: push\njobs:\n  a: [\n"))
+	require.Error(t, err)
+}
+
+func TestParseActionRef(t *testing.T) {
+	tests := []struct {
+		uses string
+		want ActionRef
+		ok   bool
+	}{
+		{"actions/checkout@v4", ActionRef{Owner: "actions", Repo: "checkout", Ref: "v4"}, true},
+		{"github/codeql-action/init@v3", ActionRef{Owner: "github", Repo: "codeql-action", Path: "init", Ref: "v3"}, true},
+		{"o/r/.github/workflows/w.yml@main", ActionRef{Owner: "o", Repo: "r", Path: ".github/workflows/w.yml", Ref: "main"}, true},
+		{"./local-action", ActionRef{}, false},
+		{"docker://alpine:3.20", ActionRef{}, false},
+		{"actions/checkout", ActionRef{}, false},
+		{"actions@v1", ActionRef{}, false},
+		{"actions/checkout@", ActionRef{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uses, func(t *testing.T) {
+			got, ok := ParseActionRef(tt.uses)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+			if ok {
+				assert.Equal(t, tt.uses, got.String())
+			}
+		})
+	}
+}
+
+func values(scalars []Scalar) []string {
+	var vs []string
+	for _, s := range scalars {
+		vs = append(vs, s.Value)
+	}
+	return vs
+}
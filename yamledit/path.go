@@ -0,0 +1,63 @@
+package yamledit
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Path addresses a node by mapping keys and sequence indexes from the document root. The empty path is the
+// root node.
+type Path []Segment
+
+// Segment is a mapping key or a sequence index.
+type Segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Key returns a segment selecting key in a mapping.
+func Key(key string) Segment {
+	return Segment{key: key}
+}
+
+// Index returns a segment selecting the item at index in a sequence.
+func Index(index int) Segment {
+	return Segment{index: index, isIndex: true}
+}
+
+// KeyPath returns a path of mapping keys, e.g. KeyPath("jobs", "build").
+func KeyPath(keys ...string) Path {
+	p := make(Path, 0, len(keys))
+	for _, key := range keys {
+		p = append(p, Key(key))
+	}
+	return p
+}
+
+// Key returns a copy of the path extended with key.
+func (p Path) Key(key string) Path {
+	return append(p[:len(p):len(p)], Key(key))
+}
+
+// Index returns a copy of the path extended with index.
+func (p Path) Index(index int) Path {
+	return append(p[:len(p):len(p)], Index(index))
+}
+
+// String returns the path like "$.jobs.build.steps[0]". Keys that are not simple names are quoted.
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range p {
+		switch {
+		case seg.isIndex:
+			b.WriteString("[" + strconv.Itoa(seg.index) + "]")
+		case seg.key != "" && !strings.ContainsAny(seg.key, ".[]'\" "):
+			b.WriteString("." + seg.key)
+		default:
+			b.WriteString("." + strconv.Quote(seg.key))
+		}
+	}
+	return b.String()
+}
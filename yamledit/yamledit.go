@@ -0,0 +1,670 @@
+// Package yamledit makes structural edits to YAML documents while preserving their formatting.
+//
+// Edits are located with the goccy/go-yaml AST but applied to the source text, so comments, quoting, blank lines
+// and the indentation of untouched lines stay as they are. New lines are indented like the surrounding nodes.
+// The document is re-parsed after every edit, so edits can be chained and always see the current content.
+//
+// Only block style collections can be edited. Flow style collections like `{ a: 1 }` or `[a, b]` are rejected
+// with ErrFlowStyleNotSupported.
+package yamledit
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+
+	"github.com/Finatext/gha-fix/workflow"
+)
+
+var (
+	// ErrNotFound is returned when a path or a sibling key does not exist.
+	ErrNotFound = errors.New("node not found")
+	// ErrKeyExists is returned when inserting a key that the mapping already has.
+	ErrKeyExists = errors.New("key already exists")
+	// ErrUnexpectedNode is returned when the node at a path is not of the kind the edit requires.
+	ErrUnexpectedNode = errors.New("unexpected node type")
+	// ErrFlowStyleNotSupported is returned when editing a flow style collection.
+	ErrFlowStyleNotSupported = errors.New("editing flow style collections is not supported")
+)
+
+// defaultIndent is used when the document has no nested block collection to infer the indentation from.
+const defaultIndent = 2
+
+// Document is an editable YAML document. Only the first document of a multi-document stream is edited.
+type Document struct {
+	lines []string
+	body  ast.Node
+}
+
+// Parse parses content for editing.
+func Parse(content []byte) (*Document, error) {
+	d := &Document{}
+	if err := d.reset(string(content)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Document) reset(content string) error {
+	file, err := parser.ParseBytes([]byte(content), parser.ParseComments)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	d.lines = strings.Split(content, "\n")
+	d.body = nil
+	for _, doc := range file.Docs {
+		if doc.Body != nil {
+			d.body = doc.Body
+			break
+		}
+	}
+	return nil
+}
+
+// Bytes returns the current content.
+func (d *Document) Bytes() []byte {
+	return []byte(d.String())
+}
+
+// String returns the current content.
+func (d *Document) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// ReplaceScalar replaces the scalar at path with value, keeping its quoting style. Plain scalars are quoted if
+// value cannot be written as a plain scalar.
+func (d *Document) ReplaceScalar(path Path, value string) error {
+	node, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	return d.replaceScalar(path, node, value)
+}
+
+// SetKey sets key to value in the mapping at path. An existing scalar value is replaced keeping its quoting
+// style, any other existing value is replaced as a whole. A new key is added after the last key of the mapping.
+// If the value at path is empty (null), a mapping is created.
+//
+// value is encoded as YAML; collections are written in block style.
+func (d *Document) SetKey(path Path, key string, value any) error {
+	m, err := d.mapping(path)
+	if err != nil {
+		return err
+	}
+
+	if i := m.index(key); i >= 0 {
+		entry := m.entries[i]
+		if _, isScalar := entry.Value.(ast.ScalarNode); isScalar {
+			if s, ok := scalarValue(value); ok {
+				return d.replaceScalar(path.Key(key), entry.Value, s)
+			}
+		}
+		start, prefix := d.entryStart(entry)
+		lines, err := d.renderEntry(key, value, columnOf(entry.Key))
+		if err != nil {
+			return err
+		}
+		return d.apply(start, d.blockEnd(start, columnOf(entry.Key), true)+1, withPrefix(lines, prefix))
+	}
+
+	if len(m.entries) == 0 {
+		lines, err := d.renderEntry(key, value, m.childIndent)
+		if err != nil {
+			return err
+		}
+		at := d.blockEnd(m.keyLine, m.parentIndent, false) + 1
+		if m.keyLine < 0 {
+			at = d.contentEnd() + 1
+		}
+		return d.apply(at, at, lines)
+	}
+	return d.insertAfter(m.entries[len(m.entries)-1], key, value)
+}
+
+// InsertBefore adds key with value to the mapping at path, just before the key sibling. Comment lines directly
+// above sibling stay attached to it and the new key is inserted above them.
+func (d *Document) InsertBefore(path Path, sibling, key string, value any) error {
+	m, err := d.mapping(path)
+	if err != nil {
+		return err
+	}
+	if m.index(key) >= 0 {
+		return errors.Wrapf(ErrKeyExists, "%s at %s", key, path)
+	}
+	i := m.index(sibling)
+	if i < 0 {
+		return errors.Wrapf(ErrNotFound, "%s at %s", sibling, path)
+	}
+
+	entry := m.entries[i]
+	column := columnOf(entry.Key)
+	lines, err := d.renderEntry(key, value, column)
+	if err != nil {
+		return err
+	}
+
+	start, prefix := d.entryStart(entry)
+	if prefix != "" {
+		// The sibling follows a sequence indicator, e.g. `- uses: ...`: the new key takes over the indicator.
+		first := withPrefix(lines, prefix)
+		first = append(first, strings.Repeat(" ", column)+d.lines[start][len(prefix):])
+		return d.apply(start, start+1, first)
+	}
+	for start > 0 && isCommentAt(d.lines[start-1], column) {
+		start--
+	}
+	return d.apply(start, start, lines)
+}
+
+// InsertAfter adds key with value to the mapping at path, just after the key sibling and its value.
+func (d *Document) InsertAfter(path Path, sibling, key string, value any) error {
+	m, err := d.mapping(path)
+	if err != nil {
+		return err
+	}
+	if m.index(key) >= 0 {
+		return errors.Wrapf(ErrKeyExists, "%s at %s", key, path)
+	}
+	i := m.index(sibling)
+	if i < 0 {
+		return errors.Wrapf(ErrNotFound, "%s at %s", sibling, path)
+	}
+	return d.insertAfter(m.entries[i], key, value)
+}
+
+func (d *Document) insertAfter(entry *ast.MappingValueNode, key string, value any) error {
+	column := columnOf(entry.Key)
+	lines, err := d.renderEntry(key, value, column)
+	if err != nil {
+		return err
+	}
+	start, _ := d.entryStart(entry)
+	at := d.blockEnd(start, column, true) + 1
+	return d.apply(at, at, lines)
+}
+
+// InsertItem inserts value into the sequence at path so that it becomes the item at index. An index of -1 or
+// the length of the sequence appends. If the value at path is empty (null), a sequence is created.
+//
+// value is encoded as YAML; collections are written in block style.
+func (d *Document) InsertItem(path Path, index int, value any) error {
+	s, err := d.sequence(path)
+	if err != nil {
+		return err
+	}
+	if index == -1 {
+		index = len(s.items)
+	}
+	if index < 0 || index > len(s.items) {
+		return errors.Wrapf(ErrNotFound, "index %d at %s", index, path)
+	}
+
+	lines, err := d.renderItem(value, s.dashIndent)
+	if err != nil {
+		return err
+	}
+
+	var at int
+	switch {
+	case index < len(s.items):
+		at = lineOf(s.items[index])
+		for at > 0 && isCommentAt(d.lines[at-1], s.dashIndent) {
+			at--
+		}
+	case len(s.items) > 0:
+		at = d.blockEnd(lineOf(s.items[len(s.items)-1]), s.dashIndent, false) + 1
+	case s.keyLine >= 0:
+		at = d.blockEnd(s.keyLine, s.parentIndent, false) + 1
+	default:
+		at = d.contentEnd() + 1
+	}
+	return d.apply(at, at, lines)
+}
+
+// apply replaces lines [start, end) with lines and re-parses the document.
+func (d *Document) apply(start, end int, lines []string) error {
+	updated := make([]string, 0, len(d.lines)-(end-start)+len(lines))
+	updated = append(updated, d.lines[:start]...)
+	updated = append(updated, lines...)
+	updated = append(updated, d.lines[end:]...)
+	if err := d.reset(strings.Join(updated, "\n")); err != nil {
+		return errors.Wrap(err, "edit produced invalid YAML")
+	}
+	return nil
+}
+
+func (d *Document) replaceScalar(path Path, node ast.Node, value string) error {
+	if tagged, ok := node.(*ast.TagNode); ok {
+		node = tagged.Value
+	}
+	if _, ok := node.(ast.ScalarNode); !ok {
+		return errors.Wrapf(ErrUnexpectedNode, "%s is not a scalar", path)
+	}
+	if _, ok := node.(*ast.LiteralNode); ok {
+		return errors.Wrapf(ErrUnexpectedNode, "%s is a block scalar", path)
+	}
+
+	tk := node.GetToken()
+	line := lineOf(node)
+	if line < 0 || line >= len(d.lines) {
+		return errors.Wrapf(ErrNotFound, "%s has no position", path)
+	}
+	text := d.lines[line]
+	start := columnOf(node)
+	if start > len(text) {
+		return errors.Wrapf(ErrUnexpectedNode, "%s spans multiple lines", path)
+	}
+
+	var end int
+	var replacement string
+	switch tk.Type {
+	case token.DoubleQuoteType:
+		end = closingQuote(text, start, '"')
+		replacement = strconv.Quote(value)
+	case token.SingleQuoteType:
+		end = closingQuote(text, start, '\'')
+		replacement = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	default:
+		end = start + len(tk.Value)
+		replacement = plainOrQuoted(value)
+	}
+	if end < 0 || end > len(text) || (tk.Type != token.DoubleQuoteType && tk.Type != token.SingleQuoteType &&
+		text[start:end] != tk.Value) {
+		return errors.Wrapf(ErrUnexpectedNode, "%s spans multiple lines", path)
+	}
+
+	return d.apply(line, line+1, []string{text[:start] + replacement + text[end:]})
+}
+
+// closingQuote returns the offset just after the quote closing the string starting at start, or -1.
+func closingQuote(text string, start int, quote byte) int {
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// plainOrQuoted returns value as a plain scalar if it reads back as the same text, else double-quoted.
+// Values that read back as other types (e.g. "10" or "true") are written plain too, so that replacing a number
+// keeps it a number.
+func plainOrQuoted(value string) string {
+	file, err := parser.ParseBytes([]byte("v: "+value), 0)
+	if err != nil || len(file.Docs) != 1 {
+		return strconv.Quote(value)
+	}
+	mapping, ok := file.Docs[0].Body.(*ast.MappingNode)
+	if !ok || len(mapping.Values) != 1 {
+		return strconv.Quote(value)
+	}
+	node, ok := mapping.Values[0].Value.(ast.ScalarNode)
+	if !ok {
+		return strconv.Quote(value)
+	}
+	tk := node.GetToken()
+	if tk == nil || tk.Type == token.DoubleQuoteType || tk.Type == token.SingleQuoteType || tk.Value != value {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// scalarValue returns the text of value if it's a scalar that can replace an existing scalar in place.
+func scalarValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, !strings.Contains(v, "\n")
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	}
+	return "", false
+}
+
+func (d *Document) renderEntry(key string, value any, indent int) ([]string, error) {
+	lines, err := d.render(yaml.MapSlice{{Key: key, Value: value}}, indent)
+	if err != nil {
+		return nil, err
+	}
+	// The encoder quotes YAML 1.1 booleans like `on`, which workflows write plain.
+	encodedKey, err := yaml.Marshal(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pad := strings.Repeat(" ", indent)
+	lines[0] = pad + plainOrQuoted(key) + strings.TrimPrefix(lines[0], pad+strings.TrimSpace(string(encodedKey)))
+	return lines, nil
+}
+
+func (d *Document) renderItem(value any, indent int) ([]string, error) {
+	return d.render([]any{value}, indent)
+}
+
+// render encodes v in block style with the document's indentation and indents it by indent columns.
+func (d *Document) render(v any, indent int) ([]string, error) {
+	unit, indentSequence := d.style()
+	out, err := yaml.MarshalWithOptions(v,
+		yaml.Indent(unit),
+		yaml.IndentSequence(indentSequence),
+		yaml.UseLiteralStyleIfMultiline(true),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	// Top-level sequences are indented when indentSequence is set; remove the common indentation first.
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := indentOf(line); common < 0 || n < common {
+			common = n
+		}
+	}
+	pad := strings.Repeat(" ", indent)
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = pad + line[common:]
+	}
+	return lines, nil
+}
+
+// style infers the indentation width and whether block sequences are indented under their key from the
+// document. Mappings nested in sequence items are ignored since their indentation depends on the `- ` indicator.
+func (d *Document) style() (int, bool) {
+	unit := 0
+	indentSequence := true
+	sawSequence := false
+	ast.Walk(visitorFunc(func(node ast.Node) {
+		entry, ok := node.(*ast.MappingValueNode)
+		if !ok {
+			return
+		}
+		keyColumn := columnOf(entry.Key)
+		switch v := entry.Value.(type) {
+		case *ast.MappingNode:
+			if v.IsFlowStyle || len(v.Values) == 0 || lineOf(v.Values[0].Key) == lineOf(entry.Key) {
+				return
+			}
+			if diff := columnOf(v.Values[0].Key) - keyColumn; diff > 0 && (unit == 0 || diff < unit) {
+				unit = diff
+			}
+		case *ast.SequenceNode:
+			if v.IsFlowStyle || sawSequence {
+				return
+			}
+			sawSequence = true
+			indentSequence = columnOf(v) > keyColumn
+		}
+	}), d.body)
+	if unit == 0 {
+		unit = defaultIndent
+	}
+	return unit, indentSequence
+}
+
+type visitorFunc func(ast.Node)
+
+func (f visitorFunc) Visit(node ast.Node) ast.Visitor {
+	f(node)
+	return f
+}
+
+// entryStart returns the line of a mapping entry's key and the `- ` indicators before the key on that line,
+// if any.
+func (d *Document) entryStart(entry *ast.MappingValueNode) (int, string) {
+	line := lineOf(entry.Key)
+	column := columnOf(entry.Key)
+	text := d.lines[line]
+	if column > len(text) {
+		return line, ""
+	}
+	prefix := text[:column]
+	if strings.TrimSpace(prefix) == "" {
+		return line, ""
+	}
+	return line, prefix
+}
+
+// blockEnd returns the last line of the block starting at line, whose content is indented more than column.
+// When sequenceAtColumn is set, sequence items at column also belong to the block, as in the non-indented
+// style "key:\n- item". Trailing blank lines are not part of the block.
+func (d *Document) blockEnd(line, column int, sequenceAtColumn bool) int {
+	end := line
+	for i := line + 1; i < len(d.lines); i++ {
+		text := d.lines[i]
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		n := indentOf(text)
+		if n > column || (sequenceAtColumn && n == column && strings.HasPrefix(text[n:], "-")) {
+			end = i
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// contentEnd returns the last non-blank line.
+func (d *Document) contentEnd() int {
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(d.lines[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+func isCommentAt(text string, column int) bool {
+	return indentOf(text) == column && strings.HasPrefix(strings.TrimSpace(text), "#")
+}
+
+func withPrefix(lines []string, prefix string) []string {
+	if prefix == "" || len(lines) == 0 {
+		return lines
+	}
+	lines[0] = prefix + lines[0][len(prefix):]
+	return lines
+}
+
+func indentOf(text string) int {
+	return len(text) - len(strings.TrimLeft(text, " "))
+}
+
+// lineOf returns the 0-based line of node.
+func lineOf(node ast.Node) int {
+	tk := node.GetToken()
+	if tk == nil || tk.Position == nil {
+		return -1
+	}
+	return tk.Position.Line - 1
+}
+
+// columnOf returns the 0-based column of node.
+func columnOf(node ast.Node) int {
+	tk := node.GetToken()
+	if tk == nil || tk.Position == nil {
+		return -1
+	}
+	return tk.Position.Column - 1
+}
+
+// mappingTarget is a block mapping, or an empty value that can become one.
+type mappingTarget struct {
+	entries []*ast.MappingValueNode
+	// childIndent is the column of the keys.
+	childIndent int
+	// keyLine is the line of the key holding the mapping, -1 for the document root.
+	keyLine int
+	// parentIndent is the column of the key holding the mapping.
+	parentIndent int
+}
+
+func (m mappingTarget) index(key string) int {
+	for i, entry := range m.entries {
+		if workflow.KeyString(entry.Key) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *Document) mapping(path Path) (mappingTarget, error) {
+	node, parent, err := d.lookupWithParent(path)
+	if err != nil {
+		return mappingTarget{}, err
+	}
+
+	m := mappingTarget{keyLine: -1}
+	if parent != nil {
+		m.keyLine = lineOf(parent.Key)
+		m.parentIndent = columnOf(parent.Key)
+	}
+
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if n.IsFlowStyle {
+			return m, errors.Wrapf(ErrFlowStyleNotSupported, "%s", path)
+		}
+		m.entries = n.Values
+		if len(n.Values) > 0 {
+			m.childIndent = columnOf(n.Values[0].Key)
+		}
+		return m, nil
+	case nil:
+		if parent != nil {
+			return m, errors.Wrapf(ErrUnexpectedNode, "%s is not a mapping", path)
+		}
+		// Empty document
+		return m, nil
+	case *ast.NullNode:
+		if parent == nil || !d.isImplicitNull(parent) {
+			return m, errors.Wrapf(ErrUnexpectedNode, "%s is not a mapping", path)
+		}
+		unit, _ := d.style()
+		m.childIndent = m.parentIndent + unit
+		return m, nil
+	}
+	return m, errors.Wrapf(ErrUnexpectedNode, "%s is not a mapping", path)
+}
+
+// isImplicitNull reports whether the entry has no value at all, as in `key:` optionally followed by a comment.
+// Explicit nulls like `key: null` or `key: ~` are not replaced.
+func (d *Document) isImplicitNull(entry *ast.MappingValueNode) bool {
+	line := lineOf(entry.Key)
+	text := d.lines[line]
+	column := columnOf(entry.Key)
+	if column > len(text) {
+		return false
+	}
+	_, rest, ok := strings.Cut(text[column:], ":")
+	rest = strings.TrimSpace(rest)
+	return ok && (rest == "" || strings.HasPrefix(rest, "#"))
+}
+
+// sequenceTarget is a block sequence, or an empty value that can become one.
+type sequenceTarget struct {
+	items []ast.Node
+	// dashIndent is the column of the `- ` indicators.
+	dashIndent   int
+	keyLine      int
+	parentIndent int
+}
+
+func (d *Document) sequence(path Path) (sequenceTarget, error) {
+	node, parent, err := d.lookupWithParent(path)
+	if err != nil {
+		return sequenceTarget{}, err
+	}
+
+	s := sequenceTarget{keyLine: -1}
+	if parent != nil {
+		s.keyLine = lineOf(parent.Key)
+		s.parentIndent = columnOf(parent.Key)
+	}
+
+	switch n := node.(type) {
+	case *ast.SequenceNode:
+		if n.IsFlowStyle {
+			return s, errors.Wrapf(ErrFlowStyleNotSupported, "%s", path)
+		}
+		s.items = n.Values
+		s.dashIndent = columnOf(n)
+		return s, nil
+	case *ast.NullNode:
+		if parent == nil || !d.isImplicitNull(parent) {
+			return s, errors.Wrapf(ErrUnexpectedNode, "%s is not a sequence", path)
+		}
+		unit, indentSequence := d.style()
+		s.dashIndent = s.parentIndent
+		if indentSequence {
+			s.dashIndent += unit
+		}
+		return s, nil
+	}
+	return s, errors.Wrapf(ErrUnexpectedNode, "%s is not a sequence", path)
+}
+
+func (d *Document) lookup(path Path) (ast.Node, error) {
+	node, _, err := d.lookupWithParent(path)
+	return node, err
+}
+
+// lookupWithParent returns the node at path and the mapping entry holding it, if the last segment is a key.
+func (d *Document) lookupWithParent(path Path) (ast.Node, *ast.MappingValueNode, error) {
+	node := d.body
+	var parent *ast.MappingValueNode
+	for i, seg := range path {
+		if tagged, ok := node.(*ast.TagNode); ok {
+			node = tagged.Value
+		}
+		parent = nil
+		if seg.isIndex {
+			seq, ok := node.(*ast.SequenceNode)
+			if !ok || seg.index < 0 || seg.index >= len(seq.Values) {
+				return nil, nil, errors.Wrapf(ErrNotFound, "%s", path[:i+1])
+			}
+			node = seq.Values[seg.index]
+			continue
+		}
+
+		mapping, ok := node.(*ast.MappingNode)
+		if !ok {
+			return nil, nil, errors.Wrapf(ErrNotFound, "%s", path[:i+1])
+		}
+		found := false
+		for _, entry := range mapping.Values {
+			if workflow.KeyString(entry.Key) == seg.key {
+				node = entry.Value
+				parent = entry
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, errors.Wrapf(ErrNotFound, "%s", path[:i+1])
+		}
+	}
+	return node, parent, nil
+}
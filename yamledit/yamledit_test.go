@@ -0,0 +1,241 @@
+package yamledit
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const workflowYAML = `name: CI # the name
+on: push
+
+jobs:
+  # The build job
+  build:
+    runs-on: ubuntu-latest
+
+    steps:
+      - uses: actions/checkout@v4 # checkout
+      - name: "Test"
+        run: go test ./...
+  lint:
+    runs-on: 'ubuntu-latest'
+    steps:
+      - run: make lint
+`
+
+func edit(t *testing.T, input string, f func(d *Document) error) string {
+	t.Helper()
+	d, err := Parse([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, f(d))
+	return d.String()
+}
+
+func TestDocument_InsertBefore(t *testing.T) {
+	got := edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertBefore(KeyPath("jobs", "build"), "runs-on", "timeout-minutes", 10)
+	})
+	assert.Equal(t, `name: CI # the name
+on: push
+
+jobs:
+  # The build job
+  build:
+    timeout-minutes: 10
+    runs-on: ubuntu-latest
+
+    steps:
+      - uses: actions/checkout@v4 # checkout
+      - name: "Test"
+        run: go test ./...
+  lint:
+    runs-on: 'ubuntu-latest'
+    steps:
+      - run: make lint
+`, got)
+
+	// Inserting before a job keeps the comment above it attached to the job.
+	got = edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertBefore(KeyPath("jobs"), "build", "setup", map[string]any{"runs-on": "ubuntu-latest"})
+	})
+	assert.Contains(t, got, `jobs:
+  setup:
+    runs-on: ubuntu-latest
+  # The build job
+  build:
+`)
+}
+
+func TestDocument_InsertBefore_SequenceItem(t *testing.T) {
+	got := edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertBefore(KeyPath("jobs", "build", "steps").Index(0), "uses", "id", "checkout")
+	})
+	assert.Contains(t, got, `    steps:
+      - id: checkout
+        uses: actions/checkout@v4 # checkout
+      - name: "Test"
+`)
+}
+
+func TestDocument_InsertAfter(t *testing.T) {
+	got := edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertAfter(KeyPath("jobs", "build"), "steps", "env", map[string]string{"GOFLAGS": "-mod=mod"})
+	})
+	assert.Contains(t, got, `        run: go test ./...
+    env:
+      GOFLAGS: -mod=mod
+  lint:
+`)
+
+	// Blank lines between keys are kept.
+	got = edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertAfter(KeyPath("jobs", "build"), "runs-on", "timeout-minutes", 5)
+	})
+	assert.Contains(t, got, `    runs-on: ubuntu-latest
+    timeout-minutes: 5
+
+    steps:
+`)
+}
+
+func TestDocument_SetKey(t *testing.T) {
+	// Appended after the last key, including its nested value.
+	got := edit(t, workflowYAML, func(d *Document) error {
+		return d.SetKey(KeyPath("jobs", "lint"), "timeout-minutes", 5)
+	})
+	assert.Contains(t, got, `      - run: make lint
+    timeout-minutes: 5
+`)
+
+	// An existing scalar keeps its quoting.
+	got = edit(t, workflowYAML, func(d *Document) error {
+		return d.SetKey(KeyPath("jobs", "lint"), "runs-on", "ubuntu-24.04")
+	})
+	assert.Contains(t, got, "    runs-on: 'ubuntu-24.04'\n")
+
+	// A collection replaces the whole value.
+	got = edit(t, workflowYAML, func(d *Document) error {
+		return d.SetKey(KeyPath("jobs", "lint"), "steps", []map[string]string{{"run": "make check"}})
+	})
+	assert.Contains(t, got, `  lint:
+    runs-on: 'ubuntu-latest'
+    steps:
+      - run: make check
+`)
+	assert.NotContains(t, got, "make lint")
+}
+
+func TestDocument_SetKey_EmptyValue(t *testing.T) {
+	input := "jobs:\n    build:\n        runs-on: ubuntu-latest\n    empty:\n    # comment\nafter: true\n"
+	got := edit(t, input, func(d *Document) error {
+		return d.SetKey(KeyPath("jobs", "empty"), "runs-on", "ubuntu-latest")
+	})
+	// The indentation is taken from the document (4 spaces).
+	assert.Equal(t, "jobs:\n    build:\n        runs-on: ubuntu-latest\n    empty:\n        runs-on: ubuntu-latest\n    # comment\nafter: true\n", got)
+
+	got = edit(t, "", func(d *Document) error {
+		return d.SetKey(nil, "on", "push")
+	})
+	assert.Equal(t, "on: push\n", got)
+}
+
+func TestDocument_ReplaceScalar(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  Path
+		value string
+		want  string
+	}{
+		{"plain keeps comment", KeyPath("name"), "Build", "name: Build # the name\n"},
+		{"plain needs quotes", KeyPath("name"), "a: b", "name: \"a: b\" # the name\n"},
+		{"double quoted", KeyPath("jobs", "build", "steps").Index(1).Key("name"), `Say "hi"`, `      - name: "Say \"hi\""` + "\n"},
+		{"single quoted", KeyPath("jobs", "lint", "runs-on"), "it's", "    runs-on: 'it''s'\n"},
+		{"sequence item value", KeyPath("jobs", "build", "steps").Index(0).Key("uses"), "actions/checkout@v5", "      - uses: actions/checkout@v5 # checkout\n"},
+		{"number stays plain", KeyPath("on"), "10", "on: 10\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := edit(t, workflowYAML, func(d *Document) error {
+				return d.ReplaceScalar(tt.path, tt.value)
+			})
+			assert.Contains(t, got, tt.want)
+		})
+	}
+}
+
+func TestDocument_InsertItem(t *testing.T) {
+	got := edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertItem(KeyPath("jobs", "lint", "steps"), -1, map[string]string{"name": "Vet", "run": "go vet ./..."})
+	})
+	assert.Contains(t, got, `      - run: make lint
+      - name: Vet
+        run: go vet ./...
+`)
+
+	got = edit(t, workflowYAML, func(d *Document) error {
+		return d.InsertItem(KeyPath("jobs", "build", "steps"), 1, map[string]string{"run": "make"})
+	})
+	assert.Contains(t, got, `      - uses: actions/checkout@v4 # checkout
+      - run: make
+      - name: "Test"
+`)
+
+	// Non-indented sequences stay non-indented.
+	got = edit(t, "branches:\n- main\nother: 1\n", func(d *Document) error {
+		return d.InsertItem(KeyPath("branches"), -1, "release/*")
+	})
+	assert.Equal(t, "branches:\n- main\n- release/*\nother: 1\n", got)
+
+	got = edit(t, "on:\n  push:\n    branches:\n", func(d *Document) error {
+		return d.InsertItem(KeyPath("on", "push", "branches"), 0, "main")
+	})
+	assert.Equal(t, "on:\n  push:\n    branches:\n      - main\n", got)
+}
+
+func TestDocument_Chained(t *testing.T) {
+	got := edit(t, workflowYAML, func(d *Document) error {
+		for _, job := range []string{"build", "lint"} {
+			if err := d.InsertBefore(KeyPath("jobs", job), "runs-on", "timeout-minutes", 5); err != nil {
+				return err
+			}
+		}
+		return d.ReplaceScalar(KeyPath("jobs", "lint", "timeout-minutes"), "15")
+	})
+	assert.Contains(t, got, "  build:\n    timeout-minutes: 5\n    runs-on: ubuntu-latest\n")
+	assert.Contains(t, got, "  lint:\n    timeout-minutes: 15\n    runs-on: 'ubuntu-latest'\n")
+}
+
+func TestDocument_Errors(t *testing.T) {
+	d, err := Parse([]byte(workflowYAML + "flow: { a: 1 }\n"))
+	require.NoError(t, err)
+
+	err = d.SetKey(KeyPath("jobs", "missing"), "a", 1)
+	assert.True(t, errors.Is(err, ErrNotFound), err)
+	assert.ErrorContains(t, err, "$.jobs.missing")
+
+	err = d.InsertBefore(KeyPath("jobs", "build"), "runs-on", "steps", 1)
+	assert.True(t, errors.Is(err, ErrKeyExists), err)
+
+	err = d.InsertAfter(KeyPath("jobs", "build"), "missing", "a", 1)
+	assert.True(t, errors.Is(err, ErrNotFound), err)
+
+	err = d.SetKey(KeyPath("flow"), "b", 2)
+	assert.True(t, errors.Is(err, ErrFlowStyleNotSupported), err)
+
+	err = d.InsertItem(KeyPath("jobs"), 0, "a")
+	assert.True(t, errors.Is(err, ErrUnexpectedNode), err)
+
+	err = d.ReplaceScalar(KeyPath("jobs"), "a")
+	assert.True(t, errors.Is(err, ErrUnexpectedNode), err)
+
+	// Failed edits leave the document unchanged.
+	assert.Equal(t, workflowYAML+"flow: { a: 1 }\n", d.String())
+}
+
+func TestPath_String(t *testing.T) {
+	assert.Equal(t, "$", Path(nil).String())
+	assert.Equal(t, `$.jobs."my job".steps[0].uses`, KeyPath("jobs", "my job", "steps").Index(0).Key("uses").String())
+}